@@ -0,0 +1,276 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mrsingh-rishi/voice-bot/dtmf"
+	"github.com/mrsingh-rishi/voice-bot/logging"
+	"github.com/mrsingh-rishi/voice-bot/stt"
+	"github.com/mrsingh-rishi/voice-bot/tts"
+	"github.com/mrsingh-rishi/voice-bot/vad"
+)
+
+const DefaultID = "default"
+
+// Config describes how a single voice agent behaves on a call.
+type Config struct {
+	ID           string `json:"id"`
+	SystemPrompt string `json:"system_prompt"`
+	Greeting     string `json:"greeting"`
+	Model        string `json:"model"`
+	VoiceID      string `json:"voice_id"`
+	TTSModel     string `json:"tts_model"`
+	// Locale drives text normalization before speech synthesis (e.g. "en-US",
+	// "en-GB"). Numbers and dates are only spelled out for English locales.
+	Locale string `json:"locale"`
+	// Pronunciations maps brand names and acronyms to how they should be spoken.
+	Pronunciations map[string]string `json:"pronunciations"`
+	// FillerThresholdMs is how long the caller may wait for the first audio of
+	// an answer before a filler word is played. Zero or less disables fillers.
+	FillerThresholdMs int `json:"filler_threshold_ms"`
+	// WarmPhrases are synthesized into the TTS cache at startup (confirmations,
+	// voicemail drops, ...). The greeting and inactivity messages are always warmed.
+	WarmPhrases []string `json:"warm_phrases"`
+	// TTSStreaming keeps one ElevenLabs websocket open per call instead of
+	// making an HTTP request per sentence.
+	TTSStreaming bool `json:"tts_streaming"`
+	// VoiceSettings override the provider defaults for every utterance.
+	VoiceSettings *tts.VoiceSettings `json:"voice_settings"`
+	// VoicePresets are named delivery styles (e.g. "excited", "calm") the
+	// model may select per sentence with a leading "[name]" tag.
+	VoicePresets map[string]tts.VoiceSettings `json:"voice_presets"`
+	// VAD tunes the local voice activity detector; unset fields keep its defaults.
+	VAD *vad.Config `json:"vad"`
+	// Interruptible lets the caller cut the agent off by speaking over it.
+	Interruptible bool `json:"interruptible"`
+	// SilenceTimeoutMs is how long the caller may stay quiet after the agent
+	// finishes speaking before RepromptMessage is said. Zero or less, the
+	// default, disables reprompts and the hang-up that follows them.
+	SilenceTimeoutMs int    `json:"silence_timeout_ms"`
+	RepromptMessage  string `json:"reprompt_message"`
+	// MaxReprompts is how many reprompts go unanswered before GoodbyeMessage
+	// is said and the call is hung up.
+	MaxReprompts   int    `json:"max_reprompts"`
+	GoodbyeMessage string `json:"goodbye_message"`
+	// MaxCallDurationSec caps the length of a call; WrapUpMessage is said
+	// before hanging up. Zero or less, the default, means no limit.
+	MaxCallDurationSec int    `json:"max_call_duration_sec"`
+	WrapUpMessage      string `json:"wrap_up_message"`
+	// STTFailureMessage is said before hanging up when speech recognition
+	// cannot be restored.
+	STTFailureMessage string `json:"stt_failure_message"`
+	// DTMF enables keypad input and the collect_digits tool; nil ignores key presses.
+	DTMF *DTMFConfig `json:"dtmf"`
+	// IVRNavigation gives the agent the press_digits tool so it can work
+	// through phone menus on outbound calls.
+	IVRNavigation bool `json:"ivr_navigation"`
+	// HidePIIFromModel masks phone numbers, emails, card numbers, SSNs and
+	// keypad digits in what the caller says before the LLM sees it, so the
+	// agent cannot read any of them back or act on them. Logs are masked
+	// either way. There is no option for stored transcripts because the bot
+	// keeps none: the conversation only lives in the call's LLM history.
+	HidePIIFromModel bool `json:"hide_pii_from_model"`
+	// Languages lets the agent switch language when the caller does; nil
+	// keeps every call in Locale.
+	Languages *LanguagePolicy `json:"languages"`
+	// STT tunes Deepgram for this agent. Keywords and keyterms may use
+	// "{{name}}" placeholders filled from the call variables.
+	STT *stt.DeepgramOptions `json:"stt"`
+	// STTFallbacks are tried in order when Deepgram cannot be reached with STT.
+	STTFallbacks []stt.DeepgramOptions `json:"stt_fallbacks"`
+	// LLMFallbacks are tried in order when Model fails or does not start
+	// answering within LLMTimeoutMs (zero uses the default).
+	LLMFallbacks []LLMFallback `json:"llm_fallbacks"`
+	LLMTimeoutMs int           `json:"llm_timeout_ms"`
+	// TTSFallbacks are tried in order when ElevenLabs fails or sends no
+	// audio within TTSTimeoutMs (zero uses the default).
+	TTSFallbacks []TTSFallback `json:"tts_fallbacks"`
+	TTSTimeoutMs int           `json:"tts_timeout_ms"`
+	// FallbackMessage is said when every provider in a chain failed. It is
+	// always warmed so it plays from the cache during an outage.
+	FallbackMessage string `json:"fallback_message"`
+}
+
+// DTMFConfig holds the rules for key presses the agent did not ask for.
+type DTMFConfig struct {
+	// Terminator ends an entry early; defaults to "#"
+	Terminator string `json:"terminator"`
+	// TimeoutMs ends an entry after this long without a key press; defaults to 3000
+	TimeoutMs int `json:"timeout_ms"`
+	// MaxDigits ends an entry once this many digits are in; zero means no limit
+	MaxDigits int `json:"max_digits"`
+}
+
+// KeypadOptions returns the collection rules for unprompted key presses.
+func (c *DTMFConfig) KeypadOptions() dtmf.Options {
+	opts := dtmf.Options{
+		Terminator: c.Terminator,
+		Timeout:    time.Duration(c.TimeoutMs) * time.Millisecond,
+		MaxDigits:  c.MaxDigits,
+	}
+	if opts.Terminator == "" {
+		opts.Terminator = "#"
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 3 * time.Second
+	}
+	return opts
+}
+
+// VADConfig returns the voice activity detector settings for this agent.
+func (c *Config) VADConfig() vad.Config {
+	if c.VAD == nil {
+		return vad.DefaultConfig()
+	}
+	return *c.VAD
+}
+
+// TTSSettings returns the voice settings every utterance starts from.
+func (c *Config) TTSSettings() tts.VoiceSettings {
+	return tts.DefaultVoiceSettings(c.TTSModel).Merge(c.VoiceSettings)
+}
+
+// Instructions returns the system prompt, extended with how to use the voice
+// presets and the keypad when the agent has them.
+func (c *Config) Instructions() string {
+	instructions := c.SystemPrompt
+	if len(c.VoicePresets) > 0 {
+		names := make([]string, 0, len(c.VoicePresets))
+		for name := range c.VoicePresets {
+			names = append(names, "["+name+"]")
+		}
+		sort.Strings(names)
+		instructions += "\n\nYour replies are spoken aloud. To change how a sentence is delivered, start it with one of these tags: " +
+			strings.Join(names, ", ") + ". Use them sparingly and only when the emotion fits."
+	}
+	if c.DTMF != nil {
+		instructions += "\n\nThe caller can also use their phone keypad. Key presses arrive as user messages starting with [keypad] followed by JSON. " +
+			"To ask for a number such as an account number, PIN or menu choice, call collect_digits and then tell the caller what to enter. " +
+			"Mark PINs and other secrets as sensitive; their digits are hidden from you, so never ask the caller to say them aloud. " +
+			"An entry with ended_by no_input means the caller typed nothing."
+	}
+	if c.Languages != nil {
+		instructions += "\n\nAlways reply in the language the caller last spoke, as long as it is one of: " +
+			strings.Join(c.Languages.names(), ", ") + ". Otherwise reply in " + c.Languages.Default + "."
+	}
+	if c.IVRNavigation {
+		instructions += "\n\nIf you reach an automated phone menu, listen to the options and call press_digits to choose one. " +
+			"Do not talk to the menu; only press keys until you reach a person or the option you need. " +
+			"To enter a sensitive keypad entry of the caller, such as a PIN, pass its ref to press_digits."
+	}
+	return instructions
+}
+
+// PhrasesToWarm returns every phrase this agent wants pre-synthesized.
+func (c *Config) PhrasesToWarm() []string {
+	candidates := []string{c.Greeting, c.STTFailureMessage, c.FallbackMessage}
+	if c.SilenceTimeoutMs > 0 {
+		candidates = append(candidates, c.RepromptMessage, c.GoodbyeMessage)
+	}
+	if c.MaxCallDurationSec > 0 {
+		candidates = append(candidates, c.WrapUpMessage)
+	}
+	phrases := make([]string, 0, len(c.WarmPhrases)+len(candidates))
+	for _, phrase := range candidates {
+		if phrase != "" {
+			phrases = append(phrases, phrase)
+		}
+	}
+	return append(phrases, c.WarmPhrases...)
+}
+
+// Default returns the agent the bot used before agents were configurable.
+func Default() *Config {
+	return &Config{
+		ID:                DefaultID,
+		SystemPrompt:      "You are a helpful assistant.",
+		Greeting:          "Hello, how can I help you today?",
+		Model:             "gpt-4o-mini",
+		VoiceID:           "cjVigY5qzO86Huf0OWal",
+		TTSModel:          "eleven_multilingual_v1",
+		Locale:            "en-US",
+		FillerThresholdMs: 900,
+		Interruptible:     true,
+		RepromptMessage:   "Are you still there?",
+		GoodbyeMessage:    "It sounds like you've stepped away, so I'll hang up now. Goodbye!",
+		WrapUpMessage:     "We've reached the time limit for this call. Thank you for calling, goodbye!",
+		STTFailureMessage: "I'm sorry, I'm having trouble hearing you right now. Please try calling again later. Goodbye!",
+		FallbackMessage:   "Sorry, one moment please.",
+	}
+}
+
+// Load reads a single agent config from a JSON file. Fields that are not set
+// fall back to the values of Default().
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := Default()
+	cfg.ID = ""
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse agent %s: %w", path, err)
+	}
+	if cfg.ID == "" {
+		cfg.ID = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return cfg, nil
+}
+
+// Registry holds every agent known to the server, keyed by ID.
+type Registry struct {
+	agents map[string]*Config
+}
+
+// NewRegistry returns a registry that only contains the default agent.
+func NewRegistry() *Registry {
+	return &Registry{
+		agents: map[string]*Config{DefaultID: Default()},
+	}
+}
+
+// LoadRegistry loads every *.json file in dir as an agent. An empty dir
+// yields a registry with only the default agent.
+func LoadRegistry(dir string) (*Registry, error) {
+	registry := NewRegistry()
+	if dir == "" {
+		return registry, nil
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		cfg, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		registry.agents[cfg.ID] = cfg
+		logging.Logger("agent").Info("Loaded agent", "agent", cfg.ID, "path", path)
+	}
+	return registry, nil
+}
+
+// Get returns the agent with the given ID. An empty ID selects the default agent.
+func (r *Registry) Get(id string) (*Config, bool) {
+	if id == "" {
+		id = DefaultID
+	}
+	cfg, ok := r.agents[id]
+	return cfg, ok
+}
+
+// All returns every registered agent.
+func (r *Registry) All() []*Config {
+	all := make([]*Config, 0, len(r.agents))
+	for _, cfg := range r.agents {
+		all = append(all, cfg)
+	}
+	return all
+}
@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"time"
+
+	"github.com/mrsingh-rishi/voice-bot/stt"
+)
+
+// LLMFallback is an OpenAI-compatible model tried when the agent's Model
+// fails or is too slow to answer.
+type LLMFallback struct {
+	Model string `json:"model"`
+	// BaseURL points at an OpenAI-compatible API; empty means OpenAI
+	BaseURL string `json:"base_url"`
+	// APIKeyEnv names the environment variable holding the key; empty
+	// reuses OPEN_AI_API_KEY
+	APIKeyEnv string `json:"api_key_env"`
+}
+
+// TTSFallback is an OpenAI-compatible speech endpoint tried when ElevenLabs
+// fails or is too slow to answer.
+type TTSFallback struct {
+	// BaseURL is the API root, e.g. "https://api.openai.com/v1"; empty means OpenAI
+	BaseURL string `json:"base_url"`
+	Model   string `json:"model"`
+	Voice   string `json:"voice"`
+	// APIKeyEnv names the environment variable holding the key; empty
+	// reuses OPEN_AI_API_KEY
+	APIKeyEnv string `json:"api_key_env"`
+}
+
+// STTChain returns the Deepgram options to try, primary first, for a call
+// with the given variables.
+func (c *Config) STTChain(variables map[string]string) []stt.DeepgramOptions {
+	primary := stt.DefaultDeepgramOptions()
+	if c.STT != nil {
+		primary = *c.STT
+	}
+	chain := []stt.DeepgramOptions{primary.WithVariables(variables)}
+	for _, options := range c.STTFallbacks {
+		chain = append(chain, options.WithVariables(variables))
+	}
+	return chain
+}
+
+// LLMTimeout is how long each model gets to start answering.
+func (c *Config) LLMTimeout() time.Duration {
+	return time.Duration(c.LLMTimeoutMs) * time.Millisecond
+}
+
+// TTSTimeout is how long each TTS provider gets to produce its first audio.
+func (c *Config) TTSTimeout() time.Duration {
+	return time.Duration(c.TTSTimeoutMs) * time.Millisecond
+}
@@ -0,0 +1,130 @@
+package agent
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/mrsingh-rishi/voice-bot/tts"
+)
+
+// LanguagePolicy lets an agent follow the caller into another language.
+type LanguagePolicy struct {
+	// Default is the language the call starts in, e.g. "en"
+	Default string `json:"default"`
+	// Languages maps every allowed language (ISO 639-1, e.g. "es") to how
+	// the agent speaks it. The default language may be left out, in which
+	// case the agent's own voice and locale are used.
+	Languages map[string]LanguageProfile `json:"languages"`
+}
+
+// LanguageProfile is how the agent sounds in one language. Empty fields
+// fall back to the agent's own settings.
+type LanguageProfile struct {
+	VoiceID       string             `json:"voice_id"`
+	TTSModel      string             `json:"tts_model"`
+	Locale        string             `json:"locale"`
+	VoiceSettings *tts.VoiceSettings `json:"voice_settings"`
+}
+
+// Voice is everything that changes when the agent switches language.
+type Voice struct {
+	Language string
+	VoiceID  string
+	Settings tts.VoiceSettings
+	Locale   string
+}
+
+// BaseLanguage reduces a language tag such as "es-419" or "EN_us" to its
+// lower-case primary subtag.
+func BaseLanguage(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	return tag
+}
+
+// Allowed returns the policy language matching a detected language tag.
+func (p *LanguagePolicy) Allowed(tag string) (string, bool) {
+	lang := BaseLanguage(tag)
+	if lang == "" {
+		return "", false
+	}
+	if lang == BaseLanguage(p.Default) {
+		return lang, true
+	}
+	for allowed := range p.Languages {
+		if BaseLanguage(allowed) == lang {
+			return lang, true
+		}
+	}
+	return "", false
+}
+
+// names lists the allowed languages, default first.
+func (p *LanguagePolicy) names() []string {
+	others := make([]string, 0, len(p.Languages))
+	for lang := range p.Languages {
+		if BaseLanguage(lang) != BaseLanguage(p.Default) {
+			others = append(others, lang)
+		}
+	}
+	sort.Strings(others)
+	return append([]string{p.Default}, others...)
+}
+
+// DefaultLanguage is the language the agent starts every call in.
+func (c *Config) DefaultLanguage() string {
+	if c.Languages != nil && c.Languages.Default != "" {
+		return BaseLanguage(c.Languages.Default)
+	}
+	return BaseLanguage(c.Locale)
+}
+
+// Voices returns how the agent speaks each language it may use on a call,
+// default language first.
+func (c *Config) Voices() []Voice {
+	if c.Languages == nil {
+		return []Voice{c.VoiceFor(c.DefaultLanguage())}
+	}
+	names := c.Languages.names()
+	voices := make([]Voice, 0, len(names))
+	for _, lang := range names {
+		voices = append(voices, c.VoiceFor(BaseLanguage(lang)))
+	}
+	return voices
+}
+
+// VoiceFor returns how the agent speaks lang. Languages without a profile
+// use the agent's own voice.
+func (c *Config) VoiceFor(lang string) Voice {
+	voice := Voice{
+		Language: lang,
+		VoiceID:  c.VoiceID,
+		Settings: c.TTSSettings(),
+		Locale:   c.Locale,
+	}
+	if c.Languages == nil {
+		return voice
+	}
+	for name, profile := range c.Languages.Languages {
+		if BaseLanguage(name) != BaseLanguage(lang) {
+			continue
+		}
+		if profile.VoiceID != "" {
+			voice.VoiceID = profile.VoiceID
+		}
+		model := c.TTSModel
+		if profile.TTSModel != "" {
+			model = profile.TTSModel
+		}
+		voice.Settings = tts.DefaultVoiceSettings(model).Merge(c.VoiceSettings).Merge(profile.VoiceSettings)
+		if profile.Locale != "" {
+			voice.Locale = profile.Locale
+		} else {
+			voice.Locale = lang
+		}
+		break
+	}
+	return voice
+}
@@ -0,0 +1,74 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// DTMF timing. ITU-T Q.24 requires tones and gaps of at least 40 ms; these
+// are comfortably above that so IVRs behind lossy codecs still detect them.
+const (
+	DTMFToneDuration = 100 * time.Millisecond
+	DTMFGapDuration  = 100 * time.Millisecond
+	// DTMFPause is inserted for a "w" in a digit string, as in Twilio's sendDigits
+	DTMFPause = 500 * time.Millisecond
+)
+
+// dtmfAmplitude is the peak of each of the two tones, about -9 dBFS apiece
+// so their sum never clips.
+const dtmfAmplitude = 0.35 * math.MaxInt16
+
+// dtmfFrequencies maps each key to its low (row) and high (column) tone in Hz.
+var dtmfFrequencies = map[rune][2]float64{
+	'1': {697, 1209}, '2': {697, 1336}, '3': {697, 1477}, 'A': {697, 1633},
+	'4': {770, 1209}, '5': {770, 1336}, '6': {770, 1477}, 'B': {770, 1633},
+	'7': {852, 1209}, '8': {852, 1336}, '9': {852, 1477}, 'C': {852, 1633},
+	'*': {941, 1209}, '0': {941, 1336}, '#': {941, 1477}, 'D': {941, 1633},
+}
+
+// ValidDTMF reports whether digits only holds keypad keys and "w" pauses.
+func ValidDTMF(digits string) error {
+	for _, r := range digits {
+		if _, ok := dtmfFrequencies[r]; !ok && r != 'w' {
+			return fmt.Errorf("%q is not a keypad key", r)
+		}
+	}
+	return nil
+}
+
+// DTMFTones renders digits as dual-tone PCM at the given sample rate. Every
+// key is followed by a gap of silence; "w" adds DTMFPause of silence.
+func DTMFTones(digits string, sampleRate int) ([]int16, error) {
+	if err := ValidDTMF(digits); err != nil {
+		return nil, err
+	}
+	samplesFor := func(d time.Duration) int {
+		return int(d * time.Duration(sampleRate) / time.Second)
+	}
+	var out []int16
+	for _, r := range digits {
+		if r == 'w' {
+			out = append(out, make([]int16, samplesFor(DTMFPause))...)
+			continue
+		}
+		freqs := dtmfFrequencies[r]
+		n := samplesFor(DTMFToneDuration)
+		for i := 0; i < n; i++ {
+			t := float64(i) / float64(sampleRate)
+			v := dtmfAmplitude * (math.Sin(2*math.Pi*freqs[0]*t) + math.Sin(2*math.Pi*freqs[1]*t))
+			out = append(out, clamp16(v))
+		}
+		out = append(out, make([]int16, samplesFor(DTMFGapDuration))...)
+	}
+	return out, nil
+}
+
+// DTMFFrames renders digits as 8 kHz mu-law split into Twilio media frames.
+func DTMFFrames(digits string) ([][]byte, error) {
+	samples, err := DTMFTones(digits, Rate8k)
+	if err != nil {
+		return nil, err
+	}
+	return Frames(EncodeMuLaw(samples), TwilioFrameBytes), nil
+}
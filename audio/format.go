@@ -0,0 +1,166 @@
+package audio
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Codec string
+
+const (
+	CodecMuLaw Codec = "ulaw"
+	CodecALaw  Codec = "alaw"
+	CodecPCM16 Codec = "pcm"
+)
+
+// FrameDuration is the packetization interval telephony transports use.
+const FrameDuration = 20 * time.Millisecond
+
+// TwilioFrameBytes is one 20 ms frame of 8 kHz mu-law.
+const TwilioFrameBytes = 160
+
+// Format describes raw mono audio.
+type Format struct {
+	Codec      Codec
+	SampleRate int
+}
+
+// Telephony is the 8 kHz mu-law format Twilio media streams carry.
+var Telephony = Format{Codec: CodecMuLaw, SampleRate: Rate8k}
+
+// ParseFormat parses provider-style names such as "ulaw_8000", "pcm_16000"
+// or "alaw_8000". "mulaw" is accepted as an alias for "ulaw".
+func ParseFormat(name string) (Format, error) {
+	codec, rateText, ok := strings.Cut(strings.ToLower(name), "_")
+	if !ok {
+		return Format{}, fmt.Errorf("unsupported audio format %q", name)
+	}
+	rate, err := strconv.Atoi(rateText)
+	if err != nil || rate <= 0 {
+		return Format{}, fmt.Errorf("unsupported audio format %q", name)
+	}
+	switch Codec(codec) {
+	case CodecMuLaw, "mulaw":
+		return Format{Codec: CodecMuLaw, SampleRate: rate}, nil
+	case CodecALaw:
+		return Format{Codec: CodecALaw, SampleRate: rate}, nil
+	case CodecPCM16, "linear16":
+		return Format{Codec: CodecPCM16, SampleRate: rate}, nil
+	}
+	return Format{}, fmt.Errorf("unsupported audio format %q", name)
+}
+
+func (f Format) String() string {
+	return fmt.Sprintf("%s_%d", f.Codec, f.SampleRate)
+}
+
+func (f Format) BytesPerSample() int {
+	if f.Codec == CodecPCM16 {
+		return 2
+	}
+	return 1
+}
+
+// FrameBytes returns how many bytes hold d of audio in this format.
+func (f Format) FrameBytes(d time.Duration) int {
+	return int(int64(f.SampleRate)*int64(d)/int64(time.Second)) * f.BytesPerSample()
+}
+
+// Decode converts raw bytes in this format to linear samples.
+func (f Format) Decode(data []byte) []int16 {
+	switch f.Codec {
+	case CodecMuLaw:
+		return DecodeMuLaw(data)
+	case CodecALaw:
+		return DecodeALaw(data)
+	default:
+		return PCM16FromBytes(data)
+	}
+}
+
+// Encode converts linear samples to raw bytes in this format.
+func (f Format) Encode(samples []int16) []byte {
+	switch f.Codec {
+	case CodecMuLaw:
+		return EncodeMuLaw(samples)
+	case CodecALaw:
+		return EncodeALaw(samples)
+	default:
+		return PCM16ToBytes(samples)
+	}
+}
+
+// Transcoder converts a byte stream between formats, carrying resampler
+// state and partial samples across chunks.
+type Transcoder struct {
+	from, to  Format
+	resampler *Resampler
+	carry     []byte
+}
+
+func NewTranscoder(from, to Format) *Transcoder {
+	return &Transcoder{
+		from:      from,
+		to:        to,
+		resampler: NewResampler(from.SampleRate, to.SampleRate),
+	}
+}
+
+// Process transcodes the next chunk of the stream.
+func (t *Transcoder) Process(data []byte) []byte {
+	if t.from == t.to {
+		return data
+	}
+	if len(t.carry) > 0 {
+		data = append(t.carry, data...)
+		t.carry = nil
+	}
+	if n := t.from.BytesPerSample(); len(data)%n != 0 {
+		t.carry = append([]byte(nil), data[len(data)-len(data)%n:]...)
+		data = data[:len(data)-len(data)%n]
+	}
+	samples := t.resampler.Process(t.from.Decode(data))
+	return t.to.Encode(samples)
+}
+
+// Framer slices a byte stream into fixed-size frames, holding back any
+// remainder until more data arrives.
+type Framer struct {
+	size int
+	buf  []byte
+}
+
+func NewFramer(size int) *Framer {
+	return &Framer{size: size}
+}
+
+// Push appends data and returns every complete frame.
+func (f *Framer) Push(data []byte) [][]byte {
+	f.buf = append(f.buf, data...)
+	var frames [][]byte
+	for len(f.buf) >= f.size {
+		frame := make([]byte, f.size)
+		copy(frame, f.buf[:f.size])
+		frames = append(frames, frame)
+		f.buf = f.buf[f.size:]
+	}
+	return frames
+}
+
+// Flush returns the incomplete trailing frame, if any.
+func (f *Framer) Flush() []byte {
+	rest := f.buf
+	f.buf = nil
+	return rest
+}
+
+// Frames slices a complete buffer into frames; the last one may be short.
+func Frames(data []byte, size int) [][]byte {
+	var frames [][]byte
+	for start := 0; start < len(data); start += size {
+		frames = append(frames, data[start:min(start+size, len(data))])
+	}
+	return frames
+}
@@ -0,0 +1,79 @@
+package audio
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Format
+		wantErr bool
+	}{
+		{"ulaw_8000", Telephony, false},
+		{"mulaw_8000", Telephony, false},
+		{"alaw_8000", Format{Codec: CodecALaw, SampleRate: Rate8k}, false},
+		{"PCM_16000", Format{Codec: CodecPCM16, SampleRate: Rate16k}, false},
+		{"linear16_24000", Format{Codec: CodecPCM16, SampleRate: Rate24k}, false},
+		{"mp3_44100", Format{}, true},
+		{"pcm", Format{}, true},
+		{"pcm_0", Format{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFormat(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseFormat(%q) = %v, %v; want %v, error %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFrameBytes(t *testing.T) {
+	if got := Telephony.FrameBytes(FrameDuration); got != TwilioFrameBytes {
+		t.Errorf("Telephony.FrameBytes(20ms) = %d, want %d", got, TwilioFrameBytes)
+	}
+	pcm := Format{Codec: CodecPCM16, SampleRate: Rate16k}
+	if got := pcm.FrameBytes(10 * time.Millisecond); got != 320 {
+		t.Errorf("pcm_16000 FrameBytes(10ms) = %d, want 320", got)
+	}
+}
+
+func TestFramer(t *testing.T) {
+	f := NewFramer(4)
+	if frames := f.Push([]byte{1, 2, 3}); len(frames) != 0 {
+		t.Fatalf("Push of a partial frame returned %d frames", len(frames))
+	}
+	frames := f.Push([]byte{4, 5, 6, 7, 8, 9})
+	if len(frames) != 2 || !bytes.Equal(frames[0], []byte{1, 2, 3, 4}) || !bytes.Equal(frames[1], []byte{5, 6, 7, 8}) {
+		t.Fatalf("Push returned %v", frames)
+	}
+	if rest := f.Flush(); !bytes.Equal(rest, []byte{9}) {
+		t.Errorf("Flush = %v, want [9]", rest)
+	}
+	if rest := f.Flush(); len(rest) != 0 {
+		t.Errorf("second Flush = %v, want nothing", rest)
+	}
+}
+
+func TestFrames(t *testing.T) {
+	frames := Frames(make([]byte, 330), TwilioFrameBytes)
+	if len(frames) != 3 || len(frames[0]) != 160 || len(frames[2]) != 10 {
+		t.Errorf("Frames(330 bytes) gave %d frames", len(frames))
+	}
+	if frames := Frames(nil, TwilioFrameBytes); len(frames) != 0 {
+		t.Errorf("Frames(nil) gave %d frames", len(frames))
+	}
+}
+
+func TestTranscoderCarriesPartialSamples(t *testing.T) {
+	pcm := Format{Codec: CodecPCM16, SampleRate: Rate8k}
+	samples := []int16{1000, -2000, 3000, -4000}
+	data := PCM16ToBytes(samples)
+	tr := NewTranscoder(pcm, Telephony)
+	// Split in the middle of the second sample
+	out := append(tr.Process(data[:3]), tr.Process(data[3:])...)
+	if want := EncodeMuLaw(samples); !bytes.Equal(out, want) {
+		t.Errorf("transcoded %v, want %v", out, want)
+	}
+}
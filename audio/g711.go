@@ -0,0 +1,124 @@
+package audio
+
+// G.711 companding as specified by ITU-T and implemented in the reference
+// g711.c. Samples are signed 16-bit linear PCM.
+
+const (
+	muLawBias = 0x84
+	muLawClip = 32635
+)
+
+var aLawSegmentEnds = [8]int{0x1F, 0x3F, 0x7F, 0xFF, 0x1FF, 0x3FF, 0x7FF, 0xFFF}
+
+// MuLawEncode compresses one linear sample to mu-law.
+func MuLawEncode(sample int16) byte {
+	v := int(sample)
+	sign := 0
+	if v < 0 {
+		v = -v
+		sign = 0x80
+	}
+	if v > muLawClip {
+		v = muLawClip
+	}
+	v += muLawBias
+	exponent := 7
+	for mask := 0x4000; v&mask == 0 && exponent > 0; mask >>= 1 {
+		exponent--
+	}
+	mantissa := (v >> (exponent + 3)) & 0x0F
+	return ^byte(sign | exponent<<4 | mantissa)
+}
+
+// MuLawDecode expands one mu-law byte to a linear sample.
+func MuLawDecode(u byte) int16 {
+	u = ^u
+	exponent := int(u>>4) & 0x07
+	mantissa := int(u) & 0x0F
+	sample := ((mantissa << 3) + muLawBias) << exponent
+	sample -= muLawBias
+	if u&0x80 != 0 {
+		return int16(-sample)
+	}
+	return int16(sample)
+}
+
+// ALawEncode compresses one linear sample to A-law.
+func ALawEncode(sample int16) byte {
+	pcm := int(sample) >> 3
+	mask := 0xD5
+	if pcm < 0 {
+		mask = 0x55
+		pcm = -pcm - 1
+	}
+	segment := 0
+	for segment < len(aLawSegmentEnds) && pcm > aLawSegmentEnds[segment] {
+		segment++
+	}
+	if segment >= len(aLawSegmentEnds) {
+		return byte(0x7F ^ mask)
+	}
+	aval := segment << 4
+	if segment < 2 {
+		aval |= (pcm >> 1) & 0x0F
+	} else {
+		aval |= (pcm >> segment) & 0x0F
+	}
+	return byte(aval ^ mask)
+}
+
+// ALawDecode expands one A-law byte to a linear sample.
+func ALawDecode(a byte) int16 {
+	a ^= 0x55
+	t := int(a&0x0F) << 4
+	segment := int(a&0x70) >> 4
+	switch segment {
+	case 0:
+		t += 8
+	case 1:
+		t += 0x108
+	default:
+		t += 0x108
+		t <<= segment - 1
+	}
+	if a&0x80 != 0 {
+		return int16(t)
+	}
+	return int16(-t)
+}
+
+// DecodeMuLaw expands a mu-law buffer to linear samples.
+func DecodeMuLaw(data []byte) []int16 {
+	samples := make([]int16, len(data))
+	for i, b := range data {
+		samples[i] = MuLawDecode(b)
+	}
+	return samples
+}
+
+// EncodeMuLaw compresses linear samples to mu-law.
+func EncodeMuLaw(samples []int16) []byte {
+	data := make([]byte, len(samples))
+	for i, s := range samples {
+		data[i] = MuLawEncode(s)
+	}
+	return data
+}
+
+// DecodeALaw expands an A-law buffer to linear samples.
+func DecodeALaw(data []byte) []int16 {
+	samples := make([]int16, len(data))
+	for i, b := range data {
+		samples[i] = ALawDecode(b)
+	}
+	return samples
+}
+
+// EncodeALaw compresses linear samples to A-law.
+func EncodeALaw(samples []int16) []byte {
+	data := make([]byte, len(samples))
+	for i, s := range samples {
+		data[i] = ALawEncode(s)
+	}
+	return data
+}
@@ -0,0 +1,72 @@
+package audio
+
+import "testing"
+
+func TestMuLawRoundTrip(t *testing.T) {
+	// Every code decodes to a sample that encodes back to the same code,
+	// except negative zero (0x7F), which folds into positive zero (0xFF).
+	for code := 0; code < 256; code++ {
+		u := byte(code)
+		got := MuLawEncode(MuLawDecode(u))
+		if u == 0x7F {
+			u = 0xFF
+		}
+		if got != u {
+			t.Errorf("MuLawEncode(MuLawDecode(%#02x)) = %#02x", code, got)
+		}
+	}
+}
+
+func TestALawRoundTrip(t *testing.T) {
+	for code := 0; code < 256; code++ {
+		a := byte(code)
+		if got := ALawEncode(ALawDecode(a)); got != a {
+			t.Errorf("ALawEncode(ALawDecode(%#02x)) = %#02x", code, got)
+		}
+	}
+}
+
+func TestG711Values(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample int16
+		mulaw  byte
+		alaw   byte
+	}{
+		{"silence", 0, 0xFF, 0xD5},
+		{"positive full scale", 32767, 0x80, 0xAA},
+		{"negative full scale", -32768, 0x00, 0x2A},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MuLawEncode(tt.sample); got != tt.mulaw {
+				t.Errorf("MuLawEncode(%d) = %#02x, want %#02x", tt.sample, got, tt.mulaw)
+			}
+			if got := ALawEncode(tt.sample); got != tt.alaw {
+				t.Errorf("ALawEncode(%d) = %#02x, want %#02x", tt.sample, got, tt.alaw)
+			}
+		})
+	}
+}
+
+func TestG711Error(t *testing.T) {
+	// Companding keeps the error within a few percent of the sample.
+	for _, sample := range []int16{-30000, -4000, -500, -60, 60, 500, 4000, 30000} {
+		limit := abs(int(sample))/16 + 16
+		for name, decoded := range map[string]int16{
+			"mulaw": MuLawDecode(MuLawEncode(sample)),
+			"alaw":  ALawDecode(ALawEncode(sample)),
+		} {
+			if abs(int(decoded)-int(sample)) > limit {
+				t.Errorf("%s round trip of %d gave %d", name, sample, decoded)
+			}
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
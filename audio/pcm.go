@@ -0,0 +1,33 @@
+package audio
+
+import "encoding/binary"
+
+// PCM16FromBytes reads little-endian signed 16-bit samples. A trailing odd
+// byte is ignored.
+func PCM16FromBytes(data []byte) []int16 {
+	samples := make([]int16, len(data)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(data[i*2:]))
+	}
+	return samples
+}
+
+// PCM16ToBytes writes samples as little-endian signed 16-bit PCM.
+func PCM16ToBytes(samples []int16) []byte {
+	data := make([]byte, len(samples)*2)
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(data[i*2:], uint16(s))
+	}
+	return data
+}
+
+// clamp16 saturates v into the int16 range.
+func clamp16(v float64) int16 {
+	switch {
+	case v > 32767:
+		return 32767
+	case v < -32768:
+		return -32768
+	}
+	return int16(v)
+}
@@ -0,0 +1,113 @@
+package audio
+
+import "math"
+
+// Common sample rates used by telephony and TTS/STT providers.
+const (
+	Rate8k    = 8000
+	Rate16k   = 16000
+	Rate22k05 = 22050
+	Rate24k   = 24000
+	Rate44k1  = 44100
+	Rate48k   = 48000
+)
+
+// Resampler converts a stream of mono samples from one rate to another
+// using linear interpolation. When downsampling, a low-pass filter runs
+// first to keep energy above the new Nyquist frequency from aliasing.
+// State carries across calls to Process, so chunk boundaries are seamless.
+type Resampler struct {
+	from, to int
+	step     float64 // input samples advanced per output sample
+	pos      float64 // position of the next output sample in the pending input
+	prev     int16
+	hasPrev  bool
+	filters  []*biquad
+}
+
+func NewResampler(from, to int) *Resampler {
+	r := &Resampler{
+		from: from,
+		to:   to,
+		step: float64(from) / float64(to),
+	}
+	if to < from {
+		// Two cascaded stages give a steeper roll-off than one.
+		cutoff := 0.45 * float64(to)
+		r.filters = []*biquad{newLowPass(float64(from), cutoff), newLowPass(float64(from), cutoff)}
+	}
+	return r
+}
+
+// Process returns the resampled version of in.
+func (r *Resampler) Process(in []int16) []int16 {
+	if r.from == r.to || len(in) == 0 {
+		return append([]int16(nil), in...)
+	}
+	for _, f := range r.filters {
+		in = f.apply(in)
+	}
+
+	samples := in
+	if r.hasPrev {
+		samples = make([]int16, 0, len(in)+1)
+		samples = append(samples, r.prev)
+		samples = append(samples, in...)
+	}
+
+	out := make([]int16, 0, int(float64(len(samples))/r.step)+1)
+	for {
+		i := int(r.pos)
+		if i+1 >= len(samples) {
+			break
+		}
+		frac := r.pos - float64(i)
+		v := float64(samples[i])*(1-frac) + float64(samples[i+1])*frac
+		out = append(out, clamp16(math.Round(v)))
+		r.pos += r.step
+	}
+
+	// Keep the last sample so the next chunk can interpolate across the boundary.
+	r.pos -= float64(len(samples) - 1)
+	r.prev = samples[len(samples)-1]
+	r.hasPrev = true
+	return out
+}
+
+// Resample converts a complete buffer from one rate to another.
+func Resample(samples []int16, from, to int) []int16 {
+	return NewResampler(from, to).Process(samples)
+}
+
+// biquad is an RBJ low-pass filter section.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func newLowPass(sampleRate, cutoff float64) *biquad {
+	w0 := 2 * math.Pi * cutoff / sampleRate
+	cos := math.Cos(w0)
+	const q = math.Sqrt2 / 2 // Butterworth
+	alpha := math.Sin(w0) / (2 * q)
+	a0 := 1 + alpha
+	return &biquad{
+		b0: (1 - cos) / 2 / a0,
+		b1: (1 - cos) / a0,
+		b2: (1 - cos) / 2 / a0,
+		a1: -2 * cos / a0,
+		a2: (1 - alpha) / a0,
+	}
+}
+
+func (f *biquad) apply(in []int16) []int16 {
+	out := make([]int16, len(in))
+	for i, s := range in {
+		x := float64(s)
+		y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+		f.x2, f.x1 = f.x1, x
+		f.y2, f.y1 = f.y1, y
+		out[i] = clamp16(y)
+	}
+	return out
+}
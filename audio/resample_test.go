@@ -0,0 +1,63 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestResampleLength(t *testing.T) {
+	// The output is as long as the input scaled by the rate ratio, less the
+	// last sample or two that are held back to interpolate into the next chunk.
+	tests := []struct {
+		from, to int
+		in       int
+	}{
+		{Rate8k, Rate8k, 160},
+		{Rate8k, Rate16k, 160},
+		{Rate16k, Rate8k, 320},
+		{Rate24k, Rate8k, 480},
+		{Rate8k, Rate48k, 160},
+		{Rate44k1, Rate8k, 441},
+		{Rate22k05, Rate16k, 2205},
+		{Rate8k, Rate16k, 0},
+	}
+	for _, tt := range tests {
+		got := len(Resample(make([]int16, tt.in), tt.from, tt.to))
+		want := tt.in * tt.to / tt.from
+		if got > want || got < want-tt.to/tt.from-1 {
+			t.Errorf("Resample(%d samples, %d, %d) gave %d samples, want about %d", tt.in, tt.from, tt.to, got, want)
+		}
+	}
+}
+
+func TestResamplerChunked(t *testing.T) {
+	// A stream resampled chunk by chunk is as long as the whole buffer
+	// resampled at once, give or take the sample held back for the next chunk.
+	for _, rates := range [][2]int{{Rate8k, Rate16k}, {Rate24k, Rate8k}, {Rate22k05, Rate8k}} {
+		r := NewResampler(rates[0], rates[1])
+		total := 0
+		for i := 0; i < 50; i++ {
+			total += len(r.Process(make([]int16, rates[0]/50)))
+		}
+		want := rates[1]
+		if diff := total - want; diff < -2 || diff > 2 {
+			t.Errorf("%d -> %d over one second gave %d samples, want about %d", rates[0], rates[1], total, want)
+		}
+	}
+}
+
+func TestResampleKeepsTone(t *testing.T) {
+	// A 440 Hz tone survives 24 kHz -> 8 kHz with about the same amplitude.
+	in := make([]int16, Rate24k/10)
+	for i := range in {
+		in[i] = int16(10000 * math.Sin(2*math.Pi*440*float64(i)/Rate24k))
+	}
+	out := Resample(in, Rate24k, Rate8k)
+	peak := 0
+	for _, s := range out[len(out)/2:] {
+		peak = max(peak, abs(int(s)))
+	}
+	if peak < 9000 || peak > 11000 {
+		t.Errorf("peak after resampling is %d, want about 10000", peak)
+	}
+}
@@ -0,0 +1,173 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	wavFormatPCM        = 1
+	wavFormatALaw       = 6
+	wavFormatMuLaw      = 7
+	wavFormatExtensible = 0xFFFE
+)
+
+// WAV is a decoded RIFF/WAVE file.
+type WAV struct {
+	AudioFormat   uint16
+	Channels      int
+	SampleRate    int
+	BitsPerSample int
+	Data          []byte
+}
+
+// maxFmtChunk bounds the fmt chunk, which is 40 bytes at most in practice
+const maxFmtChunk = 1 << 10
+
+// ReadWAV parses a WAVE file holding PCM (8 or 16 bit), mu-law or A-law audio.
+// Chunk sizes come from the file and cannot be trusted, so memory only grows
+// with the bytes actually read, and a data chunk cut short keeps just the
+// samples it has.
+func ReadWAV(r io.Reader) (*WAV, error) {
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, fmt.Errorf("read wav header: %w", err)
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return nil, errors.New("not a RIFF/WAVE file")
+	}
+
+	wav := &WAV{}
+	haveFmt := false
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			if errors.Is(err, io.EOF) && haveFmt {
+				return nil, errors.New("wav has no data chunk")
+			}
+			return nil, fmt.Errorf("read wav chunk: %w", err)
+		}
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, errors.New("wav fmt chunk too short")
+			}
+			if size > maxFmtChunk {
+				return nil, fmt.Errorf("wav fmt chunk of %d bytes is too long", size)
+			}
+			body := make([]byte, size+size%2) // chunks are word aligned
+			if _, err := io.ReadFull(r, body); err != nil {
+				return nil, fmt.Errorf("read wav fmt chunk: %w", err)
+			}
+			wav.AudioFormat = binary.LittleEndian.Uint16(body[0:2])
+			wav.Channels = int(binary.LittleEndian.Uint16(body[2:4]))
+			wav.SampleRate = int(binary.LittleEndian.Uint32(body[4:8]))
+			wav.BitsPerSample = int(binary.LittleEndian.Uint16(body[14:16]))
+			if wav.AudioFormat == wavFormatExtensible && size >= 26 {
+				// The real format code is the first two bytes of the sub-format GUID
+				wav.AudioFormat = binary.LittleEndian.Uint16(body[24:26])
+			}
+			haveFmt = true
+		case "data":
+			if !haveFmt {
+				return nil, errors.New("wav data chunk before fmt chunk")
+			}
+			// Streaming writers leave the size at its maximum, so the data
+			// may well end before it says
+			data, err := io.ReadAll(io.LimitReader(r, size))
+			if err != nil {
+				return nil, fmt.Errorf("read wav data chunk: %w", err)
+			}
+			// A partial last sample would decode as noise
+			if blockAlign := wav.Channels * wav.BitsPerSample / 8; blockAlign > 1 {
+				data = data[:len(data)-len(data)%blockAlign]
+			}
+			wav.Data = data
+			return wav, nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return nil, fmt.Errorf("read wav %s chunk: %w", id, err)
+			}
+		}
+	}
+}
+
+// Samples returns the audio as mono linear 16-bit samples, averaging channels.
+func (w *WAV) Samples() ([]int16, error) {
+	var interleaved []int16
+	switch {
+	case w.AudioFormat == wavFormatPCM && w.BitsPerSample == 16:
+		interleaved = PCM16FromBytes(w.Data)
+	case w.AudioFormat == wavFormatPCM && w.BitsPerSample == 8:
+		interleaved = make([]int16, len(w.Data))
+		for i, b := range w.Data {
+			interleaved[i] = int16(int(b)-128) << 8
+		}
+	case w.AudioFormat == wavFormatMuLaw:
+		interleaved = DecodeMuLaw(w.Data)
+	case w.AudioFormat == wavFormatALaw:
+		interleaved = DecodeALaw(w.Data)
+	default:
+		return nil, fmt.Errorf("unsupported wav format %d with %d bits", w.AudioFormat, w.BitsPerSample)
+	}
+	if w.Channels <= 1 {
+		return interleaved, nil
+	}
+	mono := make([]int16, len(interleaved)/w.Channels)
+	for i := range mono {
+		sum := 0
+		for c := 0; c < w.Channels; c++ {
+			sum += int(interleaved[i*w.Channels+c])
+		}
+		mono[i] = int16(sum / w.Channels)
+	}
+	return mono, nil
+}
+
+// NewWAV wraps raw mono audio in the given format.
+func NewWAV(format Format, data []byte) *WAV {
+	wav := &WAV{
+		Channels:      1,
+		SampleRate:    format.SampleRate,
+		BitsPerSample: 8 * format.BytesPerSample(),
+		Data:          data,
+	}
+	switch format.Codec {
+	case CodecMuLaw:
+		wav.AudioFormat = wavFormatMuLaw
+	case CodecALaw:
+		wav.AudioFormat = wavFormatALaw
+	default:
+		wav.AudioFormat = wavFormatPCM
+	}
+	return wav
+}
+
+// WriteWAV writes w as a canonical 44-byte-header WAVE file.
+func WriteWAV(out io.Writer, w *WAV) error {
+	blockAlign := w.Channels * w.BitsPerSample / 8
+	header := make([]byte, 44)
+	copy(header[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], uint32(36+len(w.Data)))
+	copy(header[8:12], "WAVE")
+	copy(header[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(header[16:20], 16)
+	binary.LittleEndian.PutUint16(header[20:22], w.AudioFormat)
+	binary.LittleEndian.PutUint16(header[22:24], uint16(w.Channels))
+	binary.LittleEndian.PutUint32(header[24:28], uint32(w.SampleRate))
+	binary.LittleEndian.PutUint32(header[28:32], uint32(w.SampleRate*blockAlign))
+	binary.LittleEndian.PutUint16(header[32:34], uint16(blockAlign))
+	binary.LittleEndian.PutUint16(header[34:36], uint16(w.BitsPerSample))
+	copy(header[36:40], "data")
+	binary.LittleEndian.PutUint32(header[40:44], uint32(len(w.Data)))
+	if _, err := out.Write(header); err != nil {
+		return err
+	}
+	_, err := out.Write(w.Data)
+	return err
+}
@@ -0,0 +1,108 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestWAVRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		data   []byte
+	}{
+		{"mulaw", Telephony, []byte{0xFF, 0x80, 0x00, 0x7F}},
+		{"alaw", Format{Codec: CodecALaw, SampleRate: Rate8k}, []byte{0xD5, 0xAA}},
+		{"pcm", Format{Codec: CodecPCM16, SampleRate: Rate16k}, PCM16ToBytes([]int16{0, 100, -100})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteWAV(&buf, NewWAV(tt.format, tt.data)); err != nil {
+				t.Fatal(err)
+			}
+			wav, err := ReadWAV(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if wav.SampleRate != tt.format.SampleRate || wav.Channels != 1 || !bytes.Equal(wav.Data, tt.data) {
+				t.Errorf("read back %+v", wav)
+			}
+		})
+	}
+}
+
+// wavFile builds a WAVE file from raw chunks, with a correct RIFF size.
+func wavFile(chunks ...[]byte) []byte {
+	body := []byte("WAVE")
+	for _, c := range chunks {
+		body = append(body, c...)
+	}
+	out := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	return append(out, body...)
+}
+
+func chunk(id string, size uint32, body []byte) []byte {
+	out := append([]byte(id), binary.LittleEndian.AppendUint32(nil, size)...)
+	return append(out, body...)
+}
+
+func fmtChunk(format uint16, channels uint16, rate uint32, bits uint16) []byte {
+	body := binary.LittleEndian.AppendUint16(nil, format)
+	body = binary.LittleEndian.AppendUint16(body, channels)
+	body = binary.LittleEndian.AppendUint32(body, rate)
+	body = binary.LittleEndian.AppendUint32(body, rate*uint32(channels*bits/8))
+	body = binary.LittleEndian.AppendUint16(body, channels*bits/8)
+	body = binary.LittleEndian.AppendUint16(body, bits)
+	return chunk("fmt ", 16, body)
+}
+
+func TestReadWAV(t *testing.T) {
+	muLaw := fmtChunk(wavFormatMuLaw, 1, Rate8k, 8)
+	pcm := fmtChunk(wavFormatPCM, 1, Rate8k, 16)
+	tests := []struct {
+		name    string
+		file    []byte
+		want    []byte
+		wantErr bool
+	}{
+		{"skips other chunks", wavFile(muLaw, chunk("LIST", 3, []byte{1, 2, 3, 0}), chunk("data", 2, []byte{7, 8})), []byte{7, 8}, false},
+		{"truncated data keeps what was read", wavFile(muLaw, chunk("data", 100, []byte{1, 2, 3})), []byte{1, 2, 3}, false},
+		{"streaming size", wavFile(muLaw, chunk("data", 0xFFFFFFFF, []byte{1, 2})), []byte{1, 2}, false},
+		{"partial pcm sample dropped", wavFile(pcm, chunk("data", 100, []byte{1, 2, 3})), []byte{1, 2}, false},
+		{"huge fmt chunk", wavFile(chunk("fmt ", 0xFFFFFFF0, nil)), nil, true},
+		{"huge other chunk", wavFile(muLaw, chunk("LIST", 0xFFFFFFF0, []byte{1, 2})), nil, true},
+		{"no data chunk", wavFile(muLaw), nil, true},
+		{"data before fmt", wavFile(chunk("data", 2, []byte{1, 2})), nil, true},
+		{"not wave", []byte("RIFF\x04\x00\x00\x00AVI "), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wav, err := ReadWAV(bytes.NewReader(tt.file))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ReadWAV succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(wav.Data, tt.want) {
+				t.Errorf("data = %v, want %v", wav.Data, tt.want)
+			}
+		})
+	}
+}
+
+func TestWAVSamplesMixesChannels(t *testing.T) {
+	wav := &WAV{AudioFormat: wavFormatPCM, Channels: 2, BitsPerSample: 16, Data: PCM16ToBytes([]int16{100, 300, -100, -300})}
+	samples, err := wav.Samples()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 || samples[0] != 200 || samples[1] != -200 {
+		t.Errorf("Samples() = %v, want [200 -200]", samples)
+	}
+}
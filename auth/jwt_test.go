@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+var jwtSecret = []byte("test-secret")
+
+// rawJWT builds a token with any header, HMAC-signed with secret when it is
+// not nil.
+func rawJWT(t *testing.T, header map[string]string, claims Claims, secret []byte) string {
+	t.Helper()
+	h, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c) + "."
+	if secret != nil {
+		token += base64.RawURLEncoding.EncodeToString(sign(secret, strings.TrimSuffix(token, ".")))
+	}
+	return token
+}
+
+func TestVerifyJWT(t *testing.T) {
+	now := time.Now().Unix()
+	valid := Claims{Subject: "backend", Workspace: "acme", Scope: "calls sessions", ExpiresAt: now + 60}
+	signed := func(claims Claims) string {
+		token, err := SignJWT(jwtSecret, claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	validToken := signed(valid)
+	parts := strings.Split(validToken, ".")
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{"valid", validToken, ""},
+		{"expired", signed(Claims{Subject: "backend", ExpiresAt: now - 1}), "token expired"},
+		{"no expiry", signed(Claims{Subject: "backend"}), "token has no expiry"},
+		{"not valid yet", signed(Claims{Subject: "backend", ExpiresAt: now + 120, NotBefore: now + 60}), "not valid yet"},
+		{"other secret", func() string { token, _ := SignJWT([]byte("other"), valid); return token }(), "invalid token signature"},
+		{"tampered claims", parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"backend","scope":"admin","exp":`+jsonInt(now+60)+`}`)) + "." + parts[2], "invalid token signature"},
+		{"alg none", rawJWT(t, map[string]string{"alg": "none"}, valid, nil), "unsupported token algorithm"},
+		{"alg none signed", rawJWT(t, map[string]string{"alg": "none"}, valid, jwtSecret), "unsupported token algorithm"},
+		{"alg lowercase", rawJWT(t, map[string]string{"alg": "hs256"}, valid, jwtSecret), "unsupported token algorithm"},
+		// An RS256 token HMAC-signed with the secret is the key confusion attack
+		{"alg RS256", rawJWT(t, map[string]string{"alg": "RS256"}, valid, jwtSecret), "unsupported token algorithm"},
+		{"alg HS512", rawJWT(t, map[string]string{"alg": "HS512"}, valid, jwtSecret), "unsupported token algorithm"},
+		{"two segments", parts[0] + "." + parts[1], "malformed token"},
+		{"bad signature encoding", parts[0] + "." + parts[1] + ".!!", "malformed token signature"},
+		{"bad header", "!!." + parts[1] + "." + parts[2], "malformed token header"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := VerifyJWT(jwtSecret, tt.token)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("VerifyJWT() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.Subject != valid.Subject || claims.Workspace != valid.Workspace || claims.ExpiresAt != valid.ExpiresAt {
+				t.Errorf("VerifyJWT() = %+v, want %+v", claims, valid)
+			}
+		})
+	}
+}
+
+func TestClaimsAllScopes(t *testing.T) {
+	claims := Claims{Scope: "calls  sessions", Scopes: []string{"monitoring"}}
+	got := claims.AllScopes()
+	want := []string{"calls", "sessions", "monitoring"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("AllScopes() = %q, want %q", got, want)
+	}
+}
+
+func jsonInt(n int64) string {
+	data, _ := json.Marshal(n)
+	return string(data)
+}
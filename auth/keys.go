@@ -0,0 +1,237 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mrsingh-rishi/voice-bot/workspace"
+)
+
+// Scopes an API key or JWT can grant.
+const (
+	// ScopeCalls places outbound calls
+	ScopeCalls = "calls"
+	// ScopeSessions talks to agents over /chat, /browser and carrier streams
+	ScopeSessions = "sessions"
+	// ScopeMonitoring reads cache and provider health
+	ScopeMonitoring = "monitoring"
+	// ScopeAdmin manages API keys
+	ScopeAdmin = "admin"
+)
+
+// AllScopes lists every scope.
+var AllScopes = []string{ScopeCalls, ScopeSessions, ScopeMonitoring, ScopeAdmin}
+
+// keyPrefix starts every API key, so keys are told apart from JWTs and are
+// easy to spot in leaked text.
+const keyPrefix = "vb_"
+
+// ErrKeyNotFound is returned for unknown key IDs.
+var ErrKeyNotFound = errors.New("api key not found")
+
+// Key is a stored API key. Only a SHA-256 hash of the key is kept; the key
+// itself is shown once, when it is created. A key only reaches the
+// workspace it belongs to.
+type Key struct {
+	ID        string    `json:"id"`
+	Workspace string    `json:"workspace"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	Hash      string    `json:"hash,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// KeyStore holds API keys in memory, and in a JSON file when it has a path.
+type KeyStore struct {
+	path string
+
+	mu   sync.Mutex
+	keys map[string]Key
+}
+
+// NewKeyStore loads the keys stored at path. An empty path keeps keys in
+// memory only, so they are lost on restart.
+func NewKeyStore(path string) (*KeyStore, error) {
+	s := &KeyStore{path: path, keys: make(map[string]Key)}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read api keys: %w", err)
+	}
+	var keys []Key
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("parse api keys %s: %w", path, err)
+	}
+	for _, key := range keys {
+		// Keys from before workspaces belong to the default one
+		if key.Workspace == "" {
+			key.Workspace = workspace.DefaultID
+		}
+		s.keys[key.ID] = key
+	}
+	return s, nil
+}
+
+// Create makes a new key in a workspace and returns it along with the only
+// copy of its secret form, "vb_<id>_<secret>".
+func (s *KeyStore) Create(workspaceID string, name string, scopes []string) (Key, string, error) {
+	if workspaceID == "" {
+		return Key{}, "", fmt.Errorf("workspace is required")
+	}
+	if name == "" {
+		return Key{}, "", fmt.Errorf("key name is required")
+	}
+	if len(scopes) == 0 {
+		return Key{}, "", fmt.Errorf("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !validScope(scope) {
+			return Key{}, "", fmt.Errorf("unknown scope %q, expected one of %s", scope, strings.Join(AllScopes, ", "))
+		}
+	}
+	id, secret := randomHex(8), randomHex(24)
+	token := keyPrefix + id + "_" + secret
+	key := Key{
+		ID:        id,
+		Workspace: workspaceID,
+		Name:      name,
+		Scopes:    scopes,
+		Hash:      hashKey(token),
+		CreatedAt: time.Now().UTC(),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[id] = key
+	if err := s.save(); err != nil {
+		delete(s.keys, id)
+		return Key{}, "", err
+	}
+	return withoutHash(key), token, nil
+}
+
+// List returns the keys of a workspace, or of every workspace when
+// workspaceID is empty, oldest first and without hashes.
+func (s *KeyStore) List(workspaceID string) []Key {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		if workspaceID == "" || key.Workspace == workspaceID {
+			keys = append(keys, withoutHash(key))
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys
+}
+
+// Revoke deletes a key of a workspace, or of any workspace when
+// workspaceID is empty.
+func (s *KeyStore) Revoke(workspaceID string, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[id]
+	if !ok || (workspaceID != "" && key.Workspace != workspaceID) {
+		return ErrKeyNotFound
+	}
+	delete(s.keys, id)
+	if err := s.save(); err != nil {
+		s.keys[id] = key
+		return err
+	}
+	return nil
+}
+
+// Verify returns the key a token belongs to.
+func (s *KeyStore) Verify(token string) (Key, bool) {
+	rest, ok := strings.CutPrefix(token, keyPrefix)
+	if !ok {
+		return Key{}, false
+	}
+	id, _, ok := strings.Cut(rest, "_")
+	if !ok {
+		return Key{}, false
+	}
+	s.mu.Lock()
+	key, ok := s.keys[id]
+	s.mu.Unlock()
+	if !ok || subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashKey(token))) != 1 {
+		return Key{}, false
+	}
+	return withoutHash(key), true
+}
+
+// save writes the keys through a temporary file so a crash never leaves a
+// truncated file behind. The caller holds s.mu.
+func (s *KeyStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	keys := make([]Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".api-keys-*")
+	if err != nil {
+		return fmt.Errorf("save api keys: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("save api keys: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("save api keys: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("save api keys: %w", err)
+	}
+	return nil
+}
+
+func withoutHash(key Key) Key {
+	key.Hash = ""
+	return key
+}
+
+// hashKey hashes a key for storage. Keys are long random strings, so a
+// plain SHA-256 is enough; there is nothing to brute-force.
+func hashKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func validScope(scope string) bool {
+	for _, s := range AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKeyStoreCreate(t *testing.T) {
+	tests := []struct {
+		name      string
+		workspace string
+		key       string
+		scopes    []string
+		wantErr   string
+	}{
+		{"valid", "acme", "backend", []string{ScopeCalls, ScopeSessions}, ""},
+		{"no workspace", "", "backend", []string{ScopeCalls}, "workspace is required"},
+		{"no name", "acme", "", []string{ScopeCalls}, "key name is required"},
+		{"no scopes", "acme", "backend", nil, "at least one scope"},
+		{"unknown scope", "acme", "backend", []string{"everything"}, "unknown scope"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := NewKeyStore("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			key, token, err := store.Create(tt.workspace, tt.key, tt.scopes)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Create() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(token, keyPrefix+key.ID+"_") {
+				t.Errorf("token %q does not start with the key ID", token)
+			}
+			if key.Hash != "" {
+				t.Error("Create() returned the key's hash")
+			}
+		})
+	}
+}
+
+func TestKeyStoreVerify(t *testing.T) {
+	store, err := NewKeyStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, token, err := store.Create("acme", "backend", []string{ScopeCalls})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _, _ := strings.Cut(strings.TrimPrefix(token, keyPrefix), "_")
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{"valid", token, true},
+		{"wrong secret", keyPrefix + id + "_" + strings.Repeat("0", 48), false},
+		{"truncated secret", token[:len(token)-1], false},
+		{"unknown id", keyPrefix + "0000000000000000_" + strings.TrimPrefix(token, keyPrefix+id+"_"), false},
+		{"no secret", keyPrefix + id, false},
+		{"no prefix", strings.TrimPrefix(token, keyPrefix), false},
+		// The stored hash must never work as the key itself
+		{"hash as token", hashKey(token), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := store.Verify(tt.token)
+			if ok != tt.want {
+				t.Fatalf("Verify() ok = %v, want %v", ok, tt.want)
+			}
+			if ok && (got.ID != key.ID || got.Workspace != "acme" || got.Hash != "") {
+				t.Errorf("Verify() = %+v", got)
+			}
+		})
+	}
+}
+
+func TestKeyStoreRevoke(t *testing.T) {
+	store, err := NewKeyStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, token, err := store.Create("acme", "backend", []string{ScopeCalls})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Revoke("other", key.ID); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Revoke() from another workspace error = %v, want ErrKeyNotFound", err)
+	}
+	if _, ok := store.Verify(token); !ok {
+		t.Fatal("key revoked by another workspace")
+	}
+	if err := store.Revoke("acme", key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Verify(token); ok {
+		t.Error("revoked key still verifies")
+	}
+	if err := store.Revoke("acme", key.ID); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("second Revoke() error = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestKeyStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-keys.json")
+	store, err := NewKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, keptToken, err := store.Create("acme", "kept", []string{ScopeCalls})
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, revokedToken, err := store.Create("acme", "revoked", []string{ScopeCalls})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Revoke("", revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), keptToken) {
+		t.Error("key file holds the key itself instead of its hash")
+	}
+
+	reloaded, err := NewKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := reloaded.Verify(keptToken); !ok || got.ID != kept.ID {
+		t.Error("key lost on reload")
+	}
+	if _, ok := reloaded.Verify(revokedToken); ok {
+		t.Error("revoked key came back on reload")
+	}
+	if keys := reloaded.List("acme"); len(keys) != 1 {
+		t.Errorf("List() = %+v, want only the kept key", keys)
+	}
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mrsingh-rishi/voice-bot/workspace"
+)
+
+// Principal is who a request is authenticated as.
+type Principal struct {
+	// Subject is the API key ID, the JWT subject, or "admin" for the
+	// bootstrap admin key
+	Subject string
+	// Workspace is the only workspace the principal reaches; empty for the
+	// bootstrap admin key, which reaches all of them
+	Workspace string
+	Scopes    []string
+}
+
+// Has reports whether the principal was granted scope.
+func (p Principal) Has(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// principalKey is the fiber.Ctx local holding the request's Principal.
+const principalKey = "principal"
+
+// Authenticator accepts API keys from Keys, HS256 JWTs signed with
+// JWTSecret, and AdminKey, which has every scope and exists to create the
+// first keys. Each of them is optional.
+type Authenticator struct {
+	Keys      *KeyStore
+	JWTSecret []byte
+	AdminKey  string
+}
+
+// Authenticate resolves a bearer token to its principal.
+func (a *Authenticator) Authenticate(token string) (Principal, bool) {
+	if token == "" {
+		return Principal{}, false
+	}
+	if a.AdminKey != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.AdminKey)) == 1 {
+		return Principal{Subject: "admin", Scopes: AllScopes}, true
+	}
+	if strings.HasPrefix(token, keyPrefix) {
+		if a.Keys == nil {
+			return Principal{}, false
+		}
+		key, ok := a.Keys.Verify(token)
+		if !ok {
+			return Principal{}, false
+		}
+		return Principal{Subject: key.ID, Workspace: key.Workspace, Scopes: key.Scopes}, true
+	}
+	if len(a.JWTSecret) == 0 {
+		return Principal{}, false
+	}
+	claims, err := VerifyJWT(a.JWTSecret, token)
+	if err != nil {
+		return Principal{}, false
+	}
+	workspaceID := claims.Workspace
+	if workspaceID == "" {
+		workspaceID = workspace.DefaultID
+	}
+	return Principal{Subject: claims.Subject, Workspace: workspaceID, Scopes: claims.AllScopes()}, true
+}
+
+// Require rejects requests without a token granting scope. The token is
+// read from "Authorization: Bearer", the X-API-Key header, or a "token"
+// query parameter for websocket clients such as browsers that cannot set
+// headers on the upgrade.
+func (a *Authenticator) Require(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := requestToken(c)
+		if token == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "authentication required"})
+		}
+		principal, ok := a.Authenticate(token)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid credentials"})
+		}
+		if !principal.Has(scope) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "missing scope " + scope})
+		}
+		c.Locals(principalKey, principal)
+		return c.Next()
+	}
+}
+
+// PrincipalFrom returns the principal Require stored on the request.
+func PrincipalFrom(c *fiber.Ctx) (Principal, bool) {
+	p, ok := c.Locals(principalKey).(Principal)
+	return p, ok
+}
+
+func requestToken(c *fiber.Ctx) string {
+	if header := c.Get(fiber.HeaderAuthorization); header != "" {
+		if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	if token := c.Get("X-API-Key"); token != "" {
+		return token
+	}
+	return c.Query("token")
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/mrsingh-rishi/voice-bot/workspace"
+)
+
+func TestRequire(t *testing.T) {
+	keys, err := NewKeyStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, callsKey, err := keys.Create("acme", "backend", []string{ScopeCalls})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, monitoringKey, err := keys.Create("acme", "grafana", []string{ScopeMonitoring})
+	if err != nil {
+		t.Fatal(err)
+	}
+	callsJWT, err := SignJWT(jwtSecret, Claims{Subject: "browser", Scope: ScopeCalls, ExpiresAt: time.Now().Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiredJWT, err := SignJWT(jwtSecret, Claims{Subject: "browser", Scope: ScopeCalls, ExpiresAt: time.Now().Add(-time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator := &Authenticator{Keys: keys, JWTSecret: jwtSecret, AdminKey: "admin-key"}
+	var principal Principal
+	app := fiber.New()
+	app.Get("/calls", authenticator.Require(ScopeCalls), func(c *fiber.Ctx) error {
+		principal, _ = PrincipalFrom(c)
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	tests := []struct {
+		name          string
+		header        string
+		value         string
+		query         string
+		want          int
+		wantSubject   string
+		wantWorkspace string
+	}{
+		{"no token", "", "", "", fiber.StatusUnauthorized, "", ""},
+		{"bearer key", fiber.HeaderAuthorization, "Bearer " + callsKey, "", fiber.StatusNoContent, "", "acme"},
+		{"x-api-key", "X-API-Key", callsKey, "", fiber.StatusNoContent, "", "acme"},
+		{"query token", "", "", "?token=" + callsKey, fiber.StatusNoContent, "", "acme"},
+		{"missing scope", "X-API-Key", monitoringKey, "", fiber.StatusForbidden, "", ""},
+		{"unknown key", "X-API-Key", keyPrefix + "0000000000000000_secret", "", fiber.StatusUnauthorized, "", ""},
+		{"jwt", fiber.HeaderAuthorization, "Bearer " + callsJWT, "", fiber.StatusNoContent, "browser", workspace.DefaultID},
+		{"expired jwt", fiber.HeaderAuthorization, "Bearer " + expiredJWT, "", fiber.StatusUnauthorized, "", ""},
+		{"admin key", fiber.HeaderAuthorization, "Bearer admin-key", "", fiber.StatusNoContent, "admin", ""},
+		{"basic auth", fiber.HeaderAuthorization, "Basic YWRtaW46YWRtaW4=", "", fiber.StatusUnauthorized, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal = Principal{}
+			req := httptest.NewRequest(fiber.MethodGet, "/calls"+tt.query, nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if tt.want != fiber.StatusNoContent {
+				return
+			}
+			if tt.wantSubject != "" && principal.Subject != tt.wantSubject {
+				t.Errorf("subject = %q, want %q", principal.Subject, tt.wantSubject)
+			}
+			if principal.Workspace != tt.wantWorkspace {
+				t.Errorf("workspace = %q, want %q", principal.Workspace, tt.wantWorkspace)
+			}
+		})
+	}
+}
+
+func TestAuthenticateWithoutStores(t *testing.T) {
+	keys, err := NewKeyStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, key, err := keys.Create("acme", "backend", []string{ScopeCalls})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := SignJWT(jwtSecret, Claims{Subject: "browser", ExpiresAt: time.Now().Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Neither an API key nor a JWT gets in without its store configured
+	empty := &Authenticator{}
+	for _, tok := range []string{key, token, ""} {
+		if _, ok := empty.Authenticate(tok); ok {
+			t.Errorf("Authenticate(%q) succeeded without keys or a JWT secret", tok)
+		}
+	}
+}
+
+func TestPrincipalHas(t *testing.T) {
+	p := Principal{Scopes: []string{ScopeCalls, ScopeSessions}}
+	tests := []struct {
+		scope string
+		want  bool
+	}{
+		{ScopeCalls, true},
+		{ScopeSessions, true},
+		{ScopeAdmin, false},
+		{"", false},
+		{"call", false},
+	}
+	for _, tt := range tests {
+		if got := p.Has(tt.scope); got != tt.want {
+			t.Errorf("Has(%q) = %v, want %v", tt.scope, got, tt.want)
+		}
+	}
+}
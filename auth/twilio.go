@@ -0,0 +1,72 @@
+// Package auth guards the HTTP and websocket endpoints: Twilio request
+// signatures for Twilio's webhooks and media streams, and API keys or JWTs
+// with scopes for everything we call ourselves.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/mrsingh-rishi/voice-bot/logging"
+)
+
+// TwilioSignatureHeader carries Twilio's signature of a request.
+const TwilioSignatureHeader = "X-Twilio-Signature"
+
+// TwilioSignature computes the signature Twilio sends for a request to url
+// with the given POST parameters: HMAC-SHA1 of the URL followed by every
+// parameter name and value sorted by name, keyed with the auth token.
+func TwilioSignature(authToken string, url string, params map[string]string) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString(url)
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteString(params[name])
+	}
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(b.String()))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ValidTwilioSignature reports whether signature is Twilio's for the request.
+func ValidTwilioSignature(authToken string, url string, params map[string]string, signature string) bool {
+	if authToken == "" || signature == "" {
+		return false
+	}
+	expected := TwilioSignature(authToken, url, params)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// TwilioWebhook rejects requests that are not signed by Twilio. Twilio signs
+// the public URL it requested, so it is rebuilt from baseURL, e.g.
+// "https://bot.example.com" or "wss://bot.example.com", and the request's
+// path and query. POST form parameters are part of the signature.
+// authToken returns the token of the Twilio account the request is for,
+// or "" when there is none.
+func TwilioWebhook(baseURL string, authToken func(c *fiber.Ctx) string) fiber.Handler {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	return func(c *fiber.Ctx) error {
+		url := baseURL + c.OriginalURL()
+		params := map[string]string{}
+		if c.Method() == fiber.MethodPost {
+			c.Request().PostArgs().VisitAll(func(key, value []byte) {
+				params[string(key)] = string(value)
+			})
+		}
+		if !ValidTwilioSignature(authToken(c), url, params, c.Get(TwilioSignatureHeader)) {
+			logging.Logger("auth").Warn("Rejected unsigned or badly signed Twilio request", "path", c.Path())
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "invalid Twilio signature"})
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const twilioToken = "12345"
+
+// Twilio's documented example request.
+var (
+	twilioURL    = "https://mycompany.com/myapp.php?foo=1&bar=2"
+	twilioParams = map[string]string{
+		"CallSid": "CA1234567890ABCDE",
+		"Caller":  "+12349013030",
+		"Digits":  "1234",
+		"From":    "+12349013030",
+		"To":      "+18005551212",
+	}
+)
+
+func TestTwilioSignature(t *testing.T) {
+	if got, want := TwilioSignature(twilioToken, twilioURL, twilioParams), "0/KCTR6DLpKmkAf8muzZqo1nDgQ="; got != want {
+		t.Errorf("TwilioSignature() = %q, want %q", got, want)
+	}
+}
+
+func TestValidTwilioSignature(t *testing.T) {
+	signature := TwilioSignature(twilioToken, twilioURL, twilioParams)
+	changed := func(name, value string) map[string]string {
+		params := map[string]string{}
+		for k, v := range twilioParams {
+			params[k] = v
+		}
+		params[name] = value
+		return params
+	}
+	tests := []struct {
+		name      string
+		token     string
+		url       string
+		params    map[string]string
+		signature string
+		want      bool
+	}{
+		{"valid", twilioToken, twilioURL, twilioParams, signature, true},
+		{"other token", "54321", twilioURL, twilioParams, signature, false},
+		{"no token", "", twilioURL, twilioParams, signature, false},
+		{"no signature", twilioToken, twilioURL, twilioParams, "", false},
+		{"changed parameter", twilioToken, twilioURL, changed("Digits", "9999"), signature, false},
+		{"added parameter", twilioToken, twilioURL, changed("Extra", "1"), signature, false},
+		// The query string is signed as sent, so its order matters
+		{"reordered query", twilioToken, "https://mycompany.com/myapp.php?bar=2&foo=1", twilioParams, signature, false},
+		{"other host", twilioToken, "https://evil.example.com/myapp.php?foo=1&bar=2", twilioParams, signature, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidTwilioSignature(tt.token, tt.url, tt.params, tt.signature); got != tt.want {
+				t.Errorf("ValidTwilioSignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTwilioWebhook(t *testing.T) {
+	app := fiber.New()
+	app.Post("/myapp.php", TwilioWebhook("https://mycompany.com/", func(c *fiber.Ctx) string { return twilioToken }), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+	signature := TwilioSignature(twilioToken, twilioURL, twilioParams)
+
+	// Form parameters are sorted before signing, so their order in the
+	// body does not matter
+	inOrder := url.Values{}
+	for _, name := range []string{"CallSid", "Caller", "Digits", "From", "To"} {
+		inOrder.Set(name, twilioParams[name])
+	}
+	reversed := "To=%2B18005551212&From=%2B12349013030&Digits=1234&Caller=%2B12349013030&CallSid=CA1234567890ABCDE"
+
+	tests := []struct {
+		name      string
+		target    string
+		body      string
+		signature string
+		want      int
+	}{
+		{"valid", "/myapp.php?foo=1&bar=2", inOrder.Encode(), signature, fiber.StatusNoContent},
+		{"reordered form", "/myapp.php?foo=1&bar=2", reversed, signature, fiber.StatusNoContent},
+		{"reordered query", "/myapp.php?bar=2&foo=1", inOrder.Encode(), signature, fiber.StatusForbidden},
+		{"tampered form", "/myapp.php?foo=1&bar=2", strings.Replace(reversed, "Digits=1234", "Digits=9999", 1), signature, fiber.StatusForbidden},
+		{"unsigned", "/myapp.php?foo=1&bar=2", inOrder.Encode(), "", fiber.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodPost, tt.target, strings.NewReader(tt.body))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationForm)
+			if tt.signature != "" {
+				req.Header.Set(TwilioSignatureHeader, tt.signature)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
@@ -1,62 +1,159 @@
 package call
 
 import (
-	"encoding/base64"
-	"encoding/json"
+	"context"
 	"errors"
-	"log"
+	"io"
+	"log/slog"
 	"os"
-
-	"github.com/gofiber/websocket/v2"
+	"sync"
+	"time"
+
+	"github.com/mrsingh-rishi/voice-bot/agent"
+	"github.com/mrsingh-rishi/voice-bot/audio"
+	"github.com/mrsingh-rishi/voice-bot/dtmf"
+	"github.com/mrsingh-rishi/voice-bot/fallback"
+	"github.com/mrsingh-rishi/voice-bot/llm"
+	"github.com/mrsingh-rishi/voice-bot/metrics"
+	"github.com/mrsingh-rishi/voice-bot/normalize"
 	"github.com/mrsingh-rishi/voice-bot/output"
 	"github.com/mrsingh-rishi/voice-bot/stt"
+	"github.com/mrsingh-rishi/voice-bot/tracing"
+	"github.com/mrsingh-rishi/voice-bot/transport"
+	"github.com/mrsingh-rishi/voice-bot/tts"
+	"github.com/mrsingh-rishi/voice-bot/vad"
 	"github.com/mrsingh-rishi/voice-bot/workers"
 )
 
-type twilioEvent struct {
-	Event string `json:"event"` // "start", "media", "stop"
-	Media struct {
-		Payload string `json:"payload"` // base64 audio
-	} `json:"media"`
-	Start struct {
-		// Normally contains CallSid, streamSid, etc.
-		CallSid   string `json:"callSid"`
-		StreamSid string `json:"streamSid"`
-	} `json:"start"`
+// Dependencies are the process-wide resources shared by every call.
+type Dependencies struct {
+	// Fillers holds pre-synthesized filler clips; nil means no fillers are played
+	Fillers *tts.FillerCache
+	// PhraseCache serves repeated TTS phrases; nil disables caching
+	PhraseCache *tts.PhraseCache
+	// Breakers track provider health across calls; nil disables circuit breaking
+	Breakers *fallback.Registry
+	// Endpoints override the vendor API roots, e.g. to run against the fakes
+	// in package simulate
+	Endpoints Endpoints
+	// Credentials are the vendor API keys of the call's workspace; the ones
+	// not set are read from the environment
+	Credentials Credentials
+	// Workspace names the call's workspace in its logs
+	Workspace string
+}
+
+// Credentials are vendor API keys.
+type Credentials struct {
+	Deepgram   string
+	OpenAI     string
+	ElevenLabs string
+}
+
+// withEnv fills the keys that are not set from the environment.
+func (c Credentials) withEnv() Credentials {
+	if c.Deepgram == "" {
+		c.Deepgram = os.Getenv("DEEPGRAM_API_KEY")
+	}
+	if c.OpenAI == "" {
+		c.OpenAI = os.Getenv("OPEN_AI_API_KEY")
+	}
+	if c.ElevenLabs == "" {
+		c.ElevenLabs = os.Getenv("ELEVEN_LABS_API_KEY")
+	}
+	return c
+}
+
+// Endpoints are vendor API roots; empty fields use the real vendors.
+type Endpoints struct {
+	// Deepgram replaces the default listen URL; agents with their own STT
+	// endpoint keep it
+	Deepgram   string
+	OpenAI     string
+	ElevenLabs string
 }
 
 type Call struct {
 	streamSid            string
-	ws                   *websocket.Conn
+	transport            transport.Transport
+	inbound              *audio.Transcoder // Converts the caller's audio to telephony for the VAD and Deepgram; set once the stream starts
+	Agent                *agent.Config
+	Variables            map[string]string // Passed to POST /call when the call was created
 	AgentWorker          *workers.AgentWorker
 	AgentResponseWorker  *workers.AgentResponseWorker
 	FillerResponseWorker *workers.FillerResponseWorker
-	OutputWorker         *output.TwilioOutput
+	OutputWorker         *output.StreamOutput
 	StreamingChannel     chan string
 	OutputChannel        chan string
-	TranscriptionChannel chan string
+	FillerChannel        chan output.Filler
+	TranscriptionChannel chan stt.Transcript
 	DeepgramClient       *stt.DeepgramClient
 	AudioChannel         chan []byte
+	VAD                  *vad.Detector   // Detects caller speech locally, without waiting for Deepgram
+	Keypad               *dtmf.Collector // Nil when the agent does not take keypad input
+	keypadResults        chan dtmf.Result
 	done                 chan struct{} // Signal channel for graceful shutdown
+	received             chan struct{} // Closed once the receive loop has returned
+
+	// OnStart, if set, is called once the far end has started the stream
+	OnStart func(start transport.Start)
+
+	mu sync.Mutex
+	// callerSpeaking, lastSpeechStart and lastSpeechEnd track the caller's
+	// turns as seen by the VAD
+	callerSpeaking  bool
+	lastSpeechStart time.Time
+	lastSpeechEnd   time.Time
+	// ending is set once the call is being wrapped up; barge-in is ignored
+	ending bool
+	// outcome is why the call was ended on our side; empty while the far
+	// end is the one to hang up
+	outcome    string
+	state      State
+	stateSince time.Time
+	// awaitingReply is set from the start of a turn until the first audio of
+	// the reply is sent, so a filler played meanwhile does not end the turn
+	awaitingReply bool
+	// language is what the agent currently speaks
+	language string
+	// secrets holds sensitive keypad entries by ref
+	secrets secretStore
+	// turn times the reply to the caller's latest turn
+	turn *metrics.Turn
+	// trace has a span for the call and one for each of its turns
+	trace *tracing.Call
+	// ids tag the records of every logger of the call
+	ids         *callIDs
+	log         *slog.Logger
+	cleanupOnce sync.Once
 }
 
-func NewCall(ws *websocket.Conn) (*Call, error) {
-	deepgramApiKey := os.Getenv("DEEPGRAM_API_KEY")
-	openaiApiKey := os.Getenv("OPEN_AI_API_KEY")
-	elevenLabsApiKey := os.Getenv("ELEVEN_LABS_API_KEY")
+func NewCall(t transport.Transport, agentConfig *agent.Config, variables map[string]string, deps Dependencies) (*Call, error) {
+	if agentConfig == nil {
+		agentConfig = agent.Default()
+	}
+	fillers := deps.Fillers
+	if fillers == nil {
+		// No pre-synthesized clips means no fillers are played
+		fillers = tts.NewFillerCache(nil)
+	}
+	credentials := deps.Credentials.withEnv()
+	deepgramApiKey := credentials.Deepgram
+	openaiApiKey := credentials.OpenAI
+	elevenLabsApiKey := credentials.ElevenLabs
 
 	if deepgramApiKey == "" || openaiApiKey == "" || elevenLabsApiKey == "" {
-		return nil, errors.New("missing required environment variables")
+		return nil, errors.New("missing required vendor API keys")
 	}
 
 	// streamingChannel: AgentWorker output -> AgentResponseWorker input
 	streamingChannel := make(chan string, 10)
 	// transcriptionChannel: DeepgramClient output -> AgentWorker input
-	transcriptionChannel := make(chan string)
+	transcriptionChannel := make(chan stt.Transcript)
 	// fillerResponseInputChannel: DeepgramClient output -> FillerResponseWorker input
 	fillerResponseInputChannel := make(chan string)
-	// fillerResponseOutputChannel: FillerResponseWorker output (not used in this Call struct)
-	fillerResponseOutputChannel := make(chan string)
+	// fillerResponseOutputChannel: FillerResponseWorker output -> OutputWorker filler input
+	fillerResponseOutputChannel := make(chan output.Filler, 1)
 	// outputChannel: AgentResponseWorker output -> OutputWorker input
 	outputChannel := make(chan string)
 	// audioChannel: StartRecievingAudio output -> DeepgramClient input
@@ -64,40 +161,122 @@ func NewCall(ws *websocket.Conn) (*Call, error) {
 	// done: signal channel for graceful shutdown
 	done := make(chan struct{})
 
-	deepgramClient, err1 := stt.NewDeepgramClient(deepgramApiKey, transcriptionChannel, fillerResponseInputChannel)
+	sttChain := agentConfig.STTChain(variables)
+	if deps.Endpoints.Deepgram != "" {
+		for i := range sttChain {
+			if sttChain[i].Endpoint == "" || sttChain[i].Endpoint == stt.DefaultDeepgramEndpoint {
+				sttChain[i].Endpoint = deps.Endpoints.Deepgram
+			}
+		}
+	}
+	trace := tracing.StartCall(agentConfig.ID, deps.Workspace)
+	ids := &callIDs{workspace: deps.Workspace, agent: agentConfig.ID}
+	deepgramClient, err1 := stt.NewDeepgramClient(trace.Context(context.Background()), deepgramApiKey, sttChain, deps.Breakers, audio.Telephony, transcriptionChannel, fillerResponseInputChannel)
 	if err1 != nil {
+		trace.End(metrics.OutcomeFailed, err1)
 		return nil, err1
 	}
-	agentWorker, err2 := workers.NewAgentWorker(openaiApiKey, "gpt-4o-mini", streamingChannel, transcriptionChannel)
+	agentWorker, err2 := newAgentWorker(agentConfig, openaiApiKey, deps, streamingChannel, transcriptionChannel)
 	if err2 != nil {
+		trace.End(metrics.OutcomeFailed, err2)
 		return nil, err2
 	}
-	log.Println("Agent worker created")
-	agentResponseWorker, err3 := workers.NewAgentResponseWorker(elevenLabsApiKey, "cjVigY5qzO86Huf0OWal", "eleven_multilingual_v1", streamingChannel, outputChannel)
+	deepgramClient.Log = callLogger(ids, "stt")
+	agentWorker.Log = callLogger(ids, "llm")
+	agentWorker.OpenAIClient.Log = agentWorker.Log
+	language := agentConfig.DefaultLanguage()
+	voice := agentConfig.VoiceFor(language)
+	normalizer := normalize.New(voice.Locale, agentConfig.Pronunciations)
+	agentResponseWorker, err3 := workers.NewAgentResponseWorker(elevenLabsApiKey, voice.VoiceID, voice.Settings, normalizer, deps.PhraseCache, streamingChannel, outputChannel)
 	if err3 != nil {
+		trace.End(metrics.OutcomeFailed, err3)
 		return nil, err3
 	}
-	log.Println("Agent response worker created")
-	fillerResponseWorker, err4 := workers.NewFillerResponseWorker(openaiApiKey, "gpt-4o-mini", fillerResponseOutputChannel, fillerResponseInputChannel)
+	if deps.Endpoints.ElevenLabs != "" {
+		agentResponseWorker.TTSClient.BaseURL = deps.Endpoints.ElevenLabs
+	}
+	agentResponseWorker.UseStreaming = agentConfig.TTSStreaming
+	agentResponseWorker.Presets = agentConfig.VoicePresets
+	agentResponseWorker.Fallbacks = ttsFallbacks(agentConfig, openaiApiKey)
+	agentResponseWorker.Breakers = deps.Breakers
+	agentResponseWorker.TTSTimeout = agentConfig.TTSTimeout()
+	agentResponseWorker.FallbackMessage = agentConfig.FallbackMessage
+	agentResponseWorker.Log = callLogger(ids, "tts")
+	fillerThreshold := time.Duration(agentConfig.FillerThresholdMs) * time.Millisecond
+	fillerResponseWorker, err4 := workers.NewFillerResponseWorker(fillers, voice.VoiceID, fillerThreshold, fillerResponseOutputChannel, fillerResponseInputChannel)
 	if err4 != nil {
+		trace.End(metrics.OutcomeFailed, err4)
 		return nil, err4
 	}
-	log.Println("Filler response worker created")
 
-	return &Call{
+	c := &Call{
 		streamSid:            "",
-		ws:                   ws,
+		transport:            t,
+		Agent:                agentConfig,
+		Variables:            variables,
 		AgentWorker:          agentWorker,
 		AgentResponseWorker:  agentResponseWorker,
 		FillerResponseWorker: fillerResponseWorker,
 		OutputWorker:         nil,
 		StreamingChannel:     streamingChannel,
 		OutputChannel:        outputChannel,
+		FillerChannel:        fillerResponseOutputChannel,
 		TranscriptionChannel: transcriptionChannel,
 		DeepgramClient:       deepgramClient,
 		AudioChannel:         audioChannel,
+		VAD:                  vad.New(agentConfig.VADConfig()),
 		done:                 done,
-	}, nil
+		received:             make(chan struct{}),
+		state:                StateListening,
+		language:             language,
+		stateSince:           time.Now(),
+		turn:                 metrics.NewTurn(),
+		trace:                trace,
+		ids:                  ids,
+		log:                  callLogger(ids, "call"),
+	}
+	deepgramClient.OnHealthChange = c.onSTTHealth
+	agentWorker.ShouldReply = c.shouldReply
+	agentWorker.OnTurnStart = c.onTurnStart
+	agentWorker.OnTurnEnd = c.onTurnEnd
+	agentWorker.OpenAIClient.OnFirstToken = c.turn.FirstToken
+	agentWorker.OpenAIClient.OnSentence = c.turn.FirstSentence
+	agentWorker.TurnContext = c.trace.Context
+	agentResponseWorker.TurnContext = c.trace.Context
+	agentResponseWorker.OnFirstAudio = c.turn.FirstAudio
+	if agentConfig.DTMF != nil {
+		c.keypadResults = make(chan dtmf.Result, 4)
+		c.Keypad = dtmf.NewCollector(agentConfig.DTMF.KeypadOptions(), c.keypadResults)
+		if err := c.registerKeypadTools(); err != nil {
+			trace.End(metrics.OutcomeFailed, err)
+			return nil, err
+		}
+	}
+	if agentConfig.IVRNavigation {
+		if err := c.registerIVRTools(); err != nil {
+			trace.End(metrics.OutcomeFailed, err)
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// newAgentWorker creates the LLM side of a conversation with the agent's
+// model, fallbacks and timeouts.
+func newAgentWorker(agentConfig *agent.Config, openaiApiKey string, deps Dependencies, streamingChannel chan<- string, transcriptionChannel <-chan stt.Transcript) (*workers.AgentWorker, error) {
+	agentWorker, err := workers.NewAgentWorker(openaiApiKey, agentConfig.Model, agentConfig.Instructions(), streamingChannel, transcriptionChannel)
+	if err != nil {
+		return nil, err
+	}
+	if deps.Endpoints.OpenAI != "" {
+		agentWorker.OpenAIClient.Client = llm.NewProvider(openaiApiKey, deps.Endpoints.OpenAI, agentConfig.Model).Client
+	}
+	agentWorker.OpenAIClient.Fallbacks = llmFallbacks(agentConfig, openaiApiKey)
+	agentWorker.OpenAIClient.Breakers = deps.Breakers
+	agentWorker.OpenAIClient.FirstTokenTimeout = agentConfig.LLMTimeout()
+	agentWorker.OpenAIClient.FallbackMessage = agentConfig.FallbackMessage
+	agentWorker.HidePIIFromModel = agentConfig.HidePIIFromModel
+	return agentWorker, nil
 }
 
 func (c *Call) CreateOutputWorker() error {
@@ -106,12 +285,16 @@ func (c *Call) CreateOutputWorker() error {
 		return errors.New("streamSid is empty")
 	}
 
-	outputWorker, err := output.NewTwilioOutput(c.streamSid, c.ws, c.OutputChannel)
+	outputWorker, err := output.NewStreamOutput(c.transport, c.OutputChannel, c.FillerChannel)
 	if err != nil {
 		c.CleanupResources()
 		return err
 	}
 
+	outputWorker.Log = callLogger(c.ids, "output")
+	outputWorker.OnPlaybackStart = c.onPlaybackStart
+	outputWorker.OnMediaSent = c.onMediaSent
+	outputWorker.OnPlaybackDone = c.onPlaybackDone
 	c.OutputWorker = outputWorker
 	return nil
 }
@@ -122,6 +305,10 @@ func (c *Call) StartOutputWorker(){
 
 // CleanupResources gracefully releases all resources associated with the Call instance.
 func (c *Call) CleanupResources() {
+	c.cleanupOnce.Do(c.cleanupResources)
+}
+
+func (c *Call) cleanupResources() {
 	// Signal all goroutines to stop first
 	if c.done != nil {
 		select {
@@ -137,6 +324,14 @@ func (c *Call) CleanupResources() {
 		c.OutputWorker.Stop()
 	}
 
+	if c.FillerResponseWorker != nil {
+		c.FillerResponseWorker.Stop()
+	}
+
+	if c.Keypad != nil {
+		c.Keypad.Stop()
+	}
+
 	if c.AgentResponseWorker != nil {
 		c.AgentResponseWorker.Stop()
 	}
@@ -145,55 +340,42 @@ func (c *Call) CleanupResources() {
 		c.AgentWorker.Stop()
 	}
 
-	// Close Deepgram client before closing channels
+	// Deepgram flushes its final results on Close. Nothing answers them once
+	// the agent is stopped, so they are drained here instead of blocking
+	// Close until it times out.
 	if c.DeepgramClient != nil {
+		stop := make(chan struct{})
+		drained := make(chan struct{})
+		go c.drainTranscripts(stop, drained)
 		c.DeepgramClient.Close()
+		close(stop)
+		<-drained
 	}
 
-	// Close channels safely
-	if c.StreamingChannel != nil {
-		select {
-		case <-c.StreamingChannel:
-			// Channel already closed
-		default:
-			close(c.StreamingChannel)
-		}
-	}
-	if c.OutputChannel != nil {
-		select {
-		case <-c.OutputChannel:
-			// Channel already closed
-		default:
-			close(c.OutputChannel)
-		}
-	}
-	if c.TranscriptionChannel != nil {
-		select {
-		case <-c.TranscriptionChannel:
-			// Channel already closed
-		default:
-			close(c.TranscriptionChannel)
-		}
-	}
-	if c.AudioChannel != nil {
-		select {
-		case <-c.AudioChannel:
-			// Channel already closed
-		default:
-			close(c.AudioChannel)
-		}
-	}
+	// The data channels are left open: their senders may still be running,
+	// and each of them gives up once done is closed or its context ends.
 
-	// Close WebSocket connection last
-	if c.ws != nil {
-		c.ws.Close()
+	// Close the transport last
+	if c.transport != nil {
+		c.transport.Close()
 	}
+	c.trace.End(c.Outcome(), nil)
 }
 
-// closeChannelSafely closes a channel if it is not nil.
-func closeChannelSafely(ch chan string) {
-	if ch != nil {
-		close(ch)
+// drainTranscripts takes the transcripts Deepgram delivers while it closes,
+// logging the caller's last words, until stop is closed. TranscriptionChannel
+// itself is never closed: the keypad also sends on it, and its readers stop
+// with their contexts.
+func (c *Call) drainTranscripts(stop <-chan struct{}, drained chan<- struct{}) {
+	defer close(drained)
+	for {
+		select {
+		case <-stop:
+			return
+		case transcript := <-c.TranscriptionChannel:
+			c.log.Info("Transcript after the call ended", "text", transcript.Text)
+		case <-c.DeepgramClient.TranscriptionChannel2:
+		}
 	}
 }
 
@@ -210,66 +392,135 @@ func (c *Call) StartRecievingAudio(audioChannel chan []byte) {
 		// case <-c.done:
 		// 	return
 		// default:
-		// 	if c.ws == nil {
+		// 	if c.transport == nil {
 		// 		log.Println("WebSocket connection is nil")
 		// 		return
 		// 	}
 
-		_, msg, err := c.ws.ReadMessage()
+		ev, err := c.transport.Receive()
 		if err != nil {
-			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
-				log.Println("WebSocket closed normally:", err)
+			if err == io.EOF {
+				c.log.Info("Stream closed normally")
 			} else {
-				log.Printf("WebSocket read error: %v", err)
+				c.log.Error("Stream read error", "error", err)
 			}
 			return
 		}
 
-		var ev twilioEvent
-		if err := json.Unmarshal(msg, &ev); err != nil {
-			log.Printf("JSON unmarshal error: %v", err)
-			continue
-		}
-
-		switch ev.Event {
-		case "start":
-			log.Printf("Stream started: CallSid=%s, StreamSid=%s", ev.Start.CallSid, ev.Start.StreamSid)
-			c.SetStreamSid(ev.Start.StreamSid)
+		switch ev.Type {
+		case transport.EventStart:
+			c.ids.started(ev.Start.CallSid, ev.Start.StreamID)
+			c.trace.Started(ev.Start.CallSid, ev.Start.StreamID)
+			if traceID := c.trace.TraceID(); traceID != "" {
+				c.log.Info("Stream started", "trace_id", traceID)
+			} else {
+				c.log.Info("Stream started")
+			}
+			if c.OnStart != nil {
+				c.OnStart(ev.Start)
+			}
+			// Carriers that negotiate the codec only know it from now on
+			c.inbound = audio.NewTranscoder(c.transport.Format(), audio.Telephony)
+			c.SetStreamSid(ev.Start.StreamID)
 			c.StartOutputWorker()
 			c.SendCallOpeningMessage()
-			log.Printf("Call opening message sent")
+			c.log.Debug("Call opening message sent")
 
-		case "media":
-			chunk, err := base64.StdEncoding.DecodeString(ev.Media.Payload)
-			if err != nil {
-				log.Printf("Base64 decode error: %v", err)
+		case transport.EventAudio:
+			if c.inbound == nil {
+				metrics.DroppedAudioChunks.WithLabelValues(metrics.DropNotStarted).Inc()
 				continue
 			}
-			audioChannel <- chunk
-			// select {
-			// case audioChannel <- chunk:
-			// 	// Successfully sent chunk
-			// case <-c.done:
-			// 	return
-			// default:
-			// 	log.Println("Audio channel is full, dropping chunk")
-			// }
-
-		case "stop":
-			log.Println("Stream stopped")
-			return
+			chunk := c.inbound.Process(ev.Audio)
+			if len(chunk) == 0 {
+				continue
+			}
+			c.detectSpeech(chunk)
+			select {
+			case audioChannel <- chunk:
+			case <-c.done:
+				return
+			}
 
-		default:
-			log.Printf("Unknown event: %s", ev.Event)
+		case transport.EventMark:
+			if c.OutputWorker != nil {
+				c.OutputWorker.MarkReceived(ev.Mark)
+			}
+
+		case transport.EventDTMF:
+			c.handleDTMF(ev.Digit)
+
+		case transport.EventStop:
+			c.log.Info("Stream stopped")
+			return
 		}
 		// }
 	}
 }
 
+// detectSpeech runs the local VAD over one mu-law chunk from the caller.
+func (c *Call) detectSpeech(chunk []byte) {
+	for _, ev := range c.VAD.Process(audio.DecodeMuLaw(chunk)) {
+		c.handleVADEvent(ev)
+	}
+}
+
+func (c *Call) handleVADEvent(ev vad.Event) {
+	now := time.Now()
+	c.mu.Lock()
+	switch ev.Type {
+	case vad.SpeechStart:
+		c.callerSpeaking = true
+		c.lastSpeechStart = now
+	case vad.SpeechEnd:
+		c.callerSpeaking = false
+		c.lastSpeechEnd = now
+	}
+	c.mu.Unlock()
+
+	if ev.Type == vad.SpeechStart && c.Agent.Interruptible && !c.isEnding() && c.OutputWorker != nil && c.OutputWorker.Speaking() {
+		c.Interrupt()
+	}
+}
+
+// onSTTHealth reacts to the Deepgram connection dropping for good: the agent
+// can no longer hear the caller, so it apologizes and hangs up.
+func (c *Call) onSTTHealth(health stt.Health) {
+	if health != stt.HealthFailed || c.isEnding() {
+		return
+	}
+	c.log.Error("Speech recognition could not recover, ending call")
+	go c.endCall(metrics.OutcomeSTTFailure, c.Agent.STTFailureMessage)
+}
+
+// CallerSpeaking reports whether the VAD currently hears the caller.
+func (c *Call) CallerSpeaking() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.callerSpeaking
+}
+
+// Interrupt stops the agent mid-reply: the answer being generated is
+// abandoned, sentences not yet synthesized are dropped, and the far end
+// discards the audio it has buffered.
+func (c *Call) Interrupt() {
+	c.log.Info("Caller barged in, interrupting agent")
+	metrics.Interruptions.Inc()
+	c.mu.Lock()
+	c.awaitingReply = false
+	c.mu.Unlock()
+	c.setState(StateInterrupted)
+	c.AgentWorker.Interrupt()
+	c.AgentResponseWorker.Interrupt()
+	if c.OutputWorker != nil {
+		c.OutputWorker.Clear()
+	}
+}
+
 func (c *Call) Start() {
 	// Start the agent worker
 	c.AgentWorker.Start()
-	log.Printf("Agent worker started")
+	c.log.Debug("Agent worker started")
 
 	// Start the agent response worker
 	c.AgentResponseWorker.Start()
@@ -278,20 +529,31 @@ func (c *Call) Start() {
 
 	// Start receiving audio in a separate goroutine
 	go func() {
+		defer close(c.received)
 		c.StartRecievingAudio(c.AudioChannel)
-		log.Printf("Started receiving audio")
+		c.log.Debug("Stopped receiving audio")
 	}()
 
 	// Start sending audio to Deepgram in a separate goroutine
 	go func() {
 		c.DeepgramClient.SendAudio(c.AudioChannel)
-		log.Printf("Started sending audio to Deepgram")
+		c.log.Debug("Stopped sending audio to Deepgram")
 	}()
 
-	// Wait for done signal
+	go c.monitorInactivity()
+	if c.Keypad != nil {
+		go c.forwardKeypad()
+	}
+
+	// Wait for done signal, then for the receive loop to let go of the
+	// transport: the server may reuse the connection once we return
 	<-c.done
+	<-c.received
 }
 
 func (c *Call) SendCallOpeningMessage(){
-	c.StreamingChannel <- "Hello, how can I help you today?"
+	if c.Agent.Greeting == "" {
+		return
+	}
+	c.say(c.Agent.Greeting)
 }
@@ -0,0 +1,282 @@
+package call
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mrsingh-rishi/voice-bot/agent"
+	"github.com/mrsingh-rishi/voice-bot/dtmf"
+	"github.com/mrsingh-rishi/voice-bot/normalize"
+	"github.com/mrsingh-rishi/voice-bot/stt"
+	"github.com/mrsingh-rishi/voice-bot/tts"
+	"github.com/mrsingh-rishi/voice-bot/workers"
+)
+
+// endOfTurn follows the last sentence of a reply on the chat's streaming
+// channel, so the turn ends after every sentence was reported.
+const endOfTurn = "__END_OF_TURN__"
+
+// Chat event types.
+const (
+	ChatSentence = "sentence"
+	ChatToolCall = "tool_call"
+	ChatKeypad   = "keypad"
+	ChatTurnEnd  = "turn_end"
+)
+
+// ChatEvent is something the agent did during a chat turn.
+type ChatEvent struct {
+	Type string `json:"type"`
+	// Text is the sentence as the LLM produced it, or the keys pressed
+	Text string `json:"text,omitempty"`
+	// Speakable is the sentence exactly as it would be sent to TTS, after
+	// the delivery tag is stripped and the text normalized
+	Speakable string `json:"speakable,omitempty"`
+	// Preset is the delivery tag the sentence selected, if any
+	Preset    string `json:"preset,omitempty"`
+	Tool      string `json:"tool,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	Result    string `json:"result,omitempty"`
+	// ElapsedMs is the time since the turn started; for tool calls, the
+	// time the tool took
+	ElapsedMs int64 `json:"elapsed_ms"`
+}
+
+// Chat is a text-only conversation with an agent. Typed messages take the
+// place of transcripts and sentences are reported instead of synthesized;
+// the AgentWorker, tools and history are the same a call uses.
+type Chat struct {
+	Agent       *agent.Config
+	AgentWorker *workers.AgentWorker
+	// Keypad is nil when the agent does not take keypad input
+	Keypad *dtmf.Collector
+
+	events        chan ChatEvent
+	streaming     chan string
+	transcripts   chan stt.Transcript
+	keypadResults chan dtmf.Result
+	normalizer    *normalize.Normalizer
+	done          chan struct{}
+	closeOnce     sync.Once
+
+	mu        sync.Mutex
+	turnStart time.Time
+	// pending counts turns whose reply has not ended yet
+	pending int
+	// secrets holds sensitive keypad entries by ref
+	secrets secretStore
+}
+
+func NewChat(agentConfig *agent.Config, deps Dependencies) (*Chat, error) {
+	if agentConfig == nil {
+		agentConfig = agent.Default()
+	}
+	openaiApiKey := deps.Credentials.withEnv().OpenAI
+	if openaiApiKey == "" {
+		return nil, errors.New("OPEN_AI_API_KEY is required")
+	}
+	streaming := make(chan string, 10)
+	transcripts := make(chan stt.Transcript)
+	agentWorker, err := newAgentWorker(agentConfig, openaiApiKey, deps, streaming, transcripts)
+	if err != nil {
+		return nil, err
+	}
+	voice := agentConfig.VoiceFor(agentConfig.DefaultLanguage())
+	c := &Chat{
+		Agent:       agentConfig,
+		AgentWorker: agentWorker,
+		events:      make(chan ChatEvent, 32),
+		streaming:   streaming,
+		transcripts: transcripts,
+		normalizer:  normalize.New(voice.Locale, agentConfig.Pronunciations),
+		done:        make(chan struct{}),
+	}
+	agentWorker.OnTurnEnd = func(string) {
+		select {
+		case c.streaming <- endOfTurn:
+		case <-c.done:
+		}
+	}
+	agentWorker.OpenAIClient.OnToolCall = func(name string, arguments string, result string, took time.Duration) {
+		c.emit(ChatEvent{Type: ChatToolCall, Tool: name, Arguments: arguments, Result: result, ElapsedMs: took.Milliseconds()})
+	}
+	if agentConfig.DTMF != nil {
+		c.keypadResults = make(chan dtmf.Result, 4)
+		c.Keypad = dtmf.NewCollector(agentConfig.DTMF.KeypadOptions(), c.keypadResults)
+		if err := agentWorker.OpenAIClient.RegisterTool(collectDigitsTool(c.collectDigits)); err != nil {
+			return nil, err
+		}
+	}
+	if agentConfig.IVRNavigation {
+		if err := agentWorker.OpenAIClient.RegisterTool(pressDigitsTool(c.pressDigits)); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Events delivers what the agent says and does, in order.
+func (c *Chat) Events() <-chan ChatEvent {
+	return c.events
+}
+
+// Start runs the agent and reports its greeting.
+func (c *Chat) Start() {
+	c.AgentWorker.Start()
+	go c.forwardSentences()
+	if c.Keypad != nil {
+		go c.forwardKeypad()
+	}
+	c.startTurn()
+	if c.Agent.Greeting != "" {
+		c.streaming <- c.Agent.Greeting
+	}
+	c.streaming <- endOfTurn
+}
+
+// Say hands a typed message to the agent as if the caller had said it. A
+// message typed while the agent is still answering interrupts it, when the
+// agent can be interrupted.
+func (c *Chat) Say(text string) {
+	if c.Thinking() && c.Agent.Interruptible {
+		c.AgentWorker.Interrupt()
+	}
+	c.send(stt.Transcript{Text: text, Confidence: 1, Final: true})
+}
+
+// Press types keys on the keypad. It fails when the agent does not take
+// keypad input.
+func (c *Chat) Press(keys string) error {
+	if c.Keypad == nil {
+		return fmt.Errorf("keypad input is disabled for agent %q", c.Agent.ID)
+	}
+	for _, key := range keys {
+		ev := dtmf.Event{Digit: string(key), At: time.Now()}
+		if !ev.Valid() {
+			return fmt.Errorf("%q is not a keypad key", key)
+		}
+		c.Keypad.Add(ev)
+	}
+	return nil
+}
+
+// Interrupt abandons the reply being generated, like a caller barging in.
+func (c *Chat) Interrupt() {
+	c.AgentWorker.Interrupt()
+}
+
+// Thinking reports whether the agent is still answering the last message.
+func (c *Chat) Thinking() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pending > 0
+}
+
+func (c *Chat) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.AgentWorker.Stop()
+		if c.Keypad != nil {
+			c.Keypad.Stop()
+		}
+	})
+}
+
+func (c *Chat) send(transcript stt.Transcript) {
+	c.startTurn()
+	select {
+	case c.transcripts <- transcript:
+	case <-c.done:
+	}
+}
+
+func (c *Chat) startTurn() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.turnStart = time.Now()
+	c.pending++
+}
+
+func (c *Chat) elapsed() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Since(c.turnStart).Milliseconds()
+}
+
+func (c *Chat) emit(ev ChatEvent) {
+	select {
+	case c.events <- ev:
+	case <-c.done:
+	}
+}
+
+// forwardSentences reports every sentence the way AgentResponseWorker would
+// prepare it for synthesis.
+func (c *Chat) forwardSentences() {
+	for {
+		select {
+		case <-c.done:
+			return
+		case sentence := <-c.streaming:
+			if sentence == endOfTurn {
+				c.mu.Lock()
+				c.pending--
+				c.mu.Unlock()
+				c.emit(ChatEvent{Type: ChatTurnEnd, ElapsedMs: c.elapsed()})
+				continue
+			}
+			text, override := tts.ParseDirective(sentence, c.Agent.VoicePresets)
+			ev := ChatEvent{
+				Type:      ChatSentence,
+				Text:      sentence,
+				Speakable: c.normalizer.Normalize(text),
+				ElapsedMs: c.elapsed(),
+			}
+			if override != nil {
+				ev.Preset = tts.Directive(sentence)
+			}
+			c.emit(ev)
+		}
+	}
+}
+
+func (c *Chat) forwardKeypad() {
+	for {
+		select {
+		case <-c.done:
+			return
+		case result := <-c.keypadResults:
+			if ignoredEntry(result) {
+				continue
+			}
+			var ref string
+			if result.Sensitive {
+				ref = c.secrets.store(result.Digits)
+			}
+			transcript := keypadTranscript(result, ref)
+			c.emit(ChatEvent{Type: ChatKeypad, Text: transcript.Text})
+			c.send(transcript)
+		}
+	}
+}
+
+func (c *Chat) collectDigits(ctx context.Context, arguments string) (string, error) {
+	opts, err := collectOptions(c.Agent.DTMF, arguments)
+	if err != nil {
+		return "", err
+	}
+	c.Keypad.Expect(opts)
+	return collectStarted, nil
+}
+
+// pressDigits only reports the keys; there is no phone menu to hear them.
+func (c *Chat) pressDigits(ctx context.Context, arguments string) (string, error) {
+	_, pressed, err := pressDigitsInput(arguments, c.secrets.lookup)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Pressed %s.", pressed), nil
+}
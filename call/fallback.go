@@ -0,0 +1,45 @@
+package call
+
+import (
+	"os"
+
+	"github.com/mrsingh-rishi/voice-bot/agent"
+	"github.com/mrsingh-rishi/voice-bot/llm"
+	"github.com/mrsingh-rishi/voice-bot/logging"
+	"github.com/mrsingh-rishi/voice-bot/tts"
+)
+
+// llmFallbacks builds the agent's backup models. A fallback whose own key
+// is not set reuses openaiApiKey.
+func llmFallbacks(agentConfig *agent.Config, openaiApiKey string) []llm.Provider {
+	providers := make([]llm.Provider, 0, len(agentConfig.LLMFallbacks))
+	for _, f := range agentConfig.LLMFallbacks {
+		providers = append(providers, llm.NewProvider(fallbackKey(f.APIKeyEnv, openaiApiKey), f.BaseURL, f.Model))
+	}
+	return providers
+}
+
+// ttsFallbacks builds the agent's backup speech providers.
+func ttsFallbacks(agentConfig *agent.Config, openaiApiKey string) []tts.Speaker {
+	speakers := make([]tts.Speaker, 0, len(agentConfig.TTSFallbacks))
+	for _, f := range agentConfig.TTSFallbacks {
+		client, err := tts.NewOpenAITTSClient(fallbackKey(f.APIKeyEnv, openaiApiKey), f.BaseURL, f.Model, f.Voice)
+		if err != nil {
+			logging.Logger("call").Warn("Skipping TTS fallback", "base_url", f.BaseURL, "error", err)
+			continue
+		}
+		speakers = append(speakers, client)
+	}
+	return speakers
+}
+
+func fallbackKey(env string, defaultKey string) string {
+	if env == "" {
+		return defaultKey
+	}
+	if key := os.Getenv(env); key != "" {
+		return key
+	}
+	logging.Logger("call").Warn("Fallback API key is not set, using OPEN_AI_API_KEY", "env", env)
+	return defaultKey
+}
@@ -0,0 +1,197 @@
+package call
+
+import (
+	"time"
+
+	"github.com/mrsingh-rishi/voice-bot/metrics"
+	"github.com/mrsingh-rishi/voice-bot/stt"
+)
+
+const (
+	// inactivityTick is how often the inactivity monitor looks at the call
+	inactivityTick = 250 * time.Millisecond
+	// playbackStartTimeout bounds how long a closing message may take to start playing
+	playbackStartTimeout = 10 * time.Second
+	// playbackEndTimeout bounds how long a closing message may play
+	playbackEndTimeout = 60 * time.Second
+	// playbackSettle is how long output must stay quiet to count as finished,
+	// so the gap between two sentences is not mistaken for the end
+	playbackSettle = 500 * time.Millisecond
+)
+
+// monitorInactivity reprompts a caller who stays quiet after the agent's
+// turn, hangs up once too many reprompts go unanswered, and wraps the call
+// up when it reaches the agent's maximum duration. The silence timer only
+// runs while neither side is speaking.
+func (c *Call) monitorInactivity() {
+	silenceTimeout := time.Duration(c.Agent.SilenceTimeoutMs) * time.Millisecond
+	maxDuration := time.Duration(c.Agent.MaxCallDurationSec) * time.Second
+	started := time.Now()
+	quietSince := started
+	var lastActivity time.Time
+	reprompts := 0
+
+	ticker := time.NewTicker(inactivityTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		if maxDuration > 0 && now.Sub(started) >= maxDuration {
+			c.log.Info("Call reached its maximum duration, wrapping up", "max_duration", maxDuration)
+			c.endCall(metrics.OutcomeMaxDuration, c.Agent.WrapUpMessage)
+			return
+		}
+		if silenceTimeout <= 0 || c.OutputWorker == nil {
+			continue
+		}
+		// Anything the caller says resets the reprompt count
+		if activity := c.lastCallerActivity(); activity.After(lastActivity) {
+			lastActivity = activity
+			reprompts = 0
+			if activity.After(quietSince) {
+				quietSince = activity
+			}
+		}
+		if state := c.State(); state == StateSpeaking || state == StateInterrupted || c.CallerSpeaking() || c.OutputWorker.Speaking() {
+			quietSince = now
+			continue
+		}
+		if now.Sub(quietSince) < silenceTimeout {
+			continue
+		}
+		if reprompts >= c.Agent.MaxReprompts {
+			c.log.Info("Caller silent after reprompts, hanging up", "reprompts", reprompts)
+			c.endCall(metrics.OutcomeNoResponse, c.Agent.GoodbyeMessage)
+			return
+		}
+		reprompts++
+		c.log.Info("Caller silent, reprompting", "silence", silenceTimeout, "reprompt", reprompts, "max_reprompts", c.Agent.MaxReprompts)
+		c.say(c.Agent.RepromptMessage)
+		quietSince = now
+	}
+}
+
+// lastCallerActivity is when the VAD last saw the caller start or stop speaking.
+func (c *Call) lastCallerActivity() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastSpeechEnd.After(c.lastSpeechStart) {
+		return c.lastSpeechEnd
+	}
+	return c.lastSpeechStart
+}
+
+// Outcome is how the call ended: why we hung up, or completed when the far
+// end did.
+func (c *Call) Outcome() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.outcome == "" {
+		return metrics.OutcomeCompleted
+	}
+	return c.outcome
+}
+
+func (c *Call) isEnding() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ending
+}
+
+// shouldReply keeps what the caller says during the closing message from
+// starting a new turn.
+func (c *Call) shouldReply(transcript stt.Transcript) bool {
+	if c.isEnding() {
+		c.log.Info("Call is ending, not replying", "text", transcript.Text)
+		return false
+	}
+	return true
+}
+
+// say queues text to be spoken after whatever the agent is already saying.
+func (c *Call) say(text string) {
+	if text == "" {
+		return
+	}
+	select {
+	case c.StreamingChannel <- text:
+	case <-c.done:
+	}
+}
+
+// endCall stops the agent from starting new replies, lets it finish the
+// current one, says message, waits for it to play out and hangs up. outcome
+// is recorded as the reason the call ended.
+func (c *Call) endCall(outcome string, message string) {
+	c.mu.Lock()
+	c.ending = true
+	c.outcome = outcome
+	c.mu.Unlock()
+
+	c.AgentWorker.Interrupt()
+	if message != "" && c.OutputWorker != nil {
+		c.waitForQuiet(playbackEndTimeout)
+		c.say(message)
+		c.waitForPlayback()
+	}
+	c.Hangup()
+}
+
+// waitForPlayback blocks until queued agent audio has started and then
+// finished playing, or the call ends.
+func (c *Call) waitForPlayback() {
+	started := c.waitFor(playbackStartTimeout, func(time.Time) bool {
+		return c.OutputWorker.Speaking()
+	})
+	if !started {
+		c.log.Warn("Timed out waiting for closing message to play")
+		return
+	}
+	c.waitForQuiet(playbackEndTimeout)
+}
+
+// waitForQuiet blocks until agent audio has been silent for playbackSettle.
+func (c *Call) waitForQuiet(timeout time.Duration) bool {
+	var quietSince time.Time
+	return c.waitFor(timeout, func(now time.Time) bool {
+		if c.OutputWorker.Speaking() {
+			quietSince = time.Time{}
+			return false
+		}
+		if quietSince.IsZero() {
+			quietSince = now
+		}
+		return now.Sub(quietSince) >= playbackSettle
+	})
+}
+
+// waitFor polls cond until it holds, the timeout passes or the call ends.
+func (c *Call) waitFor(timeout time.Duration, cond func(now time.Time) bool) bool {
+	ticker := time.NewTicker(inactivityTick / 5)
+	defer ticker.Stop()
+	deadline := time.Now().Add(timeout)
+	for {
+		select {
+		case <-c.done:
+			return false
+		case now := <-ticker.C:
+			if cond(now) {
+				return true
+			}
+			if now.After(deadline) {
+				return false
+			}
+		}
+	}
+}
+
+// Hangup ends the call. Closing the media stream lets Twilio move past
+// <Connect>, and with no TwiML after it the call is over.
+func (c *Call) Hangup() {
+	c.log.Info("Hanging up call")
+	c.CleanupResources()
+}
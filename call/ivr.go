@@ -0,0 +1,92 @@
+package call
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mrsingh-rishi/voice-bot/audio"
+	"github.com/mrsingh-rishi/voice-bot/llm"
+)
+
+// pressDigitsArgs are the arguments of the press_digits tool.
+type pressDigitsArgs struct {
+	Digits string `json:"digits"`
+	Ref    string `json:"ref"`
+}
+
+var pressDigitsParameters = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"digits": map[string]any{
+			"type":        "string",
+			"description": `Keys to press in order: 0-9, *, #, A-D. Use "w" for a half-second pause.`,
+		},
+		"ref": map[string]any{
+			"type":        "string",
+			"description": "Ref of a sensitive [keypad] entry, e.g. a PIN the caller typed. Its digits are pressed before digits.",
+		},
+	},
+}
+
+// registerIVRTools gives the LLM the tools to navigate phone menus.
+func (c *Call) registerIVRTools() error {
+	return c.AgentWorker.OpenAIClient.RegisterTool(pressDigitsTool(c.pressDigits))
+}
+
+func pressDigitsTool(handler llm.ToolHandler) llm.Tool {
+	return llm.Tool{
+		Name:        "press_digits",
+		Description: "Press keys on the phone keypad, e.g. to pick an option in an automated phone menu.",
+		Parameters:  pressDigitsParameters,
+		Handler:     handler,
+	}
+}
+
+func (c *Call) pressDigits(ctx context.Context, arguments string) (string, error) {
+	digits, pressed, err := pressDigitsInput(arguments, c.secrets.lookup)
+	if err != nil {
+		return "", err
+	}
+	if c.OutputWorker == nil {
+		return "", fmt.Errorf("the call is not connected yet")
+	}
+	frames, err := audio.DTMFFrames(digits)
+	if err != nil {
+		return "", err
+	}
+	chunks := make([]string, len(frames))
+	for i, frame := range frames {
+		chunks[i] = base64.StdEncoding.EncodeToString(frame)
+	}
+	c.log.Info("Pressing keys", "keys", pressed)
+	c.OutputWorker.PlayTones(chunks)
+	return fmt.Sprintf("Pressed %s.", pressed), nil
+}
+
+// pressDigitsInput returns the keys press_digits was asked to press, looking
+// up the digits of a sensitive entry with secret, and how to describe them
+// without giving those digits away.
+func pressDigitsInput(arguments string, secret func(ref string) (string, bool)) (digits string, pressed string, err error) {
+	var args pressDigitsArgs
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return "", "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	if args.Digits == "" && args.Ref == "" {
+		return "", "", fmt.Errorf("digits or ref is required")
+	}
+	digits, pressed = args.Digits, args.Digits
+	if args.Ref != "" {
+		entry, ok := secret(args.Ref)
+		if !ok {
+			return "", "", fmt.Errorf("unknown keypad entry %q", args.Ref)
+		}
+		digits = entry + args.Digits
+		pressed = "the digits of " + args.Ref
+		if args.Digits != "" {
+			pressed += " then " + args.Digits
+		}
+	}
+	return digits, pressed, audio.ValidDTMF(digits)
+}
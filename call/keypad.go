@@ -0,0 +1,206 @@
+package call
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mrsingh-rishi/voice-bot/agent"
+	"github.com/mrsingh-rishi/voice-bot/dtmf"
+	"github.com/mrsingh-rishi/voice-bot/llm"
+	"github.com/mrsingh-rishi/voice-bot/stt"
+)
+
+// collectInitialTimeout is how long the caller has to start typing after the
+// agent asks for digits; it covers the time the agent spends asking.
+const collectInitialTimeout = 20 * time.Second
+
+// endedByNoInput is how an expected entry the caller never typed is reported.
+const endedByNoInput = "no_input"
+
+// keypadInput is the structured user message the LLM receives for an entry.
+type keypadInput struct {
+	Digits    string `json:"digits"`
+	Length    int    `json:"length"`
+	EndedBy   string `json:"ended_by"`
+	Sensitive bool   `json:"sensitive,omitempty"`
+	// Ref names a sensitive entry so tools can look up the real digits
+	Ref string `json:"ref,omitempty"`
+}
+
+// collectDigitsArgs are the arguments of the collect_digits tool.
+type collectDigitsArgs struct {
+	MaxDigits      int     `json:"max_digits"`
+	Terminator     *string `json:"terminator"`
+	TimeoutSeconds float64 `json:"timeout_seconds"`
+	Sensitive      bool    `json:"sensitive"`
+}
+
+var collectDigitsParameters = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"max_digits": map[string]any{
+			"type":        "integer",
+			"description": "How many digits to expect; the entry completes as soon as they are in. 0 means no limit.",
+		},
+		"terminator": map[string]any{
+			"type":        "string",
+			"description": `Key that ends the entry early, "#" by default. Empty for none.`,
+		},
+		"timeout_seconds": map[string]any{
+			"type":        "number",
+			"description": "Seconds to wait after a key press before the entry is complete.",
+		},
+		"sensitive": map[string]any{
+			"type":        "boolean",
+			"description": "True for PINs, passwords and card numbers. Their digits are hidden from you; the entry carries a ref instead.",
+		},
+	},
+}
+
+// registerKeypadTools gives the LLM the tools that drive keypad input.
+func (c *Call) registerKeypadTools() error {
+	return c.AgentWorker.OpenAIClient.RegisterTool(collectDigitsTool(c.collectDigits))
+}
+
+func collectDigitsTool(handler llm.ToolHandler) llm.Tool {
+	return llm.Tool{
+		Name:        "collect_digits",
+		Description: "Collect digits the caller types on their phone keypad, e.g. an account number, a PIN or a menu choice. The entry arrives later as a [keypad] user message.",
+		Parameters:  collectDigitsParameters,
+		Handler:     handler,
+	}
+}
+
+func (c *Call) collectDigits(ctx context.Context, arguments string) (string, error) {
+	opts, err := collectOptions(c.Agent.DTMF, arguments)
+	if err != nil {
+		return "", err
+	}
+	c.Keypad.Expect(opts)
+	return collectStarted, nil
+}
+
+// collectStarted is the collect_digits result the model gets.
+const collectStarted = "Keypad collection started. Tell the caller what to enter; the entry will arrive as the next [keypad] message."
+
+// collectOptions turns collect_digits arguments into collection rules,
+// starting from the agent's keypad defaults.
+func collectOptions(config *agent.DTMFConfig, arguments string) (dtmf.Options, error) {
+	var args collectDigitsArgs
+	if arguments != "" {
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return dtmf.Options{}, fmt.Errorf("invalid arguments: %w", err)
+		}
+	}
+	opts := config.KeypadOptions()
+	opts.MaxDigits = args.MaxDigits
+	opts.Sensitive = args.Sensitive
+	opts.InitialTimeout = collectInitialTimeout
+	if args.Terminator != nil {
+		opts.Terminator = *args.Terminator
+	}
+	if args.TimeoutSeconds > 0 {
+		opts.Timeout = time.Duration(args.TimeoutSeconds * float64(time.Second))
+	}
+	return opts, nil
+}
+
+// handleDTMF feeds one key press from Twilio to the keypad collector.
+func (c *Call) handleDTMF(digit string) {
+	if c.Keypad == nil {
+		c.log.Info("Ignoring key press: keypad input is disabled")
+		return
+	}
+	// Typing over the agent means the caller already knows what to enter
+	if c.Agent.Interruptible && !c.isEnding() && c.OutputWorker != nil && c.OutputWorker.Speaking() {
+		c.Interrupt()
+	}
+	c.Keypad.Add(dtmf.Event{Digit: digit, At: time.Now()})
+}
+
+// forwardKeypad hands every completed entry to the agent as structured user
+// input. Sensitive digits are masked and kept out of the conversation.
+func (c *Call) forwardKeypad() {
+	for {
+		select {
+		case <-c.done:
+			return
+		case result := <-c.keypadResults:
+			if ignoredEntry(result) {
+				c.log.Debug("Ignoring empty keypad entry", "ended_by", result.Reason)
+				continue
+			}
+			var ref string
+			if result.Sensitive {
+				ref = c.secrets.store(result.Digits)
+			}
+			transcript := keypadTranscript(result, ref)
+			c.log.Info("Keypad entry complete", "text", transcript.Text)
+			select {
+			case c.TranscriptionChannel <- transcript:
+			case <-c.done:
+				return
+			}
+		}
+	}
+}
+
+// ignoredEntry reports whether an entry is not worth a turn: a terminator
+// pressed with nothing before it. An expected entry that timed out empty is
+// still reported, so the agent knows the caller typed nothing.
+func ignoredEntry(result dtmf.Result) bool {
+	return result.Digits == "" && result.Reason != dtmf.ReasonTimeout
+}
+
+// keypadTranscript is the structured user message for a keypad entry. ref
+// names the stored digits of a sensitive entry.
+func keypadTranscript(result dtmf.Result, ref string) stt.Transcript {
+	input := keypadInput{
+		Digits:    result.Masked(),
+		Length:    len(result.Digits),
+		EndedBy:   result.Reason,
+		Sensitive: result.Sensitive,
+		Ref:       ref,
+	}
+	if result.Digits == "" {
+		input.EndedBy = endedByNoInput
+		input.Sensitive, input.Ref = false, ""
+	}
+	data, _ := json.Marshal(input)
+	return stt.Transcript{Text: "[keypad] " + string(data), Final: true}
+}
+
+// secretStore keeps the digits of sensitive keypad entries by ref, so tools
+// can use them without the model ever seeing them.
+type secretStore struct {
+	mu      sync.Mutex
+	entries map[string]string
+}
+
+// store keeps digits and returns their ref.
+func (s *secretStore) store(digits string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.entries == nil {
+		s.entries = map[string]string{}
+	}
+	ref := fmt.Sprintf("keypad-%d", len(s.entries)+1)
+	s.entries[ref] = digits
+	return ref
+}
+
+// lookup returns the digits stored under ref.
+func (s *secretStore) lookup(ref string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	digits, ok := s.entries[ref]
+	return digits, ok
+}
+
+// SecretDigits returns the real digits of a sensitive keypad entry.
+func (c *Call) SecretDigits(ref string) (string, bool) {
+	return c.secrets.lookup(ref)
+}
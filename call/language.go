@@ -0,0 +1,44 @@
+package call
+
+import (
+	"fmt"
+
+	"github.com/mrsingh-rishi/voice-bot/normalize"
+)
+
+// Language returns the language the agent is currently speaking.
+func (c *Call) Language() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.language
+}
+
+// switchLanguage follows the caller into the language Deepgram detected,
+// if the agent's language policy allows it: the reply language, the TTS
+// voice and model, and the text normalizer all change together.
+func (c *Call) switchLanguage(detected string) {
+	policy := c.Agent.Languages
+	if policy == nil || detected == "" {
+		return
+	}
+	lang, ok := policy.Allowed(detected)
+	if !ok {
+		c.log.Info("Caller spoke a language the agent does not support", "detected", detected, "language", c.Language())
+		return
+	}
+	c.mu.Lock()
+	from := c.language
+	c.language = lang
+	c.mu.Unlock()
+	if lang == from {
+		return
+	}
+
+	voice := c.Agent.VoiceFor(lang)
+	c.log.Info("Switching language", "from", from, "to", lang, "voice", voice.VoiceID)
+	c.AgentResponseWorker.SetVoice(voice.VoiceID, voice.Settings, normalize.New(voice.Locale, c.Agent.Pronunciations))
+	c.FillerResponseWorker.SetVoice(voice.VoiceID)
+	// Called from the agent worker before the turn is sent, so the model
+	// sees this ahead of the caller's message
+	c.AgentWorker.OpenAIClient.AddSystemMessage(fmt.Sprintf("The caller switched to language %q. Reply in that language from now on.", lang))
+}
@@ -0,0 +1,44 @@
+package call
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/mrsingh-rishi/voice-bot/logging"
+)
+
+// callIDs identify a call in every record its components log. The far
+// end's IDs are only known once the stream starts, after the components are
+// built, so they are read for each record.
+type callIDs struct {
+	mu        sync.Mutex
+	workspace string
+	agent     string
+	callSid   string
+	streamSid string
+}
+
+func (ids *callIDs) LogValue() slog.Value {
+	ids.mu.Lock()
+	defer ids.mu.Unlock()
+	attrs := []slog.Attr{slog.String("agent", ids.agent)}
+	if ids.workspace != "" {
+		attrs = append(attrs, slog.String("workspace", ids.workspace))
+	}
+	if ids.callSid != "" {
+		attrs = append(attrs, slog.String("sid", ids.callSid), slog.String("stream_sid", ids.streamSid))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func (ids *callIDs) started(callSid string, streamSid string) {
+	ids.mu.Lock()
+	defer ids.mu.Unlock()
+	ids.callSid = callSid
+	ids.streamSid = streamSid
+}
+
+// callLogger returns the logger of subsystem for the call identified by ids.
+func callLogger(ids *callIDs, subsystem string) *slog.Logger {
+	return logging.Logger(subsystem).With(slog.Any("call", ids))
+}
@@ -0,0 +1,136 @@
+package call
+
+import (
+	"time"
+
+	"github.com/mrsingh-rishi/voice-bot/stt"
+)
+
+// State is where a call is in the turn-taking cycle.
+type State int
+
+const (
+	// StateListening waits for the caller to speak
+	StateListening State = iota
+	// StateThinking has a transcript and waits for the first audio of the reply
+	StateThinking
+	// StateSpeaking is playing agent audio
+	StateSpeaking
+	// StateInterrupted was cut off by the caller; Twilio is dropping buffered audio
+	StateInterrupted
+)
+
+func (s State) String() string {
+	switch s {
+	case StateListening:
+		return "listening"
+	case StateThinking:
+		return "thinking"
+	case StateSpeaking:
+		return "speaking"
+	case StateInterrupted:
+		return "interrupted"
+	default:
+		return "unknown"
+	}
+}
+
+// State returns the call's current turn-taking state.
+func (c *Call) State() State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// setState moves the call to state to, provided it is currently in one of
+// from (any state when from is empty). It reports whether the move happened.
+func (c *Call) setState(to State, from ...State) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(from) > 0 && !containsState(from, c.state) {
+		return false
+	}
+	if c.state == to {
+		return true
+	}
+	c.log.Debug("Call state changed", "from", c.state.String(), "to", to.String())
+	c.state = to
+	c.stateSince = time.Now()
+	return true
+}
+
+func containsState(states []State, s State) bool {
+	for _, candidate := range states {
+		if candidate == s {
+			return true
+		}
+	}
+	return false
+}
+
+// onTurnStart runs when a caller transcript is handed to the LLM.
+func (c *Call) onTurnStart(transcript stt.Transcript) {
+	c.mu.Lock()
+	speechEnd := c.lastSpeechEnd
+	c.mu.Unlock()
+	c.turn.Start(speechEnd)
+	c.trace.StartTurn()
+	c.switchLanguage(transcript.Language)
+	c.mu.Lock()
+	c.awaitingReply = true
+	c.mu.Unlock()
+	c.setState(StateThinking)
+}
+
+// onTurnEnd runs when the LLM is done; a turn that produced nothing to say
+// goes straight back to listening.
+func (c *Call) onTurnEnd(reply string) {
+	if reply != "" {
+		return
+	}
+	c.mu.Lock()
+	c.awaitingReply = false
+	c.mu.Unlock()
+	if c.setState(StateListening, StateThinking, StateSpeaking) {
+		c.trace.EndTurn()
+	}
+}
+
+// onPlaybackStart runs when the first audio of an agent utterance or of a
+// filler is sent.
+func (c *Call) onPlaybackStart() {
+	c.mu.Lock()
+	thinking := c.state == StateThinking
+	since := c.stateSince
+	c.mu.Unlock()
+	if thinking {
+		c.log.Info("First audio sent", "latency", time.Since(since).Round(time.Millisecond))
+	}
+	c.setState(StateSpeaking)
+}
+
+// onMediaSent runs once the first audio of an agent utterance, not a
+// filler, has been handed to the transport.
+func (c *Call) onMediaSent() {
+	c.turn.FirstMedia()
+	c.mu.Lock()
+	c.awaitingReply = false
+	c.mu.Unlock()
+}
+
+// onPlaybackDone runs once Twilio has acked every mark, i.e. all audio sent
+// so far has played out or been cleared. The reply is over, and so is the
+// turn's trace span, unless only a filler played and the reply is still to
+// come.
+func (c *Call) onPlaybackDone() {
+	c.mu.Lock()
+	awaiting := c.awaitingReply
+	c.mu.Unlock()
+	if awaiting {
+		c.setState(StateThinking, StateSpeaking)
+		return
+	}
+	if c.setState(StateListening, StateSpeaking, StateInterrupted) {
+		c.trace.EndTurn()
+	}
+}
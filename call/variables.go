@@ -0,0 +1,56 @@
+package call
+
+import (
+	"sync"
+	"time"
+)
+
+// VariableStore holds the variables an outbound call was created with until
+// its media stream connects. Entries that are never claimed expire.
+type VariableStore struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]variableEntry
+}
+
+type variableEntry struct {
+	vars    map[string]string
+	created time.Time
+}
+
+func NewVariableStore(ttl time.Duration) *VariableStore {
+	return &VariableStore{
+		ttl:     ttl,
+		entries: map[string]variableEntry{},
+	}
+}
+
+// Put stores the variables of a call by its CallSid.
+func (s *VariableStore) Put(callSid string, vars map[string]string) {
+	if callSid == "" || len(vars) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for sid, entry := range s.entries {
+		if now.Sub(entry.created) > s.ttl {
+			delete(s.entries, sid)
+		}
+	}
+	s.entries[callSid] = variableEntry{vars: vars, created: now}
+}
+
+// Take returns and forgets the variables of a call. Calls without variables
+// get an empty map.
+func (s *VariableStore) Take(callSid string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[callSid]
+	if !ok {
+		return map[string]string{}
+	}
+	delete(s.entries, callSid)
+	return entry.vars
+}
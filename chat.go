@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mrsingh-rishi/voice-bot/agent"
+	"github.com/mrsingh-rishi/voice-bot/call"
+	"github.com/mrsingh-rishi/voice-bot/fallback"
+)
+
+// chatMessage is what a /chat client sends: {"type":"text","text":"..."},
+// {"type":"keys","keys":"1234#"} or {"type":"interrupt"}.
+type chatMessage struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+	Keys string `json:"keys,omitempty"`
+}
+
+// runChat talks to an agent in the terminal, typed text in place of speech,
+// and returns the exit code.
+//
+//	voice-bot chat --agent support
+func runChat(args []string) int {
+	flags := flag.NewFlagSet("chat", flag.ExitOnError)
+	agentID := flags.String("agent", "", "agent ID (default agent when empty)")
+	agentsDir := flags.String("agents", os.Getenv("AGENTS_DIR"), "directory of agent configs")
+	verbose := flags.Bool("v", false, "show the bot's logs")
+	flags.Parse(args)
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	agents, err := agent.LoadRegistry(*agentsDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load agents: %v\n", err)
+		return 1
+	}
+	agentConfig, ok := agents.Get(*agentID)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown agent %q\n", *agentID)
+		return 1
+	}
+	chat, err := call.NewChat(agentConfig, call.Dependencies{
+		Breakers:  fallback.NewRegistry(3, 30*time.Second),
+		Endpoints: call.Endpoints{OpenAI: os.Getenv("OPEN_AI_BASE_URL")},
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start chat: %v\n", err)
+		return 1
+	}
+	defer chat.Close()
+
+	fmt.Printf("Chatting with agent %q. Type /keys 1234# to use the keypad, /quit to leave.\n", agentConfig.ID)
+	go printChatEvents(chat)
+	chat.Start()
+
+	input := bufio.NewScanner(os.Stdin)
+	for input.Scan() {
+		line := strings.TrimSpace(input.Text())
+		switch {
+		case line == "":
+		case line == "/quit":
+			return 0
+		case strings.HasPrefix(line, "/keys "):
+			if err := chat.Press(strings.TrimSpace(strings.TrimPrefix(line, "/keys "))); err != nil {
+				fmt.Printf("error: %v\n", err)
+			}
+		default:
+			chat.Say(line)
+		}
+	}
+	return 0
+}
+
+// printChatEvents shows each sentence with its timing, followed by what TTS
+// would be sent when that differs, and prompts again once a turn ends.
+func printChatEvents(chat *call.Chat) {
+	for ev := range chat.Events() {
+		switch ev.Type {
+		case call.ChatSentence:
+			fmt.Printf("agent  %6dms  %s\n", ev.ElapsedMs, ev.Text)
+			if ev.Preset != "" {
+				fmt.Printf("%16s[%s] %q\n", "", ev.Preset, ev.Speakable)
+			} else if ev.Speakable != ev.Text {
+				fmt.Printf("%16stts %q\n", "", ev.Speakable)
+			}
+		case call.ChatToolCall:
+			fmt.Printf("tool   %6dms  %s(%s) -> %s\n", ev.ElapsedMs, ev.Tool, ev.Arguments, ev.Result)
+		case call.ChatKeypad:
+			fmt.Printf("keypad          %s\n", ev.Text)
+		case call.ChatTurnEnd:
+			fmt.Printf("       %6dms  (done)\nyou> ", ev.ElapsedMs)
+		}
+	}
+}
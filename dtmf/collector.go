@@ -0,0 +1,165 @@
+// Package dtmf turns keypad presses reported by the telephony provider into
+// complete entries such as an account number or a menu choice.
+package dtmf
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Keys are every key a telephone keypad can send.
+const Keys = "0123456789*#ABCD"
+
+// Event is one key press.
+type Event struct {
+	Digit string
+	At    time.Time
+}
+
+// Valid reports whether the event carries a known key.
+func (e Event) Valid() bool {
+	return len(e.Digit) == 1 && strings.Contains(Keys, e.Digit)
+}
+
+// Reasons a collection completed.
+const (
+	ReasonTerminator = "terminator"
+	ReasonMaxDigits  = "max_digits"
+	ReasonTimeout    = "timeout"
+)
+
+// Options are the rules for collecting one entry.
+type Options struct {
+	// MaxDigits completes the entry once this many digits are in; zero means no limit
+	MaxDigits int
+	// Terminator is the key that completes the entry early; it is not part of the digits
+	Terminator string
+	// Timeout completes the entry when no key is pressed for this long after the last one
+	Timeout time.Duration
+	// InitialTimeout completes an expected entry with no digits when nothing
+	// is pressed for this long; zero waits for the first key indefinitely
+	InitialTimeout time.Duration
+	// Sensitive entries (PINs, card numbers) must not be logged or shown to the model
+	Sensitive bool
+}
+
+// Result is one completed entry.
+type Result struct {
+	Digits    string
+	Reason    string
+	Sensitive bool
+}
+
+// Masked returns the digits, or one "*" per digit when they are sensitive.
+func (r Result) Masked() string {
+	if !r.Sensitive {
+		return r.Digits
+	}
+	return strings.Repeat("*", len(r.Digits))
+}
+
+// Collector gathers key presses into entries. Unless Expect sets rules for
+// the next entry, presses are collected with the default options.
+type Collector struct {
+	defaults Options
+	results  chan<- Result
+
+	mu      sync.Mutex
+	opts    Options
+	digits  strings.Builder
+	timer   *time.Timer
+	version int // invalidates timers of entries that already completed
+}
+
+func NewCollector(defaults Options, results chan<- Result) *Collector {
+	return &Collector{
+		defaults: defaults,
+		results:  results,
+		opts:     defaults,
+	}
+}
+
+// Expect replaces the rules for the next entry and discards anything typed
+// so far, e.g. when the agent asks for a 6-digit account number.
+func (c *Collector) Expect(opts Options) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reset()
+	c.opts = opts
+	if opts.InitialTimeout > 0 {
+		c.startTimer(opts.InitialTimeout)
+	}
+}
+
+// Add records one key press and completes the entry when a rule says so.
+func (c *Collector) Add(ev Event) {
+	if !ev.Valid() {
+		return
+	}
+	c.mu.Lock()
+	if c.opts.Terminator != "" && ev.Digit == c.opts.Terminator {
+		result := c.complete(ReasonTerminator)
+		c.mu.Unlock()
+		c.results <- result
+		return
+	}
+	c.digits.WriteString(ev.Digit)
+	if c.opts.MaxDigits > 0 && c.digits.Len() >= c.opts.MaxDigits {
+		result := c.complete(ReasonMaxDigits)
+		c.mu.Unlock()
+		c.results <- result
+		return
+	}
+	if c.opts.Timeout > 0 {
+		c.startTimer(c.opts.Timeout)
+	}
+	c.mu.Unlock()
+}
+
+// Stop cancels any pending timeout.
+func (c *Collector) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reset()
+}
+
+// startTimer (re)arms the timeout for the current entry. Callers hold mu.
+func (c *Collector) startTimer(d time.Duration) {
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	version := c.version
+	c.timer = time.AfterFunc(d, func() {
+		c.mu.Lock()
+		if version != c.version {
+			c.mu.Unlock()
+			return
+		}
+		result := c.complete(ReasonTimeout)
+		c.mu.Unlock()
+		c.results <- result
+	})
+}
+
+// complete finishes the current entry and returns to the default rules. Callers hold mu.
+func (c *Collector) complete(reason string) Result {
+	result := Result{
+		Digits:    c.digits.String(),
+		Reason:    reason,
+		Sensitive: c.opts.Sensitive,
+	}
+	c.reset()
+	c.opts = c.defaults
+	return result
+}
+
+// reset drops the digits and timer of the current entry. Callers hold mu.
+func (c *Collector) reset() {
+	c.version++
+	c.digits.Reset()
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+}
@@ -0,0 +1,186 @@
+// Package fallback tracks the health of the vendors a call depends on, so a
+// failing provider is skipped for a while instead of being retried on every
+// request.
+package fallback
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mrsingh-rishi/voice-bot/metrics"
+)
+
+// ErrAllOpen is returned when every provider of a chain is being skipped.
+var ErrAllOpen = errors.New("every provider's circuit is open")
+
+// Breaker states.
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half_open"
+)
+
+// Breaker is a circuit breaker for one provider. After Threshold failures in
+// a row it opens and rejects requests for Cooldown, then lets a single trial
+// request through; its outcome closes or reopens the breaker.
+// A nil *Breaker always allows requests.
+type Breaker struct {
+	Name      string
+	Threshold int
+	Cooldown  time.Duration
+
+	mu          sync.Mutex
+	state       string
+	consecutive int
+	openedAt    time.Time
+	lastError   string
+	successes   int
+	failures    int
+}
+
+// Status is a snapshot of a breaker for health reporting.
+type Status struct {
+	Name                string    `json:"name"`
+	State               string    `json:"state"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	Successes           int       `json:"successes"`
+	Failures            int       `json:"failures"`
+	LastError           string    `json:"last_error,omitempty"`
+	OpenedAt            time.Time `json:"opened_at,omitempty"`
+}
+
+func NewBreaker(name string, threshold int, cooldown time.Duration) *Breaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &Breaker{
+		Name:      name,
+		Threshold: threshold,
+		Cooldown:  cooldown,
+		state:     StateClosed,
+	}
+}
+
+// Allow reports whether a request may be sent to the provider now.
+func (b *Breaker) Allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case StateOpen, StateHalfOpen:
+		// While half open only the trial request is let through, unless it
+		// never reported back within a cooldown
+		if time.Since(b.openedAt) < b.Cooldown {
+			return false
+		}
+		b.state = StateHalfOpen
+		b.openedAt = time.Now()
+		return true
+	}
+	return true
+}
+
+// Success records a request that worked and closes the breaker.
+func (b *Breaker) Success() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.successes++
+	b.consecutive = 0
+	b.state = StateClosed
+}
+
+// Failure records a failed request and opens the breaker when the threshold
+// is reached or a trial request failed.
+func (b *Breaker) Failure(err error) {
+	if b == nil {
+		return
+	}
+	metrics.VendorErrors.WithLabelValues(b.Name).Inc()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.consecutive++
+	if err != nil {
+		b.lastError = err.Error()
+	}
+	if b.state == StateHalfOpen || b.consecutive >= b.Threshold {
+		b.state = StateOpen
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *Breaker) Status() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status := Status{
+		Name:                b.Name,
+		State:               b.state,
+		ConsecutiveFailures: b.consecutive,
+		Successes:           b.successes,
+		Failures:            b.failures,
+		LastError:           b.lastError,
+	}
+	if b.state != StateClosed {
+		status.OpenedAt = b.openedAt
+	}
+	return status
+}
+
+// Registry hands out one breaker per provider name, shared by every call.
+// A nil *Registry hands out nil breakers, which never trip.
+type Registry struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+}
+
+func NewRegistry(threshold int, cooldown time.Duration) *Registry {
+	return &Registry{
+		threshold: threshold,
+		cooldown:  cooldown,
+		breakers:  map[string]*Breaker{},
+	}
+}
+
+// Get returns the breaker for a provider, creating it on first use.
+func (r *Registry) Get(name string) *Breaker {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.breakers[name]
+	if !ok {
+		b = NewBreaker(name, r.threshold, r.cooldown)
+		r.breakers[name] = b
+	}
+	return b
+}
+
+// Statuses returns every breaker's status, sorted by name.
+func (r *Registry) Statuses() []Status {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	breakers := make([]*Breaker, 0, len(r.breakers))
+	for _, b := range r.breakers {
+		breakers = append(breakers, b)
+	}
+	r.mu.Unlock()
+	statuses := make([]Status, 0, len(breakers))
+	for _, b := range breakers {
+		statuses = append(statuses, b.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
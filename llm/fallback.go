@@ -0,0 +1,117 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mrsingh-rishi/voice-bot/tracing"
+	"github.com/sashabaranov/go-openai"
+)
+
+// DefaultFirstTokenTimeout is how long a provider gets to start answering
+// before the next one in the chain is tried.
+const DefaultFirstTokenTimeout = 5 * time.Second
+
+// ErrAllProvidersFailed is returned when no provider in the chain answered.
+var ErrAllProvidersFailed = errors.New("all LLM providers failed")
+
+// Provider is one OpenAI-compatible chat endpoint in a fallback chain.
+type Provider struct {
+	// Name identifies the provider's circuit breaker
+	Name   string
+	Client *openai.Client
+	Model  string
+}
+
+// NewProvider builds a provider for an OpenAI-compatible API. An empty
+// baseURL means OpenAI itself.
+func NewProvider(apiKey string, baseURL string, model string) Provider {
+	config := openai.DefaultConfig(apiKey)
+	name := "openai/" + model
+	if baseURL != "" {
+		config.BaseURL = baseURL
+		name = baseURL + "/" + model
+	}
+	return Provider{
+		Name:   name,
+		Client: openai.NewClientWithConfig(config),
+		Model:  model,
+	}
+}
+
+// chain is the primary model followed by the fallbacks, in order.
+func (c *OpenAIClient) chain() []Provider {
+	primary := Provider{Name: "openai/" + c.Model, Client: c.Client, Model: c.Model}
+	return append([]Provider{primary}, c.Fallbacks...)
+}
+
+// openStream starts the completion on the first provider whose breaker is
+// closed and that sends its first chunk within the timeout. That first
+// chunk is returned (nil for an empty reply) along with the stream; cancel
+// must be called once the stream is done. Once a provider has started
+// answering there is no switching, as its words may already be spoken.
+func (c *OpenAIClient) openStream(ctx context.Context, req openai.ChatCompletionRequest) (*openai.ChatCompletionStream, *openai.ChatCompletionStreamResponse, context.CancelFunc, error) {
+	timeout := c.FirstTokenTimeout
+	if timeout <= 0 {
+		timeout = DefaultFirstTokenTimeout
+	}
+	for _, provider := range c.chain() {
+		breaker := c.Breakers.Get("llm:" + provider.Name)
+		if !breaker.Allow() {
+			c.Log.Info("Skipping LLM provider: circuit open", "provider", provider.Name)
+			continue
+		}
+		attemptCtx, cancel := context.WithCancel(ctx)
+		timer := time.AfterFunc(timeout, cancel)
+		req.Model = provider.Model
+		// The span lasts until the provider starts answering
+		_, span := tracing.Start(ctx, "llm.request", tracing.Provider.String("llm:"+provider.Name))
+		stream, err := provider.Client.CreateChatCompletionStream(attemptCtx, req)
+		if err == nil {
+			var first openai.ChatCompletionStreamResponse
+			first, err = stream.Recv()
+			timer.Stop()
+			if err == nil {
+				breaker.Success()
+				span.End()
+				return stream, &first, cancel, nil
+			}
+			if errors.Is(err, io.EOF) {
+				breaker.Success()
+				span.End()
+				return stream, nil, cancel, nil
+			}
+			stream.Close()
+		}
+		timer.Stop()
+		cancel()
+		if ctx.Err() != nil {
+			span.End()
+			return nil, nil, nil, ctx.Err()
+		}
+		if attemptCtx.Err() != nil {
+			err = fmt.Errorf("no response within %s", timeout)
+		}
+		tracing.Fail(span, err)
+		span.End()
+		c.Log.Warn("LLM provider failed", "provider", provider.Name, "error", err)
+		breaker.Failure(err)
+	}
+	return nil, nil, nil, ErrAllProvidersFailed
+}
+
+// apologize tells the caller the agent needs a moment when no provider
+// could answer. The message is a warmed phrase, so it plays from the TTS
+// cache even when the TTS providers are down too.
+func (c *OpenAIClient) apologize(ctx context.Context) {
+	if c.FallbackMessage == "" {
+		return
+	}
+	select {
+	case c.StreamingChannel <- c.FallbackMessage:
+	case <-ctx.Done():
+	}
+}
@@ -2,10 +2,14 @@ package llm
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"regexp"
 	"strings"
+	"time"
 
+	"github.com/mrsingh-rishi/voice-bot/fallback"
+	"github.com/mrsingh-rishi/voice-bot/logging"
+	"github.com/mrsingh-rishi/voice-bot/tracing"
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -16,6 +20,18 @@ type OpenAIClient struct {
 	StreamingChannel   chan<- string
 	Model              string      // Model to use for OpenAI API
 	ActionChannel      chan string // Channel to send actions to the main thread(Type will be defined later)
+	Tools              map[string]Tool // Functions the model may call, keyed by name
+	Fallbacks          []Provider      // Tried in order when Model fails or is too slow
+	Breakers           *fallback.Registry
+	FirstTokenTimeout  time.Duration // How long each provider gets to start answering
+	FallbackMessage    string        // Spoken when no provider answers
+	// OnToolCall, if set, is called after every tool call with its result
+	OnToolCall func(name string, arguments string, result string, took time.Duration)
+	// OnFirstToken, if set, is called when the first text of each completion arrives
+	OnFirstToken func()
+	// OnSentence, if set, is called as each sentence of the reply is sent to be spoken
+	OnSentence func()
+	Log        *slog.Logger
 }
 
 func NewOpenAIClient(apiKey string, systemInstructions string, model string, streamingChannel chan<- string) (*OpenAIClient, error) {
@@ -30,64 +46,145 @@ func NewOpenAIClient(apiKey string, systemInstructions string, model string, str
 		Model: model,
 		// will update it later when actions are defined
 		ActionChannel: make(chan string), // Initialize the action channel
+		Log:           logging.Logger("llm"),
 	}, nil
 }
 
+// AddSystemMessage adds an instruction to the conversation mid-call.
+func (c *OpenAIClient) AddSystemMessage(text string) {
+    c.Messages = append(c.Messages, openai.ChatCompletionMessage{
+        Role:    "system",
+        Content: text,
+    })
+}
+
 // StreamResponse sends a user query to OpenAI and streams the response in real-time
-// 1️⃣ Top-level StreamResponse orchestrates setup, looping, and final flush
 func (c *OpenAIClient) StreamResponse(input string) {
-    log.Printf("Sending input to OpenAI: %s\n", input)
+    c.StreamResponseContext(context.Background(), input)
+}
+
+// StreamResponseContext is StreamResponse that stops as soon as ctx is
+// cancelled, e.g. when the caller barges in. It returns the text generated.
+// When the model calls tools, their results are sent back and the reply
+// continues, for at most maxToolRounds rounds.
+// 1️⃣ Top-level StreamResponse orchestrates setup, looping, and final flush
+func (c *OpenAIClient) StreamResponseContext(ctx context.Context, input string) string {
+    c.Log.Debug("Sending input to OpenAI", "input", input)
     c.Messages = append(c.Messages, openai.ChatCompletionMessage{
         Role:    "user",
         Content: input,
     })
+
+    var replies []string
+    for round := 0; round <= maxToolRounds; round++ {
+        text, calls := c.streamRound(ctx)
+        if text != "" {
+            replies = append(replies, text)
+        }
+        if len(calls) == 0 || ctx.Err() != nil {
+            break
+        }
+        c.runTools(ctx, calls)
+    }
+    return strings.Join(replies, " ")
+}
+
+// streamRound streams one completion, speaking its text as it arrives, and
+// records it in the history. It returns the text and any tool calls.
+func (c *OpenAIClient) streamRound(ctx context.Context) (string, []openai.ToolCall) {
+    ctx, span := tracing.Start(ctx, "llm.completion")
+    defer span.End()
     req := openai.ChatCompletionRequest{
         Model:    c.Model,
         Messages: c.Messages,
         Stream:   true,
+        Tools:    c.toolDefinitions(),
     }
 
-    stream, err := c.Client.CreateChatCompletionStream(context.Background(), req)
+    stream, first, cancel, err := c.openStream(ctx, req)
     if err != nil {
-        log.Printf("Failed to stream OpenAI response: %v\n", err)
-        return
+        tracing.Fail(span, err)
+        if ctx.Err() == nil {
+            c.Log.Error("Failed to stream OpenAI response", "error", err)
+            c.apologize(ctx)
+        }
+        return "", nil
     }
+    defer cancel()
     defer stream.Close()
 
-    // prepare our buffer and sentence-matcher
-    sentenceRe := regexp.MustCompile(`[^\.!\?]*[\.!\?]`)
+    // prepare our buffer and sentence-matcher. A sentence only ends once the
+    // punctuation is followed by whitespace, so "$12.50" or "example.com"
+    // reach the normalizer in one piece.
+    sentenceRe := regexp.MustCompile(`(?s).*?[\.!\?]+\s`)
     buffer := &strings.Builder{}
+    reply := &strings.Builder{}
+    calls := &toolCallAccumulator{}
 
     // 2️⃣ Read & process incoming chunks
-    c.readAndProcess(stream, sentenceRe, buffer)
+    c.readAndProcess(ctx, stream, first, sentenceRe, buffer, reply, calls)
+
+    // 3️⃣ Send any trailing text, unless the turn was cut short
+    if ctx.Err() == nil {
+        c.flushRemaining(ctx, buffer)
+    } else {
+        calls.calls = nil
+    }
 
-    // 3️⃣ Send any trailing text
-    c.flushRemaining(buffer)
+    // Keep the reply in the history so the next turn has context
+    text := strings.TrimSpace(reply.String())
+    if text != "" || len(calls.calls) > 0 {
+        c.Messages = append(c.Messages, openai.ChatCompletionMessage{
+            Role:      "assistant",
+            Content:   text,
+            ToolCalls: calls.calls,
+        })
+    }
+    return text, calls.calls
 }
 
 // 2️⃣ readAndProcess: receive each chunk, collate into sentences, and emit them
 func (c *OpenAIClient) readAndProcess(
+    ctx context.Context,
     stream *openai.ChatCompletionStream,
+    first *openai.ChatCompletionStreamResponse,
     sentenceRe *regexp.Regexp,
     buffer *strings.Builder,
+    reply *strings.Builder,
+    calls *toolCallAccumulator,
 ) {
     for {
-        resp, err := stream.Recv()
-        if err != nil {
-            if err.Error() != "EOF" {
-                log.Printf("Error receiving OpenAI response: %v\n", err)
+        var resp openai.ChatCompletionStreamResponse
+        if first != nil {
+            // already received while choosing a provider
+            resp, first = *first, nil
+        } else {
+            var err error
+            resp, err = stream.Recv()
+            if err != nil {
+                if err.Error() != "EOF" && ctx.Err() == nil {
+                    c.Log.Error("Error receiving OpenAI response", "error", err)
+                }
+                break
             }
-            break
         }
+        if len(resp.Choices) == 0 {
+            continue
+        }
+        calls.add(resp.Choices[0].Delta.ToolCalls)
         chunk := resp.Choices[0].Delta.Content
         if chunk == "" {
             continue
         }
+        if reply.Len() == 0 && c.OnFirstToken != nil {
+            c.OnFirstToken()
+        }
+        reply.WriteString(chunk)
 
         // 3️⃣ Break out complete sentences from the buffer
         sentences := processChunk(buffer, chunk, sentenceRe)
         for _, s := range sentences {
-            c.StreamingChannel <- s
+            c.sendSentence(ctx, s)
         }
     }
 }
@@ -121,9 +218,21 @@ func processChunk(
 }
 
 // 4️⃣ flushRemaining: send any leftover text at end-of-stream
-func (c *OpenAIClient) flushRemaining(buffer *strings.Builder) {
+func (c *OpenAIClient) flushRemaining(ctx context.Context, buffer *strings.Builder) {
     leftover := strings.TrimSpace(buffer.String())
     if leftover != "" {
-        c.StreamingChannel <- leftover
+        c.sendSentence(ctx, leftover)
+    }
+}
+
+// sendSentence hands one sentence of the reply to the speech side, unless
+// ctx ends first.
+func (c *OpenAIClient) sendSentence(ctx context.Context, s string) {
+    if c.OnSentence != nil {
+        c.OnSentence()
+    }
+    select {
+    case c.StreamingChannel <- s:
+    case <-ctx.Done():
     }
 }
\ No newline at end of file
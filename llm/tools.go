@@ -0,0 +1,124 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// maxToolRounds bounds how many times one reply may go back to the model
+// with tool results, so a model stuck calling tools cannot loop forever.
+const maxToolRounds = 4
+
+// ToolHandler runs one tool call. arguments is the JSON object the model
+// produced; the returned string is handed back to the model as the result.
+type ToolHandler func(ctx context.Context, arguments string) (string, error)
+
+// Tool is a function the model may call while composing a reply.
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the arguments
+	Parameters any
+	Handler    ToolHandler
+}
+
+// RegisterTool makes a tool available to the model on every following turn.
+func (c *OpenAIClient) RegisterTool(tool Tool) error {
+	if tool.Name == "" {
+		return fmt.Errorf("tool name is required")
+	}
+	if tool.Handler == nil {
+		return fmt.Errorf("tool handler is required")
+	}
+	if c.Tools == nil {
+		c.Tools = map[string]Tool{}
+	}
+	c.Tools[tool.Name] = tool
+	return nil
+}
+
+// toolDefinitions lists the registered tools in the form the API expects.
+func (c *OpenAIClient) toolDefinitions() []openai.Tool {
+	if len(c.Tools) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(c.Tools))
+	for name := range c.Tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	defs := make([]openai.Tool, 0, len(names))
+	for _, name := range names {
+		tool := c.Tools[name]
+		defs = append(defs, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	return defs
+}
+
+// runTools executes the tool calls of the last assistant message and appends
+// their results to the conversation. Failures are reported to the model
+// rather than aborting the turn.
+func (c *OpenAIClient) runTools(ctx context.Context, calls []openai.ToolCall) {
+	for _, call := range calls {
+		var result string
+		started := time.Now()
+		tool, ok := c.Tools[call.Function.Name]
+		if !ok {
+			result = fmt.Sprintf("error: unknown tool %q", call.Function.Name)
+		} else {
+			c.Log.Info("Calling tool", "tool", call.Function.Name)
+			out, err := tool.Handler(ctx, call.Function.Arguments)
+			if err != nil {
+				c.Log.Warn("Tool failed", "tool", call.Function.Name, "error", err)
+				result = "error: " + err.Error()
+			} else {
+				result = out
+			}
+		}
+		if c.OnToolCall != nil {
+			c.OnToolCall(call.Function.Name, call.Function.Arguments, result, time.Since(started))
+		}
+		c.Messages = append(c.Messages, openai.ChatCompletionMessage{
+			Role:       openai.ChatMessageRoleTool,
+			Content:    result,
+			ToolCallID: call.ID,
+		})
+	}
+}
+
+// toolCallAccumulator reassembles tool calls that arrive in fragments
+// across stream chunks.
+type toolCallAccumulator struct {
+	calls []openai.ToolCall
+}
+
+func (a *toolCallAccumulator) add(deltas []openai.ToolCall) {
+	for _, delta := range deltas {
+		index := len(a.calls)
+		if delta.Index != nil {
+			index = *delta.Index
+		}
+		for len(a.calls) <= index {
+			a.calls = append(a.calls, openai.ToolCall{Type: openai.ToolTypeFunction})
+		}
+		call := &a.calls[index]
+		if delta.ID != "" {
+			call.ID = delta.ID
+		}
+		if delta.Function.Name != "" {
+			call.Function.Name = delta.Function.Name
+		}
+		call.Function.Arguments += delta.Function.Arguments
+	}
+}
@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/mrsingh-rishi/voice-bot/redact"
+)
+
+// digitKeys are attributes holding keypad digits, which are masked whole.
+// "keys" are the digits pressed on the far end's menus, which may include
+// a caller's PIN.
+var digitKeys = map[string]bool{"digit": true, "digits": true, "keys": true}
+
+// handler filters records by the level of their subsystem and masks
+// personal data before passing them on.
+type handler struct {
+	inner  slog.Handler
+	levels Levels
+	level  slog.Level
+	// dynamic are attributes whose values change over the logger's life,
+	// such as a call's IDs, so they are resolved for every record instead
+	// of once
+	dynamic []slog.Attr
+}
+
+func newHandler(inner slog.Handler, levels Levels) *handler {
+	return &handler{inner: inner, levels: levels, level: levels.Default}
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	masked := slog.NewRecord(r.Time, r.Level, redact.String(r.Message), r.PC)
+	for _, a := range h.dynamic {
+		masked.AddAttrs(maskAttr(a))
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		masked.AddAttrs(maskAttr(a))
+		return true
+	})
+	return h.inner.Handle(ctx, masked)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	derived := *h
+	derived.dynamic = append([]slog.Attr(nil), h.dynamic...)
+	var static []slog.Attr
+	for _, a := range attrs {
+		if a.Key == SubsystemKey {
+			derived.level = h.levels.For(a.Value.String())
+		}
+		if a.Value.Kind() == slog.KindLogValuer {
+			derived.dynamic = append(derived.dynamic, a)
+			continue
+		}
+		static = append(static, maskAttr(a))
+	}
+	derived.inner = h.inner.WithAttrs(static)
+	return &derived
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	derived := *h
+	derived.inner = h.inner.WithGroup(name)
+	return &derived
+}
+
+// maskAttr masks personal data in a's value.
+func maskAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	switch {
+	case digitKeys[a.Key]:
+		a.Value = slog.StringValue(redact.Digits(a.Value.String()))
+	case a.Value.Kind() == slog.KindString:
+		a.Value = slog.StringValue(redact.String(a.Value.String()))
+	case a.Value.Kind() == slog.KindGroup:
+		group := a.Value.Group()
+		masked := make([]slog.Attr, len(group))
+		for i, member := range group {
+			masked[i] = maskAttr(member)
+		}
+		a.Value = slog.GroupValue(masked...)
+	case a.Value.Kind() == slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(redact.String(err.Error()))
+		}
+	}
+	return a
+}
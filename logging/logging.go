@@ -0,0 +1,79 @@
+// Package logging sets up structured logging: one slog handler for the
+// whole process, with a level per subsystem and personal data masked in
+// every record. Output of the standard log package goes through it too.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// SubsystemKey is the attribute naming the part of the bot a record comes
+// from, e.g. "stt" or "call".
+const SubsystemKey = "subsystem"
+
+// Levels is the minimum level logged, overridable per subsystem.
+type Levels struct {
+	Default    slog.Level
+	Subsystems map[string]slog.Level
+}
+
+// For returns the level of subsystem.
+func (l Levels) For(subsystem string) slog.Level {
+	if level, ok := l.Subsystems[subsystem]; ok {
+		return level
+	}
+	return l.Default
+}
+
+// ParseLevels reads a default level such as "info" and per-subsystem
+// overrides such as "stt=debug,llm=warn". Empty strings mean info and no
+// overrides.
+func ParseLevels(defaultLevel string, overrides string) (Levels, error) {
+	levels := Levels{Subsystems: map[string]slog.Level{}}
+	if defaultLevel != "" {
+		if err := levels.Default.UnmarshalText([]byte(defaultLevel)); err != nil {
+			return Levels{}, fmt.Errorf("invalid log level %q", defaultLevel)
+		}
+	}
+	for _, override := range strings.Split(overrides, ",") {
+		override = strings.TrimSpace(override)
+		if override == "" {
+			continue
+		}
+		subsystem, name, ok := strings.Cut(override, "=")
+		if !ok || subsystem == "" {
+			return Levels{}, fmt.Errorf("invalid log level override %q, want subsystem=level", override)
+		}
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(name)); err != nil {
+			return Levels{}, fmt.Errorf("invalid log level %q for %s", name, subsystem)
+		}
+		levels.Subsystems[strings.TrimSpace(subsystem)] = level
+	}
+	return levels, nil
+}
+
+// Setup makes the default logger write to w in format, "text" or "json",
+// at levels.
+func Setup(w io.Writer, format string, levels Levels) error {
+	var inner slog.Handler
+	options := &slog.HandlerOptions{Level: slog.LevelDebug}
+	switch format {
+	case "", "text":
+		inner = slog.NewTextHandler(w, options)
+	case "json":
+		inner = slog.NewJSONHandler(w, options)
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	slog.SetDefault(slog.New(newHandler(inner, levels)))
+	return nil
+}
+
+// Logger returns the logger of a subsystem.
+func Logger(subsystem string) *slog.Logger {
+	return slog.Default().With(SubsystemKey, subsystem)
+}
@@ -1,20 +1,48 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/xml"
+	"errors"
 	"fmt"
-	"log"
+	"net/url"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
 	"github.com/gofiber/websocket/v2"
 	"github.com/joho/godotenv"
+	"github.com/mrsingh-rishi/voice-bot/auth"
 	"github.com/mrsingh-rishi/voice-bot/call"
-	twilio "github.com/twilio/twilio-go"
+	"github.com/mrsingh-rishi/voice-bot/logging"
+	"github.com/mrsingh-rishi/voice-bot/sip"
+	"github.com/mrsingh-rishi/voice-bot/tracing"
+	"github.com/mrsingh-rishi/voice-bot/transport"
+	"github.com/mrsingh-rishi/voice-bot/workspace"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	openapi "github.com/twilio/twilio-go/rest/api/v2010"
 )
 
+// webPage is the browser test page served at /web.
+//
+//go:embed web/index.html
+var webPage []byte
+
 type callRequest struct {
 	To string `json:"to"`
+	// From is one of the workspace's numbers; empty uses its first
+	From  string `json:"from,omitempty"`
+	Agent string `json:"agent,omitempty"`
+	// Variables describe this call (customer name, order number, ...) to the agent
+	Variables map[string]string `json:"variables,omitempty"`
 }
 
 type callResponse struct {
@@ -22,6 +50,29 @@ type callResponse struct {
 	Message string `json:"message"`
 }
 
+type apiKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// Workspace defaults to the admin's own, or the default workspace for
+	// the bootstrap admin key, which is the only one that may name another
+	Workspace string `json:"workspace,omitempty"`
+}
+
+type workspaceResponse struct {
+	ID           string           `json:"id"`
+	Name         string           `json:"name"`
+	PhoneNumbers []string         `json:"phone_numbers"`
+	Agents       []string         `json:"agents"`
+	Limits       workspace.Limits `json:"limits"`
+	Usage        workspace.Usage  `json:"usage"`
+}
+
+type apiKeyResponse struct {
+	Key auth.Key `json:"key"`
+	// Token is the API key itself; it cannot be retrieved again
+	Token string `json:"token"`
+}
+
 // Twilio's streaming payload
 type twilioEvent struct {
 	Event string `json:"event"` // "start", "media", "stop"
@@ -37,12 +88,68 @@ type twilioEvent struct {
 
 func main() {
 	// Load .env if present
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, falling back to environment variables")
+	envErr := godotenv.Load()
+	// LOG_LEVEL is the default level, LOG_LEVELS overrides it per subsystem,
+	// e.g. "stt=debug,llm=warn"
+	levels, err := logging.ParseLevels(os.Getenv("LOG_LEVEL"), os.Getenv("LOG_LEVELS"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := logging.Setup(os.Stderr, os.Getenv("LOG_FORMAT"), levels); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-	// PORT
+	if envErr != nil {
+		logging.Logger("server").Info("No .env file found, falling back to environment variables")
+	}
+	shutdownTracing, err := tracing.Setup(context.Background(), os.Getenv("OTEL_TRACES_EXPORTER"))
+	if err != nil {
+		fatal("Failed to set up tracing", "error", err)
+	}
+	// exit flushes the spans still waiting to be exported
+	exit := func(code int) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logging.Logger("server").Warn("Failed to flush traces", "error", err)
+		}
+		os.Exit(code)
+	}
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "simulate":
+			exit(runSimulate(os.Args[2:]))
+		case "chat":
+			exit(runChat(os.Args[2:]))
+		}
+	}
+	app := newApp()
+
+	// Stop serving on Ctrl-C or SIGTERM, so pending traces are flushed
+	signals, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-signals.Done()
+		app.Shutdown()
+	}()
+
+	// Start server
 	port := os.Getenv("PORT")
+	if port == "" {
+		port = "3000"
+	}
+	addr := "0.0.0.0:" + port
+	fmt.Printf("Fiber server listening on %s\n", addr)
+	if err := app.Listen(addr); err != nil {
+		logging.Logger("server").Error("Server failed", "error", err)
+		exit(1)
+	}
+	exit(0)
+}
 
+// newApp builds the server from the environment.
+func newApp() *fiber.App {
 	// Twilio config
 	accountSid := os.Getenv("TWILIO_ACCOUNT_SID")
 	authToken := os.Getenv("TWILIO_AUTH_TOKEN")
@@ -54,29 +161,36 @@ func main() {
 	elevenLabsApiKey := os.Getenv("ELEVEN_LABS_API_KEY")
 	baseUrl = os.Getenv("BASE_URL")
 	baseWsUrl = os.Getenv("BASE_WS_URL")
-	if accountSid == "" || authToken == "" || fromNumber == "" {
-		log.Fatal("TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN and TWILIO_FROM_NUMBER must be set")
+	// With the SIP gateway on, a PBX can send calls without Twilio; only
+	// outbound calls through POST /call need it then
+	sipAddr := os.Getenv("SIP_ADDR")
+	// Workspaces other than the default one are JSON files in WORKSPACES_DIR,
+	// each with its own Twilio account
+	workspacesDir := os.Getenv("WORKSPACES_DIR")
+	twilioConfigured := accountSid != "" && authToken != "" && fromNumber != ""
+	if !twilioConfigured && sipAddr == "" && workspacesDir == "" {
+		fatal("TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN and TWILIO_FROM_NUMBER must be set")
 	}
 	if baseWsUrl == "" {
-		log.Fatal("BASE_WS_URL must be set")
+		fatal("BASE_WS_URL must be set")
 	}
 	if deepgramApiKey == "" {
-		log.Fatal("DEEPGRAM_API_KEY must be set")
+		fatal("DEEPGRAM_API_KEY must be set")
 	}
 	if baseUrl == "" {
-		log.Fatal("BASE_URL must be set")
+		fatal("BASE_URL must be set")
 	}
 	if openaiApiKey == "" {
-		log.Fatal("OPEN_AI_API_KEY must be set")
+		fatal("OPEN_AI_API_KEY must be set")
 	}
 	if elevenLabsApiKey == "" {
-		log.Fatal("ELEVEN_LABS_API_KEY must be set")
+		fatal("ELEVEN_LABS_API_KEY must be set")
 	}
 	if baseUrl == "" {
-		log.Fatal("BASE_URL must be set")
+		fatal("BASE_URL must be set")
 	}
 	if baseWsUrl == "" {
-		log.Fatal("BASE_WS_URL must be set")
+		fatal("BASE_WS_URL must be set")
 	}
 	if baseUrl[len(baseUrl)-1:] != "/" {
 		baseUrl += "/"
@@ -85,13 +199,119 @@ func main() {
 		baseWsUrl += "/"
 	}
 
-	log.Printf("Server Running on %s", baseUrl)
-	log.Printf("WebSocket URL: %s", baseWsUrl)
-	// Init Twilio client
-	client := twilio.NewRestClientWithParams(twilio.ClientParams{
-		Username: accountSid,
-		Password: authToken,
+	// Vendor API roots can be overridden, e.g. for self-hosted or simulated vendors
+	endpoints := call.Endpoints{
+		Deepgram:   os.Getenv("DEEPGRAM_URL"),
+		OpenAI:     os.Getenv("OPEN_AI_BASE_URL"),
+		ElevenLabs: os.Getenv("ELEVEN_LABS_BASE_URL"),
+	}
+
+	// The default workspace runs on the server's own Twilio account, number,
+	// agents and vendor keys, which other workspaces fall back to
+	defaultWorkspace := workspace.Config{
+		ID:        workspace.DefaultID,
+		Name:      "Default",
+		AgentsDir: os.Getenv("AGENTS_DIR"),
+		Webhooks:  workspace.WebhookConfig{URL: os.Getenv("WEBHOOK_URL")},
+	}
+	if twilioConfigured {
+		defaultWorkspace.Twilio = workspace.TwilioConfig{AccountSID: accountSid, AuthTokenEnv: "TWILIO_AUTH_TOKEN"}
+		defaultWorkspace.PhoneNumbers = []string{fromNumber}
+	}
+	if os.Getenv("WEBHOOK_SECRET") != "" {
+		defaultWorkspace.Webhooks.SecretEnv = "WEBHOOK_SECRET"
+	}
+	workspaces, err := workspace.LoadRegistry(workspacesDir, defaultWorkspace, workspace.Keys{
+		Deepgram:   deepgramApiKey,
+		OpenAI:     openaiApiKey,
+		ElevenLabs: elevenLabsApiKey,
 	})
+	if err != nil {
+		fatal("Failed to load workspaces", "error", err)
+	}
+
+	// TTS phrase cache of each workspace, holding warmed phrases and fillers
+	// only: in-memory LRU, plus a disk tier under TTS_CACHE_DIR/<workspace>
+	// when it is set
+	cacheMB, err := strconv.Atoi(os.Getenv("TTS_CACHE_MEMORY_MB"))
+	if err != nil || cacheMB <= 0 {
+		cacheMB = 64
+	}
+	tenants := tenantRegistry{}
+	for _, w := range workspaces.All() {
+		t, err := newTenant(w, tenantOptions{
+			endpoints:  endpoints,
+			cacheBytes: int64(cacheMB) << 20,
+			cacheDir:   os.Getenv("TTS_CACHE_DIR"),
+		})
+		if err != nil {
+			fatal("Failed to set up workspace", "workspace", w.ID, "error", err)
+		}
+		tenants[w.ID] = t
+		go t.warm()
+	}
+
+	// API authentication: keys created through /admin/keys, stored hashed in
+	// API_KEYS_FILE (in memory without it), JWTs signed with JWT_SECRET, and
+	// ADMIN_API_KEY to create the first keys. Keys and JWTs belong to a
+	// workspace and only reach its calls, agents and keys
+	apiKeys, err := auth.NewKeyStore(os.Getenv("API_KEYS_FILE"))
+	if err != nil {
+		fatal("Failed to load API keys", "error", err)
+	}
+	authenticator := &auth.Authenticator{
+		Keys:      apiKeys,
+		JWTSecret: []byte(os.Getenv("JWT_SECRET")),
+		AdminKey:  os.Getenv("ADMIN_API_KEY"),
+	}
+	if authenticator.AdminKey == "" && len(authenticator.JWTSecret) == 0 && len(apiKeys.List("")) == 0 {
+		logging.Logger("auth").Warn("No ADMIN_API_KEY, JWT_SECRET or API keys configured: the management APIs will refuse every request")
+	}
+	// withTenant puts the authenticated workspace's tenant on the request
+	withTenant := func(c *fiber.Ctx) error {
+		t, ok := tenants.forRequest(c)
+		if !ok {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "unknown workspace"})
+		}
+		c.Locals(tenantKey, t)
+		return c.Next()
+	}
+	// Twilio requests carry no credentials of ours; twilioTenant finds their
+	// workspace and they must be signed with its account's auth token
+	twilioTenant := func(c *fiber.Ctx) error {
+		t, ok := tenants.forTwilio(c)
+		if !ok {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "unknown workspace"})
+		}
+		c.Locals(tenantKey, t)
+		return c.Next()
+	}
+	twilioToken := func(c *fiber.Ctx) string {
+		return c.Locals(tenantKey).(*tenant).TwilioAuthToken
+	}
+	twilioWebhook := auth.TwilioWebhook(baseUrl, twilioToken)
+	twilioStream := auth.TwilioWebhook(baseWsUrl, twilioToken)
+
+	// SIP gateway: calls from a PBX, routed to the agent named by the user
+	// part of the request URI, e.g. sip:support@bot, in the workspace named
+	// by SIP_WORKSPACE
+	if sipAddr != "" {
+		sipTenant, ok := tenants.get(os.Getenv("SIP_WORKSPACE"))
+		if !ok {
+			fatal("Unknown SIP_WORKSPACE", "workspace", os.Getenv("SIP_WORKSPACE"))
+		}
+		gateway, err := sip.NewServer(sipAddr, os.Getenv("SIP_PUBLIC_IP"))
+		if err != nil {
+			fatal("Failed to start SIP gateway", "error", err)
+		}
+		gateway.OnCall = func(session *sip.Session) {
+			sipTenant.runCall("sip", session, sipTenant.agent(session.Agent), nil)
+		}
+		logging.Logger("sip").Info("SIP gateway listening", "addr", gateway.Addr().String())
+		go gateway.Serve()
+	}
+
+	logging.Logger("server").Info("Server running", "url", baseUrl, "ws_url", baseWsUrl)
 
 	// Fiber app
 	app := fiber.New()
@@ -101,48 +321,239 @@ func main() {
 	})
 
 	// POST /call — kicks off outbound call & points TwiML at /twiml
-	app.Post("/call", func(c *fiber.Ctx) error {
+	app.Post("/call", authenticator.Require(auth.ScopeCalls), withTenant, func(c *fiber.Ctx) error {
+		t := c.Locals(tenantKey).(*tenant)
 		var req callRequest
 		if err := c.BodyParser(&req); err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid JSON"})
 		}
+		if !t.HasTwilio() {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "outbound calls need Twilio credentials"})
+		}
 		if req.To == "" {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "`to` field is required"})
 		}
+		if _, ok := t.agents.Get(req.Agent); !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "unknown agent"})
+		}
+		from := req.From
+		if from == "" {
+			from = t.PhoneNumbers[0]
+		}
+		if !t.OwnsNumber(from) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "`from` is not a number of this workspace"})
+		}
+		if err := t.CheckLimits(); err != nil {
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": err.Error()})
+		}
 
+		twimlQuery := url.Values{"workspace": {t.ID}, "agent": {req.Agent}}
 		params := &openapi.CreateCallParams{}
 		params.SetTo(req.To)
-		params.SetFrom(fromNumber)
-		params.SetUrl(fmt.Sprintf("%stwiml", baseUrl))
+		params.SetFrom(from)
+		params.SetUrl(fmt.Sprintf("%stwiml?%s", baseUrl, twimlQuery.Encode()))
 		params.SetMethod("GET")
 
-		resp, err := client.Api.CreateCall(params)
+		resp, err := t.twilio.Api.CreateCall(params)
 		if err != nil {
-			log.Printf("Twilio error: %v", err)
+			logging.Logger("call").Error("Failed to create Twilio call", "workspace", t.ID, "error", err)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to create call"})
 		}
 
+		t.callVariables.Put(*resp.Sid, req.Variables)
 		return c.JSON(callResponse{SID: *resp.Sid, Message: "call initiated"})
 	})
 
 	// GET /twiml — returns the TwiML instructing Twilio to stream to /stream
-	app.Get("/twiml", func(c *fiber.Ctx) error {
+	app.Get("/twiml", twilioTenant, twilioWebhook, func(c *fiber.Ctx) error {
+		t := c.Locals(tenantKey).(*tenant)
 		callSid := c.Query("CallSid", "")
 		if callSid == "" {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "CallSid missing"})
 		}
 
-		xml := fmt.Sprintf(`
+		streamQuery := url.Values{"CallSid": {callSid}, "workspace": {t.ID}}
+		if agentID := c.Query("agent", ""); agentID != "" {
+			streamQuery.Set("agent", agentID)
+		}
+		var streamUrl bytes.Buffer
+		xml.EscapeText(&streamUrl, []byte(fmt.Sprintf("%sstream?%s", baseWsUrl, streamQuery.Encode())))
+
+		twiml := fmt.Sprintf(`
 <Response>
   <Connect>
-    <Stream url="%sstream?CallSid=%s" bidirectional="true"/>
+    <Stream url="%s" bidirectional="true"/>
   </Connect>
-</Response>`, baseWsUrl, callSid)
+</Response>`, streamUrl.String())
 
 		c.Type("xml")
-		return c.SendString(xml)
+		return c.SendString(twiml)
 	})
 
+	// GET /workspace — the authenticated workspace, its limits and usage
+	app.Get("/workspace", authenticator.Require(auth.ScopeMonitoring), withTenant, func(c *fiber.Ctx) error {
+		t := c.Locals(tenantKey).(*tenant)
+		agentIDs := make([]string, 0)
+		for _, agentConfig := range t.agents.All() {
+			agentIDs = append(agentIDs, agentConfig.ID)
+		}
+		sort.Strings(agentIDs)
+		return c.JSON(workspaceResponse{
+			ID:           t.ID,
+			Name:         t.Name,
+			PhoneNumbers: t.PhoneNumbers,
+			Agents:       agentIDs,
+			Limits:       t.Limits,
+			Usage:        t.Usage(),
+		})
+	})
+
+	// GET /tts/cache — phrase cache hit/miss counters
+	app.Get("/tts/cache", authenticator.Require(auth.ScopeMonitoring), withTenant, func(c *fiber.Ctx) error {
+		return c.JSON(c.Locals(tenantKey).(*tenant).deps.PhraseCache.Stats())
+	})
+
+	// GET /health/providers — circuit breaker state of every STT, LLM and TTS provider
+	app.Get("/health/providers", authenticator.Require(auth.ScopeMonitoring), withTenant, func(c *fiber.Ctx) error {
+		return c.JSON(c.Locals(tenantKey).(*tenant).deps.Breakers.Statuses())
+	})
+
+	// GET /metrics — Prometheus metrics. They cover every workspace, so only
+	// the bootstrap admin key and keys of the default workspace may read them
+	metricsHandler := adaptor.HTTPHandler(promhttp.Handler())
+	app.Get("/metrics", authenticator.Require(auth.ScopeMonitoring), func(c *fiber.Ctx) error {
+		if principal, _ := auth.PrincipalFrom(c); principal.Workspace != "" && principal.Workspace != workspace.DefaultID {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "metrics are not available to workspace keys"})
+		}
+		return metricsHandler(c)
+	})
+
+	// Admin API for API keys; a key is only ever shown in the response that
+	// creates it. Admins of a workspace manage its keys; the bootstrap admin
+	// key manages every workspace's
+	admin := app.Group("/admin", authenticator.Require(auth.ScopeAdmin))
+
+	// POST /admin/keys — {"name": "...", "scopes": ["calls", ...], "workspace": "..."}
+	admin.Post("/keys", func(c *fiber.Ctx) error {
+		var req apiKeyRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid JSON"})
+		}
+		principal, _ := auth.PrincipalFrom(c)
+		workspaceID := req.Workspace
+		if workspaceID == "" {
+			workspaceID = principal.Workspace
+		}
+		if principal.Workspace != "" && workspaceID != principal.Workspace {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "keys can only be created in your own workspace"})
+		}
+		if workspaceID == "" {
+			workspaceID = workspace.DefaultID
+		}
+		if _, ok := tenants.get(workspaceID); !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "unknown workspace"})
+		}
+		key, token, err := apiKeys.Create(workspaceID, req.Name, req.Scopes)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		logging.Logger("auth").Info("API key created", "key", key.ID, "name", key.Name, "workspace", key.Workspace, "scopes", key.Scopes, "by", principal.Subject)
+		return c.Status(fiber.StatusCreated).JSON(apiKeyResponse{Key: key, Token: token})
+	})
+
+	// GET /admin/keys — the keys of the admin's workspace, without secrets
+	admin.Get("/keys", func(c *fiber.Ctx) error {
+		principal, _ := auth.PrincipalFrom(c)
+		return c.JSON(apiKeys.List(principal.Workspace))
+	})
+
+	// DELETE /admin/keys/:id — revokes a key
+	admin.Delete("/keys/:id", func(c *fiber.Ctx) error {
+		principal, _ := auth.PrincipalFrom(c)
+		if err := apiKeys.Revoke(principal.Workspace, c.Params("id")); err != nil {
+			if errors.Is(err, auth.ErrKeyNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+			}
+			logging.Logger("auth").Error("Failed to revoke API key", "key", c.Params("id"), "error", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to revoke key"})
+		}
+		logging.Logger("auth").Info("API key revoked", "key", c.Params("id"), "by", principal.Subject)
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	// Middleware to require WebSocket upgrade on /chat
+	app.Use("/chat", func(c *fiber.Ctx) error {
+		if !websocket.IsWebSocketUpgrade(c) {
+			return fiber.ErrUpgradeRequired
+		}
+		return c.Next()
+	})
+
+	// WebSocket handler for text chats with an agent: typed messages in,
+	// sentences, tool calls and timings out
+	app.Get("/chat", authenticator.Require(auth.ScopeSessions), withTenant, websocket.New(func(ws *websocket.Conn) {
+		t := ws.Locals(tenantKey).(*tenant)
+		agentConfig, ok := t.agents.Get(ws.Query("agent"))
+		if !ok {
+			ws.WriteJSON(fiber.Map{"type": "error", "error": "unknown agent"})
+			return
+		}
+		end, err := t.StartCall()
+		if err != nil {
+			ws.WriteJSON(fiber.Map{"type": "error", "error": err.Error()})
+			return
+		}
+		defer end()
+		chat, err := call.NewChat(agentConfig, t.deps)
+		if err != nil {
+			logging.Logger("call").Error("Error creating chat", "workspace", t.ID, "agent", agentConfig.ID, "error", err)
+			ws.WriteJSON(fiber.Map{"type": "error", "error": "failed to start chat"})
+			return
+		}
+		defer chat.Close()
+
+		var writeMu sync.Mutex
+		write := func(v interface{}) {
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			if err := ws.WriteJSON(v); err != nil {
+				logging.Logger("call").Warn("Chat write error", "error", err)
+			}
+		}
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			for {
+				select {
+				case <-done:
+					return
+				case ev := <-chat.Events():
+					write(ev)
+				}
+			}
+		}()
+		chat.Start()
+
+		for {
+			var msg chatMessage
+			if err := ws.ReadJSON(&msg); err != nil {
+				return
+			}
+			switch msg.Type {
+			case "text":
+				chat.Say(msg.Text)
+			case "keys":
+				if err := chat.Press(msg.Keys); err != nil {
+					write(fiber.Map{"type": "error", "error": err.Error()})
+				}
+			case "interrupt":
+				chat.Interrupt()
+			default:
+				write(fiber.Map{"type": "error", "error": fmt.Sprintf("unknown message type %q", msg.Type)})
+			}
+		}
+	}))
+
 	// Middleware to require WebSocket upgrade on /stream
 	app.Use("/stream", func(c *fiber.Ctx) error {
 		if !websocket.IsWebSocketUpgrade(c) {
@@ -153,30 +564,60 @@ func main() {
 	})
 
 	// WebSocket handler for Twilio media
-	app.Get("/stream", websocket.New(func(ws *websocket.Conn) {
+	app.Get("/stream", twilioTenant, twilioStream, websocket.New(func(ws *websocket.Conn) {
 		// Ensure the connection is properly upgraded
 		if ws.Conn == nil {
-			log.Println("WebSocket connection not properly upgraded")
+			logging.Logger("transport").Warn("WebSocket connection not properly upgraded")
 			return
 		}
 
-		log.Println("WebSocket connection established")
+		logging.Logger("transport").Info("WebSocket connection established", "carrier", "twilio")
+
+		t := ws.Locals(tenantKey).(*tenant)
+		// **Block** here — the call reads from `ws` until the stream ends
+		t.runCall("twilio", transport.NewTwilio(ws), t.agent(ws.Query("agent")), t.callVariables.Take(ws.Query("CallSid")))
+	}))
 
-		call, err := call.NewCall(ws)
+	// WebSocket handler for the media streams of other carriers, e.g.
+	// /stream/vonage; the carrier's call setup points its stream here, with
+	// an API key in the token query parameter
+	app.Get("/stream/:carrier", authenticator.Require(auth.ScopeSessions), withTenant, websocket.New(func(ws *websocket.Conn) {
+		t := ws.Locals(tenantKey).(*tenant)
+		carrier := ws.Params("carrier")
+		tr, err := transport.New(carrier, ws)
 		if err != nil {
-			log.Printf("Error creating call: %v", err)
+			logging.Logger("transport").Warn("Rejecting media stream", "carrier", carrier, "error", err)
 			return
 		}
-        // **Block** here — Start() will itself read from `ws` in StartReceivingAudio
-        defer call.CleanupResources()
-        call.Start()
+		t.runCall(carrier, tr, t.agent(ws.Query("agent")), nil)
 	}))
 
-	// Start server
-	if port == "" {
-		port = "3000"
-	}
-	addr := "0.0.0.0:" + port
-	fmt.Printf("Fiber server listening on %s\n", addr)
-	log.Fatal(app.Listen(addr))
+	// GET /web — a test page that talks to an agent through /browser
+	app.Get("/web", func(c *fiber.Ctx) error {
+		c.Type("html")
+		return c.Send(webPage)
+	})
+
+	// Middleware to require WebSocket upgrade on /browser
+	app.Use("/browser", func(c *fiber.Ctx) error {
+		if !websocket.IsWebSocketUpgrade(c) {
+			return fiber.ErrUpgradeRequired
+		}
+		return c.Next()
+	})
+
+	// WebSocket handler for browsers and web apps: PCM16 16 kHz audio, see
+	// transport.Browser for the protocol
+	app.Get("/browser", authenticator.Require(auth.ScopeSessions), withTenant, websocket.New(func(ws *websocket.Conn) {
+		t := ws.Locals(tenantKey).(*tenant)
+		t.runCall("browser", transport.NewBrowser(ws), t.agent(ws.Query("agent")), nil)
+	}))
+
+	return app
+}
+
+// fatal logs a startup error the server cannot run with and exits.
+func fatal(msg string, args ...any) {
+	logging.Logger("server").Error(msg, args...)
+	os.Exit(1)
 }
@@ -0,0 +1,105 @@
+// Package metrics holds the bot's Prometheus metrics: where the time of a
+// reply goes, what becomes of calls, and what goes wrong on the way. They
+// are registered with the default registry, which also exports the Go
+// runtime's metrics such as go_goroutines.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Stages of a reply, from the caller's final transcript to the first audio
+// sent back.
+const (
+	StageSTTToLLM      = "stt_final_to_llm_first_token"
+	StageLLMToSentence = "llm_first_token_to_first_sentence"
+	StageSentenceToTTS = "first_sentence_to_tts_first_byte"
+	StageTTSToMedia    = "tts_first_byte_to_first_media"
+)
+
+// Call outcomes.
+const (
+	// OutcomeCompleted calls were hung up by the far end
+	OutcomeCompleted = "completed"
+	// OutcomeMaxDuration calls were wrapped up at the agent's time limit
+	OutcomeMaxDuration = "max_duration"
+	// OutcomeNoResponse calls were hung up after unanswered reprompts
+	OutcomeNoResponse = "no_response"
+	// OutcomeSTTFailure calls ended because speech recognition was lost
+	OutcomeSTTFailure = "stt_failure"
+	// OutcomeRejected calls were turned away by a workspace limit
+	OutcomeRejected = "rejected"
+	// OutcomeFailed calls could not be set up
+	OutcomeFailed = "failed"
+)
+
+// Reasons audio chunks are dropped.
+const (
+	// DropNotStarted is caller audio that arrived before the stream started
+	DropNotStarted = "not_started"
+	// DropSTTBuffer is caller audio buffered for a reconnecting STT
+	// connection that did not fit
+	DropSTTBuffer = "stt_buffer_full"
+	// DropCleared is agent audio discarded because the caller barged in
+	DropCleared = "cleared"
+	// DropWriteError is agent audio the transport failed to send
+	DropWriteError = "write_error"
+	// DropOutOfOrder is late or duplicated RTP
+	DropOutOfOrder = "out_of_order"
+)
+
+// latencyBuckets span the few milliseconds of local hand-offs up to the
+// seconds of a slow vendor.
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, .75, 1, 1.5, 2, 3, 5, 10}
+
+var (
+	// StageDuration is how long each stage of a reply takes.
+	StageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "voicebot",
+		Name:      "stage_duration_seconds",
+		Help:      "Time spent in each stage of a reply, per turn.",
+		Buckets:   latencyBuckets,
+	}, []string{"stage"})
+
+	// ResponseLatency is what the caller experiences: from when they stop
+	// speaking to when the first audio of the reply is sent.
+	ResponseLatency = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "voicebot",
+		Name:      "response_latency_seconds",
+		Help:      "Time from the caller stopping speaking to the first audio of the reply being sent.",
+		Buckets:   latencyBuckets,
+	})
+
+	Calls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "voicebot",
+		Name:      "calls_total",
+		Help:      "Calls by workspace, channel and outcome.",
+	}, []string{"workspace", "channel", "outcome"})
+
+	ActiveCalls = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "voicebot",
+		Name:      "active_calls",
+		Help:      "Calls in progress by workspace.",
+	}, []string{"workspace"})
+
+	Interruptions = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "voicebot",
+		Name:      "interruptions_total",
+		Help:      "Times the caller barged in on the agent.",
+	})
+
+	// VendorErrors counts failed requests to STT, LLM and TTS providers,
+	// by the provider's breaker name, e.g. "tts:elevenlabs".
+	VendorErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "voicebot",
+		Name:      "vendor_errors_total",
+		Help:      "Failed requests to STT, LLM and TTS providers.",
+	}, []string{"provider"})
+
+	DroppedAudioChunks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "voicebot",
+		Name:      "dropped_audio_chunks_total",
+		Help:      "Audio chunks dropped, by reason.",
+	}, []string{"reason"})
+)
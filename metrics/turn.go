@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// Turn times one reply through the pipeline. Start begins a turn; each
+// later point is recorded once per turn, and observes the stage ending
+// there when the point before it was reached. Points reached outside a
+// turn, e.g. while the greeting plays, are ignored. A nil Turn records
+// nothing.
+type Turn struct {
+	mu            sync.Mutex
+	speechEnd     time.Time
+	final         time.Time
+	firstToken    time.Time
+	firstSentence time.Time
+	firstAudio    time.Time
+	firstMedia    time.Time
+}
+
+func NewTurn() *Turn {
+	return &Turn{}
+}
+
+// Start begins a turn when the caller's final transcript goes to the LLM.
+// speechEnd is when the caller stopped speaking; zero when it is unknown.
+func (t *Turn) Start(speechEnd time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.speechEnd = speechEnd
+	t.final = time.Now()
+	t.firstToken = time.Time{}
+	t.firstSentence = time.Time{}
+	t.firstAudio = time.Time{}
+	t.firstMedia = time.Time{}
+}
+
+// FirstToken records the LLM's first token of the reply.
+func (t *Turn) FirstToken() {
+	t.reach(&t.firstToken, &t.final, StageSTTToLLM)
+}
+
+// FirstSentence records the first sentence of the reply going to TTS.
+func (t *Turn) FirstSentence() {
+	t.reach(&t.firstSentence, &t.firstToken, StageLLMToSentence)
+}
+
+// FirstAudio records the first synthesized audio of the reply.
+func (t *Turn) FirstAudio() {
+	t.reach(&t.firstAudio, &t.firstSentence, StageSentenceToTTS)
+}
+
+// FirstMedia records the first audio of the reply sent to the far end,
+// which is also when the caller's wait ends.
+func (t *Turn) FirstMedia() {
+	if !t.reach(&t.firstMedia, &t.firstAudio, StageTTSToMedia) {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	from := t.speechEnd
+	if from.IsZero() {
+		from = t.final
+	}
+	ResponseLatency.Observe(t.firstMedia.Sub(from).Seconds())
+}
+
+// reach records point and observes stage from previous. It reports whether
+// point was reached for the first time this turn.
+func (t *Turn) reach(point *time.Time, previous *time.Time, stage string) bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.final.IsZero() || !point.IsZero() {
+		return false
+	}
+	*point = time.Now()
+	if !previous.IsZero() {
+		StageDuration.WithLabelValues(stage).Observe(point.Sub(*previous).Seconds())
+	}
+	return true
+}
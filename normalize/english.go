@@ -0,0 +1,240 @@
+package normalize
+
+import (
+	"strconv"
+	"strings"
+)
+
+type english struct{}
+
+var (
+	englishOnes = []string{
+		"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine",
+		"ten", "eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen",
+		"seventeen", "eighteen", "nineteen",
+	}
+	englishTens = []string{
+		"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety",
+	}
+	englishScales = []struct {
+		value int64
+		name  string
+	}{
+		{1_000_000_000_000, "trillion"},
+		{1_000_000_000, "billion"},
+		{1_000_000, "million"},
+		{1_000, "thousand"},
+	}
+	englishMonths = []string{
+		"", "January", "February", "March", "April", "May", "June", "July",
+		"August", "September", "October", "November", "December",
+	}
+	englishCurrencies = map[string][4]string{
+		"$": {"dollar", "dollars", "cent", "cents"},
+		"€": {"euro", "euros", "cent", "cents"},
+		"£": {"pound", "pounds", "penny", "pence"},
+		"₹": {"rupee", "rupees", "paisa", "paise"},
+		"¥": {"yen", "yen", "sen", "sen"},
+	}
+	englishScaleSuffixes = map[string]string{
+		"k": "thousand", "m": "million", "mn": "million", "b": "billion", "bn": "billion",
+	}
+)
+
+func (english) word(key string) string {
+	return key
+}
+
+func (e english) symbol(s string) string {
+	r := strings.NewReplacer(".", " dot ", "/", " slash ", "-", " dash ", "_", " underscore ", "+", " plus ", "?", " ", "=", " equals ", "&", " and ")
+	return strings.TrimSpace(r.Replace(s))
+}
+
+func (english) phone(s string) string {
+	var groups []string
+	var current []string
+	flush := func() {
+		if len(current) > 0 {
+			groups = append(groups, strings.Join(current, " "))
+			current = nil
+		}
+	}
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			current = append(current, englishOnes[r-'0'])
+		case r == '+':
+			current = append(current, "plus")
+		default:
+			flush()
+		}
+	}
+	flush()
+	return strings.Join(groups, ", ")
+}
+
+func (e english) date(year, month, day int) (string, bool) {
+	if month < 1 || month > 12 || day < 1 || day > 31 {
+		return "", false
+	}
+	return englishMonths[month] + " " + e.ordinal(int64(day)) + ", " + e.year(year), true
+}
+
+// year reads 1984 as "nineteen eighty-four" and 2005 as "two thousand five".
+func (e english) year(y int) string {
+	switch {
+	case y >= 2000 && y < 2010, y < 1000 || y >= 10000:
+		return e.cardinal(int64(y))
+	case y%100 == 0:
+		return e.cardinal(int64(y/100)) + " hundred"
+	case y%100 < 10:
+		return e.cardinal(int64(y/100)) + " oh " + e.cardinal(int64(y%100))
+	default:
+		return e.cardinal(int64(y/100)) + " " + e.cardinal(int64(y%100))
+	}
+}
+
+func (e english) clock(hour, minute int, meridiem string) (string, bool) {
+	if hour > 23 || minute > 59 {
+		return "", false
+	}
+	suffix := ""
+	switch strings.ToLower(meridiem) {
+	case "a":
+		suffix = " AM"
+	case "p":
+		suffix = " PM"
+	}
+	// 24-hour times are read the 12-hour way, 14:00 as "two PM"; the hour
+	// wins over a contradicting "am"
+	switch {
+	case hour > 12:
+		hour, suffix = hour-12, " PM"
+	case hour == 0:
+		hour, suffix = 12, " AM"
+	}
+	h := e.cardinal(int64(hour))
+	switch {
+	case minute == 0 && suffix != "":
+		return h + suffix, true
+	case minute == 0:
+		return h + " o'clock", true
+	case minute < 10:
+		return h + " oh " + e.cardinal(int64(minute)) + suffix, true
+	default:
+		return h + " " + e.cardinal(int64(minute)) + suffix, true
+	}
+}
+
+func (e english) money(symbol, whole, fraction, scale string) string {
+	names, ok := englishCurrencies[symbol]
+	if !ok {
+		return symbol + whole
+	}
+	if scale != "" {
+		if full, ok := englishScaleSuffixes[strings.ToLower(scale)]; ok {
+			scale = full
+		}
+		amount := whole
+		if fraction != "" {
+			amount += "." + fraction
+		}
+		return e.number(amount) + " " + scale + " " + names[1]
+	}
+	units, _ := strconv.ParseInt(whole, 10, 64)
+	unitName := names[1]
+	if units == 1 {
+		unitName = names[0]
+	}
+	out := e.cardinal(units) + " " + unitName
+	if fraction != "" {
+		if len(fraction) == 1 {
+			fraction += "0"
+		}
+		cents, _ := strconv.ParseInt(fraction, 10, 64)
+		if cents > 0 {
+			centName := names[3]
+			if cents == 1 {
+				centName = names[2]
+			}
+			out += " and " + e.cardinal(cents) + " " + centName
+		}
+	}
+	return out
+}
+
+// number reads "1234" as words and "3.14" as "three point one four".
+func (e english) number(s string) string {
+	whole, fraction, hasFraction := strings.Cut(s, ".")
+	var n int64
+	if len(whole) > maxCardinalDigits {
+		return e.digits(whole)
+	}
+	n, _ = strconv.ParseInt(whole, 10, 64)
+	out := e.cardinal(n)
+	if hasFraction && fraction != "" {
+		out += " point " + e.digits(fraction)
+	}
+	return out
+}
+
+func (english) digits(s string) string {
+	words := make([]string, 0, len(s))
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			words = append(words, englishOnes[r-'0'])
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func (e english) cardinal(n int64) string {
+	if n < 0 {
+		return "minus " + e.cardinal(-n)
+	}
+	if n < 20 {
+		return englishOnes[n]
+	}
+	if n < 100 {
+		if n%10 == 0 {
+			return englishTens[n/10]
+		}
+		return englishTens[n/10] + "-" + englishOnes[n%10]
+	}
+	if n < 1000 {
+		if n%100 == 0 {
+			return englishOnes[n/100] + " hundred"
+		}
+		return englishOnes[n/100] + " hundred " + e.cardinal(n%100)
+	}
+	var parts []string
+	for _, scale := range englishScales {
+		if n >= scale.value {
+			parts = append(parts, e.cardinal(n/scale.value)+" "+scale.name)
+			n %= scale.value
+		}
+	}
+	if n > 0 {
+		parts = append(parts, e.cardinal(n))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (e english) ordinal(n int64) string {
+	words := e.cardinal(n)
+	cut := strings.LastIndexAny(words, " -") + 1
+	head, last := words[:cut], words[cut:]
+	irregular := map[string]string{
+		"one": "first", "two": "second", "three": "third", "five": "fifth",
+		"eight": "eighth", "nine": "ninth", "twelve": "twelfth",
+	}
+	switch {
+	case irregular[last] != "":
+		last = irregular[last]
+	case strings.HasSuffix(last, "y"):
+		last = strings.TrimSuffix(last, "y") + "ieth"
+	default:
+		last += "th"
+	}
+	return head + last
+}
@@ -0,0 +1,48 @@
+package normalize
+
+import "strings"
+
+// speller turns digits and symbols into words for one language.
+type speller interface {
+	word(key string) string
+	symbol(s string) string
+	phone(s string) string
+	date(year, month, day int) (string, bool)
+	clock(hour, minute int, meridiem string) (string, bool)
+	money(symbol, whole, fraction, scale string) string
+	number(s string) string
+	ordinal(n int64) string
+}
+
+type locale struct {
+	tag      string
+	language string
+	region   string
+	// dayFirst reads 03/04/2025 as 3 April rather than March 4.
+	dayFirst bool
+	// speller is nil for languages we have no word tables for; their digits
+	// are left for the (multilingual) TTS model to read.
+	speller speller
+}
+
+func parseLocale(tag string) locale {
+	if tag == "" {
+		tag = "en-US"
+	}
+	lang, region, _ := strings.Cut(strings.ReplaceAll(tag, "_", "-"), "-")
+	l := locale{
+		tag:      tag,
+		language: strings.ToLower(lang),
+		region:   strings.ToUpper(region),
+	}
+	switch {
+	case l.region == "US", l.region == "PH", l.region == "" && l.language == "en":
+		l.dayFirst = false
+	default:
+		l.dayFirst = true
+	}
+	if l.language == "en" {
+		l.speller = english{}
+	}
+	return l
+}
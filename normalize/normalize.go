@@ -0,0 +1,289 @@
+package normalize
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Normalizer rewrites LLM text into a form that reads well when spoken:
+// markdown and emojis are removed, numbers, currencies, dates, times and
+// phone numbers are expanded into words, and a per-agent pronunciation
+// dictionary is applied to brand names and acronyms.
+type Normalizer struct {
+	locale          locale
+	pronunciations  map[string]string
+	pronunciationRe *regexp.Regexp
+}
+
+// New returns a Normalizer for the given locale (e.g. "en-US", "en-GB", "de-DE").
+// Only English has word tables: for other languages markdown, emojis and the
+// dictionary are still handled, but numbers, currencies, dates, times and
+// phone numbers are passed through unchanged for the TTS model to read.
+// Dictionary keys are matched case-sensitively on word boundaries, so "US" and
+// "us" can be told apart.
+func New(localeTag string, pronunciations map[string]string) *Normalizer {
+	n := &Normalizer{
+		locale:         parseLocale(localeTag),
+		pronunciations: pronunciations,
+	}
+	if len(pronunciations) > 0 {
+		keys := make([]string, 0, len(pronunciations))
+		for k := range pronunciations {
+			if k != "" {
+				keys = append(keys, regexp.QuoteMeta(k))
+			}
+		}
+		// Longest first so "AWS Lambda" wins over "AWS".
+		sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+		if len(keys) > 0 {
+			n.pronunciationRe = regexp.MustCompile(strings.Join(keys, "|"))
+		}
+	}
+	return n
+}
+
+// Locale returns the locale tag the normalizer was built for.
+func (n *Normalizer) Locale() string {
+	return n.locale.tag
+}
+
+// Normalize returns text ready to be sent to a TTS provider. A nil Normalizer
+// returns text unchanged.
+func (n *Normalizer) Normalize(text string) string {
+	if n == nil {
+		return text
+	}
+	text = stripMarkdown(text)
+	text = stripEmoji(text)
+	text = n.applyPronunciations(text)
+	if n.locale.speller != nil {
+		text = n.expand(text)
+	}
+	return collapseSpaces(text)
+}
+
+var (
+	emailRe     = regexp.MustCompile(`\b[\w.+-]+@[\w-]+(?:\.[\w-]+)+\b`)
+	urlRe       = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>()"]+`)
+	phoneRe     = regexp.MustCompile(`(?:\+\d{1,3}[\s.-]?)?(?:\(\d{3}\)|\b\d{3})[\s.-]?\d{3}[\s.-]?\d{4}\b`)
+	isoDateRe   = regexp.MustCompile(`\b(\d{4})-(\d{1,2})-(\d{1,2})\b`)
+	slashDateRe = regexp.MustCompile(`\b(\d{1,2})/(\d{1,2})/(\d{4}|\d{2})\b`)
+	timeRe      = regexp.MustCompile(`\b(\d{1,2}):(\d{2})(?:\s?([AaPp])(?:\.[Mm]\.|[Mm]\b))?`)
+	currencyRe  = regexp.MustCompile(`([$€£₹¥])\s?(\d{1,3}(?:,\d{2,3})+|\d+)(?:\.(\d{1,2}))?(?:\s?(thousand|million|billion|trillion|[kKmMbB]n?)\b)?`)
+	percentRe   = regexp.MustCompile(`(\d+(?:\.\d+)?)\s?%`)
+	ordinalRe   = regexp.MustCompile(`\b(\d+)(?:st|nd|rd|th)\b`)
+	numberRe    = regexp.MustCompile(`\d{1,3}(?:,\d{2,3})+(?:\.\d+)?|\d+(?:\.\d+)?`)
+)
+
+// expand rewrites everything that a TTS engine tends to read badly, in an
+// order where the more specific patterns (phone numbers, dates) win over the
+// generic number expansion.
+func (n *Normalizer) expand(text string) string {
+	sp := n.locale.speller
+
+	text = emailRe.ReplaceAllStringFunc(text, func(m string) string {
+		local, domain, _ := strings.Cut(m, "@")
+		return sp.symbol(local) + " " + sp.word("at") + " " + sp.symbol(domain)
+	})
+	text = urlRe.ReplaceAllStringFunc(text, func(m string) string {
+		// Sentence punctuation right after a URL is not part of it.
+		trimmed := strings.TrimRight(m, ".,/")
+		trailing := strings.ReplaceAll(m[len(trimmed):], "/", "")
+		trimmed = strings.ToLower(trimmed)
+		for _, prefix := range []string{"https://", "http://", "www."} {
+			trimmed = strings.TrimPrefix(trimmed, prefix)
+		}
+		return sp.symbol(trimmed) + trailing
+	})
+	text = phoneRe.ReplaceAllStringFunc(text, func(m string) string {
+		return sp.phone(m)
+	})
+	text = isoDateRe.ReplaceAllStringFunc(text, func(m string) string {
+		g := isoDateRe.FindStringSubmatch(m)
+		if s, ok := sp.date(atoi(g[1]), atoi(g[2]), atoi(g[3])); ok {
+			return s
+		}
+		return m
+	})
+	text = slashDateRe.ReplaceAllStringFunc(text, func(m string) string {
+		g := slashDateRe.FindStringSubmatch(m)
+		month, day := atoi(g[1]), atoi(g[2])
+		if n.locale.dayFirst {
+			month, day = day, month
+		}
+		year := atoi(g[3])
+		if len(g[3]) == 2 {
+			year += 2000
+		}
+		if s, ok := sp.date(year, month, day); ok {
+			return s
+		}
+		return m
+	})
+	text = timeRe.ReplaceAllStringFunc(text, func(m string) string {
+		g := timeRe.FindStringSubmatch(m)
+		if s, ok := sp.clock(atoi(g[1]), atoi(g[2]), g[3]); ok {
+			return s
+		}
+		return m
+	})
+	text = currencyRe.ReplaceAllStringFunc(text, func(m string) string {
+		g := currencyRe.FindStringSubmatch(m)
+		return sp.money(g[1], stripGrouping(g[2]), g[3], g[4])
+	})
+	text = percentRe.ReplaceAllStringFunc(text, func(m string) string {
+		g := percentRe.FindStringSubmatch(m)
+		return sp.number(g[1]) + " " + sp.word("percent")
+	})
+	text = ordinalRe.ReplaceAllStringFunc(text, func(m string) string {
+		g := ordinalRe.FindStringSubmatch(m)
+		// Too long to fit an int64, so the digits are read one by one
+		if len(g[1]) > maxCardinalDigits {
+			return sp.number(g[1])
+		}
+		return sp.ordinal(int64(atoi(g[1])))
+	})
+	text = numberRe.ReplaceAllStringFunc(text, func(m string) string {
+		return sp.number(stripGrouping(m))
+	})
+	text = strings.ReplaceAll(text, " & ", " "+sp.word("and")+" ")
+	return text
+}
+
+// applyPronunciations replaces dictionary entries that stand as whole words.
+func (n *Normalizer) applyPronunciations(text string) string {
+	if n.pronunciationRe == nil {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	for _, loc := range n.pronunciationRe.FindAllStringIndex(text, -1) {
+		if !isBoundary(text, loc[0], true) || !isBoundary(text, loc[1], false) {
+			continue
+		}
+		b.WriteString(text[last:loc[0]])
+		b.WriteString(n.pronunciations[text[loc[0]:loc[1]]])
+		last = loc[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+func isBoundary(text string, i int, before bool) bool {
+	var r rune
+	if before {
+		if i == 0 {
+			return true
+		}
+		r, _ = utf8.DecodeLastRuneInString(text[:i])
+	} else {
+		if i >= len(text) {
+			return true
+		}
+		r, _ = utf8.DecodeRuneInString(text[i:])
+	}
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+var (
+	codeFenceRe  = regexp.MustCompile("(?m)^\\s*```.*$")
+	inlineCodeRe = regexp.MustCompile("`([^`]*)`")
+	imageRe      = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	linkRe       = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	headingRe    = regexp.MustCompile(`(?m)^\s{0,3}#{1,6}\s+`)
+	quoteRe      = regexp.MustCompile(`(?m)^\s*>\s?`)
+	ruleRe       = regexp.MustCompile(`(?m)^\s*(?:-{3,}|\*{3,}|_{3,})\s*$`)
+	bulletRe     = regexp.MustCompile(`(?m)^\s*(?:[-*+•]|\d+[.)])\s+`)
+	emphasisRe   = regexp.MustCompile(`\*{1,3}|~~`)
+)
+
+// stripMarkdown removes markdown syntax while keeping the readable text.
+func stripMarkdown(text string) string {
+	text = codeFenceRe.ReplaceAllString(text, "")
+	text = inlineCodeRe.ReplaceAllString(text, "$1")
+	text = imageRe.ReplaceAllString(text, "$1")
+	text = linkRe.ReplaceAllString(text, "$1")
+	text = ruleRe.ReplaceAllString(text, "")
+	text = headingRe.ReplaceAllString(text, "")
+	text = quoteRe.ReplaceAllString(text, "")
+	text = bulletRe.ReplaceAllString(text, "")
+	text = emphasisRe.ReplaceAllString(text, "")
+	text = stripUnderscoreEmphasis(text)
+	text = strings.ReplaceAll(text, "|", ", ")
+	return text
+}
+
+// stripUnderscoreEmphasis drops underscores that are not inside a word, so
+// "_really_" loses its markers but "snake_case" is left alone.
+func stripUnderscoreEmphasis(text string) string {
+	if !strings.Contains(text, "_") {
+		return text
+	}
+	runes := []rune(text)
+	var b strings.Builder
+	for i, r := range runes {
+		if r == '_' {
+			inside := i > 0 && i < len(runes)-1 && isWordRune(runes[i-1]) && isWordRune(runes[i+1])
+			if !inside {
+				continue
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// stripEmoji removes pictographs, flags, keycaps and the joiners that glue them together.
+func stripEmoji(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 0x1F000 && r <= 0x1FAFF,
+			r >= 0x2600 && r <= 0x27BF,
+			r >= 0x2B00 && r <= 0x2BFF,
+			r >= 0xFE00 && r <= 0xFE0F,
+			r == 0x200D, r == 0x20E3:
+			return -1
+		}
+		return r
+	}, text)
+}
+
+var (
+	spacesRe           = regexp.MustCompile(`\s+`)
+	spaceBeforePunctRe = regexp.MustCompile(`\s+([.,!?;:])`)
+)
+
+func collapseSpaces(text string) string {
+	text = spacesRe.ReplaceAllString(text, " ")
+	text = spaceBeforePunctRe.ReplaceAllString(text, "$1")
+	return strings.TrimSpace(text)
+}
+
+func stripGrouping(s string) string {
+	return strings.ReplaceAll(s, ",", "")
+}
+
+// maxCardinalDigits is the longest number read as a whole; longer runs are
+// read digit by digit, as no one says them as a number anyway.
+const maxCardinalDigits = 15
+
+// atoi reads a run of digits that is known to be short: the date and time
+// patterns capture at most four, and ordinals are checked against
+// maxCardinalDigits first.
+func atoi(s string) int {
+	n := 0
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			break
+		}
+		n = n*10 + int(r-'0')
+	}
+	return n
+}
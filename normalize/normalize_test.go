@@ -0,0 +1,115 @@
+package normalize
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name   string
+		locale string
+		in     string
+		want   string
+	}{
+		{"markdown", "en-US", "**Sure!** Here is `the plan`:\n- first\n- second", "Sure! Here is the plan: first second"},
+		{"link", "en-US", "See [our docs](https://example.com/docs).", "See our docs."},
+		{"emoji", "en-US", "Done ✅ 🎉", "Done"},
+		{"currency", "en-US", "That is $12.50 in total.", "That is twelve dollars and fifty cents in total."},
+		{"currency one", "en-US", "Only £1 more.", "Only one pound more."},
+		{"currency scale", "en-US", "Raised $2.5M last year.", "Raised two point five million dollars last year."},
+		{"number grouping", "en-US", "We have 1,234 seats.", "We have one thousand two hundred thirty-four seats."},
+		{"percent", "en-US", "Up 15% today.", "Up fifteen percent today."},
+		{"ordinal", "en-US", "You are 21st in line.", "You are twenty-first in line."},
+		{"ordinal too long", "en-US", "Ticket 12345678901234567890th.", "Ticket one two three four five six seven eight nine zero one two three four five six seven eight nine zero."},
+		{"iso date", "en-US", "Due 2025-03-04.", "Due March fourth, twenty twenty-five."},
+		{"us slash date", "en-US", "Due 03/04/2025.", "Due March fourth, twenty twenty-five."},
+		{"gb slash date", "en-GB", "Due 03/04/2025.", "Due April third, twenty twenty-five."},
+		{"clock", "en-US", "Open at 9:05 am.", "Open at nine oh five AM."},
+		{"clock dotted", "en-US", "Closes at 5:30 p.m. sharp.", "Closes at five thirty PM sharp."},
+		{"clock o'clock", "en-US", "Open at 9:00.", "Open at nine o'clock."},
+		{"clock 24-hour", "en-US", "Open at 14:00.", "Open at two PM."},
+		{"clock 24-hour minutes", "en-US", "Closes at 17:45.", "Closes at five forty-five PM."},
+		{"clock midnight", "en-US", "Runs at 00:30.", "Runs at twelve thirty AM."},
+		{"clock contradicting meridiem", "en-US", "At 14:00 am.", "At two PM."},
+		{"phone", "en-US", "Call (555) 123-4567.", "Call five five five, one two three, four five six seven."},
+		{"email", "en-US", "Mail jo.doe@example.com now.", "Mail jo dot doe at example dot com now."},
+		{"url", "en-US", "Visit www.example.com.", "Visit example dot com."},
+		{"ampersand", "en-US", "Terms & conditions.", "Terms and conditions."},
+		{"no speller", "de-DE", "**Nur** 12,50 € am 03.04.", "Nur 12,50 € am 03.04."},
+		{"default locale", "", "It costs $3.", "It costs three dollars."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := New(tt.locale, nil).Normalize(tt.in); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPronunciations(t *testing.T) {
+	n := New("en-US", map[string]string{
+		"AWS":        "A W S",
+		"AWS Lambda": "A W S lambda",
+		"US":         "U S",
+	})
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Deploy on AWS Lambda.", "Deploy on A W S lambda."},
+		{"Hosted on AWS.", "Hosted on A W S."},
+		{"Call us in the US.", "Call us in the U S."},
+		{"AWSome is not a match.", "AWSome is not a match."},
+	}
+	for _, tt := range tests {
+		if got := n.Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNilNormalizer(t *testing.T) {
+	var n *Normalizer
+	if got := n.Normalize("**$5**"); got != "**$5**" {
+		t.Errorf("nil Normalize changed text to %q", got)
+	}
+}
+
+func TestCardinal(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "zero"},
+		{13, "thirteen"},
+		{40, "forty"},
+		{99, "ninety-nine"},
+		{100, "one hundred"},
+		{101, "one hundred one"},
+		{1_000_000, "one million"},
+		{2_003_010, "two million three thousand ten"},
+		{-7, "minus seven"},
+	}
+	for _, tt := range tests {
+		if got := (english{}).cardinal(tt.n); got != tt.want {
+			t.Errorf("cardinal(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestYear(t *testing.T) {
+	tests := []struct {
+		y    int
+		want string
+	}{
+		{1984, "nineteen eighty-four"},
+		{1900, "nineteen hundred"},
+		{1905, "nineteen oh five"},
+		{2005, "two thousand five"},
+		{2025, "twenty twenty-five"},
+	}
+	for _, tt := range tests {
+		if got := (english{}).year(tt.y); got != tt.want {
+			t.Errorf("year(%d) = %q, want %q", tt.y, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package output
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Mark is a named marker sent to the far end after a stretch of audio.
+type Mark struct {
+	Name   string
+	SentAt time.Time
+}
+
+// PlaybackTracker matches mark acks to the marks we sent. The far end acks
+// a mark once everything sent before it has played or been cleared, and acks
+// arrive in the order the marks were sent.
+type PlaybackTracker struct {
+	mu      sync.Mutex
+	seq     int
+	pending []Mark
+}
+
+func NewPlaybackTracker() *PlaybackTracker {
+	return &PlaybackTracker{}
+}
+
+// Next records a new mark with a name unique to this call, e.g. "utterance-3".
+func (t *PlaybackTracker) Next(kind string) Mark {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.seq++
+	mark := Mark{Name: fmt.Sprintf("%s-%d", kind, t.seq), SentAt: time.Now()}
+	t.pending = append(t.pending, mark)
+	return mark
+}
+
+// Ack resolves the mark with the given name, and every mark sent before it.
+// It returns the mark and how many marks are still outstanding; ok is false
+// for names that were never sent or were already acked.
+func (t *PlaybackTracker) Ack(name string) (mark Mark, remaining int, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, m := range t.pending {
+		if m.Name == name {
+			t.pending = t.pending[i+1:]
+			return m, len(t.pending), true
+		}
+	}
+	return Mark{}, len(t.pending), false
+}
+
+// Pending returns how many marks the far end has not acked yet.
+func (t *PlaybackTracker) Pending() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.pending)
+}
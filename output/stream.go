@@ -0,0 +1,287 @@
+package output
+
+import (
+    "context"
+    "encoding/base64"
+    "fmt"
+    "log/slog"
+    "sync"
+    "time"
+
+    "github.com/mrsingh-rishi/voice-bot/audio"
+    "github.com/mrsingh-rishi/voice-bot/logging"
+    "github.com/mrsingh-rishi/voice-bot/metrics"
+    "github.com/mrsingh-rishi/voice-bot/transport"
+)
+
+const EndOfUtterance = "__END_OF_UTTERANCE__"
+
+// Filler is a pre-synthesized clip requested while the caller waits for the
+// first audio of the agent's answer to the turn that started at TurnStart.
+type Filler struct {
+    Word      string
+    TurnStart time.Time
+    Chunks    []string
+}
+
+// StreamOutput plays the agent's audio over a call's transport. Audio arrives
+// as base64 8 kHz mu-law and is transcoded to whatever the transport carries.
+type StreamOutput struct {
+    ctx                 context.Context
+    cancel              context.CancelFunc
+    OutputDeviceChannel <-chan string
+    FillerChannel       <-chan Filler
+    transport           transport.Transport
+    // outbound converts telephony audio to the transport's format
+    outbound *audio.Transcoder
+    // lastMediaAt is when agent audio or a filler was last written; only touched by the Start loop
+    lastMediaAt time.Time
+    // inUtterance is set between the first media chunk of an utterance and its end sentinel
+    inUtterance bool
+    // dropping discards the rest of an utterance that was cleared mid-way
+    dropping bool
+    clearRequests chan struct{}
+    toneRequests  chan []string
+    // pendingTones wait for the utterance being sent to end
+    pendingTones [][]string
+
+    mu sync.Mutex
+    // playoutUntil estimates when the far end finishes playing what was sent so far
+    playoutUntil time.Time
+    // Playback matches mark acks from the far end to the marks we sent
+    Playback *PlaybackTracker
+    // OnPlaybackStart, if set, is called when the first audio of an agent
+    // utterance or of a filler is sent
+    OnPlaybackStart func()
+    // OnMediaSent, if set, is called once the first audio of an agent
+    // utterance has been handed to the transport
+    OnMediaSent func()
+    // OnPlaybackDone, if set, is called once the far end acks every mark sent,
+    // i.e. all audio sent so far has played or was cleared
+    OnPlaybackDone func()
+    Log            *slog.Logger
+}
+
+const (
+    // telephonyBytesPerSecond is 8 kHz mu-law
+    telephonyBytesPerSecond = 8000
+    // markAckGrace is how long past the playout estimate we keep waiting for
+    // a mark ack before assuming playback finished anyway
+    markAckGrace = 2 * time.Second
+)
+
+func NewStreamOutput(
+    t transport.Transport,
+    outputDeviceChannel <-chan string,
+    fillerChannel <-chan Filler,
+) (*StreamOutput, error) {
+    if t == nil {
+        return nil, fmt.Errorf("transport is required")
+    }
+    if outputDeviceChannel == nil {
+        return nil, fmt.Errorf("output device channel is required")
+    }
+    ctx, cancel := context.WithCancel(context.Background())
+    return &StreamOutput{
+        ctx:                 ctx,
+        cancel:              cancel,
+        OutputDeviceChannel: outputDeviceChannel,
+        FillerChannel:       fillerChannel,
+        transport:           t,
+        outbound:            audio.NewTranscoder(audio.Telephony, t.Format()),
+        clearRequests:       make(chan struct{}, 1),
+        toneRequests:        make(chan []string, 4),
+        Playback:            NewPlaybackTracker(),
+        Log:                 logging.Logger("output"),
+    }, nil
+}
+
+func (o *StreamOutput) Start() {
+    go func() {
+        for {
+            select {
+            case <-o.ctx.Done():
+                return
+            case payload, ok := <-o.OutputDeviceChannel:
+                if !ok {
+                    return
+                }
+                // if it's our end‑of‑utterance sentinel, send a mark
+                if payload == EndOfUtterance {
+                    o.inUtterance = false
+                    if o.dropping {
+                        o.dropping = false
+                    } else {
+                        o.sendMarkEvent("utterance")
+                    }
+                    o.flushTones()
+                } else {
+                    if o.dropping {
+                        metrics.DroppedAudioChunks.WithLabelValues(metrics.DropCleared).Inc()
+                        continue
+                    }
+                    first := !o.inUtterance
+                    if first && o.OnPlaybackStart != nil {
+                        o.OnPlaybackStart()
+                    }
+                    o.inUtterance = true
+                    o.lastMediaAt = time.Now()
+                    o.sendMediaEvent(payload)
+                    if first && o.OnMediaSent != nil {
+                        o.OnMediaSent()
+                    }
+                }
+            case <-o.clearRequests:
+                o.clear()
+            case tones := <-o.toneRequests:
+                o.pendingTones = append(o.pendingTones, tones)
+                if !o.inUtterance {
+                    o.flushTones()
+                }
+            case filler, ok := <-o.FillerChannel:
+                if !ok {
+                    o.FillerChannel = nil
+                    continue
+                }
+                o.playFiller(filler)
+            }
+        }
+    }()
+}
+
+// playFiller sends a filler clip unless the real answer already started
+// playing. Fillers and agent audio share this goroutine, so they never
+// overlap; a filler is sent whole, as a short utterance of its own.
+func (o *StreamOutput) playFiller(filler Filler) {
+    if o.inUtterance || o.lastMediaAt.After(filler.TurnStart) || len(filler.Chunks) == 0 {
+        return
+    }
+    o.Log.Debug("Playing filler", "word", filler.Word)
+    if o.OnPlaybackStart != nil {
+        o.OnPlaybackStart()
+    }
+    o.inUtterance = true
+    o.lastMediaAt = time.Now()
+    for _, chunk := range filler.Chunks {
+        o.sendMediaEvent(chunk)
+    }
+    o.inUtterance = false
+    o.sendMarkEvent("filler")
+    o.flushTones()
+}
+
+// PlayTones queues pre-rendered DTMF audio (base64 mu-law frames). It is sent
+// between utterances, never in the middle of one.
+func (o *StreamOutput) PlayTones(chunks []string) {
+    select {
+    case o.toneRequests <- chunks:
+    case <-o.ctx.Done():
+    }
+}
+
+func (o *StreamOutput) flushTones() {
+    for _, tones := range o.pendingTones {
+        for _, chunk := range tones {
+            o.sendMediaEvent(chunk)
+        }
+        o.sendMarkEvent("dtmf")
+    }
+    o.pendingTones = nil
+}
+
+// Clear stops playback: the far end drops the audio it has buffered, queued chunks
+// are discarded, and so is the rest of the utterance being sent right now.
+func (o *StreamOutput) Clear() {
+    select {
+    case o.clearRequests <- struct{}{}:
+    default:
+    }
+}
+
+// Speaking reports whether the caller is probably still hearing agent audio.
+// The far end acks a mark once everything sent before it has played, so an
+// outstanding mark means audio is still playing.
+func (o *StreamOutput) Speaking() bool {
+    o.mu.Lock()
+    defer o.mu.Unlock()
+    now := time.Now()
+    if now.Before(o.playoutUntil) {
+        return true
+    }
+    return o.Playback.Pending() > 0 && now.Before(o.playoutUntil.Add(markAckGrace))
+}
+
+// MarkReceived records a mark ack from the far end.
+func (o *StreamOutput) MarkReceived(name string) {
+    mark, remaining, ok := o.Playback.Ack(name)
+    if !ok {
+        o.Log.Warn("Ignoring ack for unknown mark", "mark", name)
+        return
+    }
+    o.Log.Debug("Mark played", "mark", mark.Name, "after", time.Since(mark.SentAt).Round(time.Millisecond))
+    if remaining == 0 && o.OnPlaybackDone != nil {
+        o.OnPlaybackDone()
+    }
+}
+
+func (o *StreamOutput) clear() {
+    if err := o.transport.Clear(); err != nil {
+        o.Log.Error("Clear write error", "error", err)
+    }
+    for drained := false; !drained; {
+        select {
+        case payload, ok := <-o.OutputDeviceChannel:
+            if !ok {
+                drained = true
+            } else if payload == EndOfUtterance {
+                o.inUtterance = false
+            } else {
+                metrics.DroppedAudioChunks.WithLabelValues(metrics.DropCleared).Inc()
+            }
+        default:
+            drained = true
+        }
+    }
+    o.dropping = o.inUtterance
+    o.mu.Lock()
+    o.playoutUntil = time.Now()
+    o.mu.Unlock()
+}
+
+// extendPlayout pushes the playout estimate forward by the chunk's duration.
+func (o *StreamOutput) extendPlayout(chunk []byte) {
+    duration := time.Duration(len(chunk)) * time.Second / telephonyBytesPerSecond
+    o.mu.Lock()
+    defer o.mu.Unlock()
+    now := time.Now()
+    if o.playoutUntil.Before(now) {
+        o.playoutUntil = now
+    }
+    o.playoutUntil = o.playoutUntil.Add(duration)
+}
+
+func (o *StreamOutput) sendMediaEvent(payload string) {
+    chunk, err := base64.StdEncoding.DecodeString(payload)
+    if err != nil {
+        o.Log.Error("Invalid media", "error", err)
+        return
+    }
+    o.extendPlayout(chunk)
+    if err := o.transport.SendAudio(o.outbound.Process(chunk)); err != nil {
+        o.Log.Error("Media write error", "error", err)
+        metrics.DroppedAudioChunks.WithLabelValues(metrics.DropWriteError).Inc()
+    }
+}
+
+// sendMarkEvent marks the end of a stretch of audio of the given kind.
+func (o *StreamOutput) sendMarkEvent(kind string) {
+    mark := o.Playback.Next(kind)
+    if err := o.transport.Mark(mark.Name); err != nil {
+        o.Log.Error("Mark write error", "error", err)
+    }
+}
+
+func (o *StreamOutput) Stop() {
+    o.cancel()
+    o.transport.Close()
+}
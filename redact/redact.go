@@ -0,0 +1,77 @@
+// Package redact masks personal data in text: phone numbers, emails, card
+// numbers, social security numbers and keypad digits. It errs on the side
+// of masking, since a masked order number is cheaper than a logged card.
+package redact
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Replacements for each kind of data.
+const (
+	Email = "[email]"
+	Card  = "[card]"
+	SSN   = "[ssn]"
+	Phone = "[phone]"
+)
+
+var (
+	emailRe = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	// cardRe finds runs of 13 to 19 digits, optionally grouped with spaces
+	// or dashes; only those passing the Luhn check are masked
+	cardRe = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+	ssnRe  = regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)
+	// phoneRe finds E.164 numbers and 10 digit numbers in the usual
+	// North American groupings
+	phoneRe = regexp.MustCompile(`\+\d{7,15}\b|(?:\b|\()\d{3}\)?[ .-]?\d{3}[ .-]?\d{4}\b`)
+	// keypadRe finds the digits of keypad entries as they appear in keypad
+	// transcripts, e.g. {"digits":"1234"}
+	keypadRe = regexp.MustCompile(`("digits":\s*")([0-9*#]*)(")`)
+)
+
+// String masks the personal data in s.
+func String(s string) string {
+	s = emailRe.ReplaceAllString(s, Email)
+	s = cardRe.ReplaceAllStringFunc(s, func(match string) string {
+		if luhn(match) {
+			return Card
+		}
+		return match
+	})
+	s = ssnRe.ReplaceAllString(s, SSN)
+	s = phoneRe.ReplaceAllString(s, Phone)
+	s = keypadRe.ReplaceAllStringFunc(s, func(match string) string {
+		parts := keypadRe.FindStringSubmatch(match)
+		return parts[1] + Digits(parts[2]) + parts[3]
+	})
+	return s
+}
+
+// Digits masks keypad digits, keeping how many were pressed.
+func Digits(digits string) string {
+	return strings.Repeat("*", len(digits))
+}
+
+// luhn reports whether the digits of s pass the Luhn checksum card numbers
+// carry; spaces and dashes are skipped.
+func luhn(s string) bool {
+	sum := 0
+	double := false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/mrsingh-rishi/voice-bot/logging"
+	"github.com/mrsingh-rishi/voice-bot/simulate"
+)
+
+// simulatedAdminKey authenticates the replayed carrier streams.
+const simulatedAdminKey = "simulated-admin"
+
+// runSimulate plays a scenario, or replays a carrier recording, against an
+// in-process bot whose vendors are all fakes and returns the exit code: 0
+// when every expectation held.
+//
+//	voice-bot simulate -scenario simulate/testdata/basic.json
+//	voice-bot simulate -recording simulate/testdata/vonage.json
+//	voice-bot simulate -agents simulate/testdata/agents -scenario simulate/testdata/max_duration.json
+func runSimulate(args []string) int {
+	flags := flag.NewFlagSet("simulate", flag.ExitOnError)
+	scenarioPath := flags.String("scenario", "", "scenario JSON file")
+	recordingPath := flags.String("recording", "", "recorded carrier stream JSON file")
+	agentsDir := flags.String("agents", "", "directory of agent configs (default $AGENTS_DIR)")
+	flags.Parse(args)
+	if (*scenarioPath == "") == (*recordingPath == "") {
+		flags.Usage()
+		return 2
+	}
+	var scenario *simulate.Scenario
+	var recording *simulate.Recording
+	var err error
+	if *recordingPath != "" {
+		recording, err = simulate.LoadRecording(*recordingPath)
+		if recording != nil {
+			scenario = &recording.Scenario
+		}
+	} else {
+		scenario, err = simulate.LoadScenario(*scenarioPath)
+	}
+	if err != nil {
+		logging.Logger("simulate").Error("Cannot load the simulation", "error", err)
+		return 1
+	}
+
+	fakes := simulate.StartFakes(scenario)
+	defer fakes.Close()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		logging.Logger("simulate").Error("Cannot listen", "error", err)
+		return 1
+	}
+	addr := ln.Addr().String()
+	env := fakes.Env()
+	env["TWILIO_ACCOUNT_SID"] = "ACsimulated"
+	env["TWILIO_AUTH_TOKEN"] = simulate.TwilioAuthToken
+	env["ADMIN_API_KEY"] = simulatedAdminKey
+	env["TWILIO_FROM_NUMBER"] = "+15005550006"
+	env["BASE_URL"] = "http://" + addr
+	env["BASE_WS_URL"] = "ws://" + addr
+	if *agentsDir != "" {
+		env["AGENTS_DIR"] = *agentsDir
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+
+	app := newApp()
+	go app.Listener(ln)
+	defer app.Shutdown()
+
+	query := url.Values{}
+	if scenario.Agent != "" {
+		query.Set("agent", scenario.Agent)
+	}
+	var report interface{}
+	var failures []string
+	if recording != nil {
+		query.Set("token", simulatedAdminKey)
+		streamURL := fmt.Sprintf("ws://%s/stream/%s?%s", addr, recording.Carrier, query.Encode())
+		replayReport, replayErr := simulate.RunRecording(streamURL, recording, fakes)
+		if replayReport != nil {
+			report, failures = replayReport, replayReport.Failures
+		}
+		err = replayErr
+	} else {
+		callSid := fmt.Sprintf("CAsimulated%d", time.Now().UnixNano())
+		query.Set("CallSid", callSid)
+		runReport, runErr := simulate.Run(fmt.Sprintf("ws://%s/stream?%s", addr, query.Encode()), callSid, scenario, fakes)
+		if runReport != nil {
+			report, failures = runReport, runReport.Failures
+		}
+		err = runErr
+	}
+	if report != nil {
+		out, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(out))
+	}
+	if err != nil {
+		logging.Logger("simulate").Error("Simulation failed", "error", err)
+		return 1
+	}
+	if len(failures) > 0 {
+		logging.Logger("simulate").Error("Expectations failed", "count", len(failures))
+		return 1
+	}
+	logging.Logger("simulate").Info("Scenario passed")
+	return 0
+}
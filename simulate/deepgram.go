@@ -0,0 +1,136 @@
+package simulate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	gws "github.com/gorilla/websocket"
+	"github.com/mrsingh-rishi/voice-bot/audio"
+	"github.com/mrsingh-rishi/voice-bot/logging"
+	"github.com/mrsingh-rishi/voice-bot/vad"
+)
+
+// ScriptedTranscript is what the fake Deepgram "hears" for one utterance.
+type ScriptedTranscript struct {
+	Text string
+	// Language is reported as the detected language when set
+	Language string
+}
+
+// DeepgramServer is a local stand-in for Deepgram's streaming API. It runs
+// the bot's own VAD over the audio it receives and answers every utterance
+// with the next scripted transcript, once the caller stops talking, like
+// Deepgram's endpointing does.
+type DeepgramServer struct {
+	URL string
+
+	server   *httptest.Server
+	upgrader gws.Upgrader
+
+	mu     sync.Mutex
+	script []ScriptedTranscript
+	sent   []string
+}
+
+func NewDeepgramServer(script []ScriptedTranscript) *DeepgramServer {
+	s := &DeepgramServer{script: script}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/listen", s.handleListen)
+	s.server = httptest.NewServer(mux)
+	s.URL = "ws" + strings.TrimPrefix(s.server.URL, "http") + "/v1/listen"
+	return s
+}
+
+// Sent returns the transcripts delivered so far, in order.
+func (s *DeepgramServer) Sent() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.sent...)
+}
+
+func (s *DeepgramServer) Close() {
+	s.server.Close()
+}
+
+// next pops the next scripted transcript; ok is false once the script is done.
+func (s *DeepgramServer) next() (ScriptedTranscript, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.script) == 0 {
+		return ScriptedTranscript{}, false
+	}
+	t := s.script[0]
+	s.script = s.script[1:]
+	s.sent = append(s.sent, t.Text)
+	return t, true
+}
+
+func (s *DeepgramServer) handleListen(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Token ") {
+		http.Error(w, "missing token", http.StatusUnauthorized)
+		return
+	}
+	q := r.URL.Query()
+	format, err := audio.ParseFormat(q.Get("encoding") + "_" + q.Get("sample_rate"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	cfg := vad.DefaultConfig()
+	cfg.SampleRate = format.SampleRate
+	detector := vad.New(cfg)
+	for {
+		kind, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if kind == gws.TextMessage {
+			// KeepAlive needs no answer; CloseStream ends the session
+			if strings.Contains(string(data), "CloseStream") {
+				conn.WriteMessage(gws.CloseMessage, gws.FormatCloseMessage(gws.CloseNormalClosure, ""))
+				return
+			}
+			continue
+		}
+		for _, ev := range detector.Process(format.Decode(data)) {
+			if ev.Type != vad.SpeechEnd {
+				continue
+			}
+			t, ok := s.next()
+			if !ok {
+				logging.Logger("simulate").Warn("Fake Deepgram heard speech but the script is finished")
+				continue
+			}
+			if err := conn.WriteJSON(results(t)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// results is a final Results message in Deepgram's wire format.
+func results(t ScriptedTranscript) map[string]interface{} {
+	alternative := map[string]interface{}{
+		"transcript": t.Text,
+		"confidence": 0.99,
+	}
+	if t.Language != "" {
+		alternative["languages"] = []string{t.Language}
+	}
+	return map[string]interface{}{
+		"type":         "Results",
+		"is_final":     true,
+		"speech_final": true,
+		"channel": map[string]interface{}{
+			"alternatives": []interface{}{alternative},
+		},
+	}
+}
@@ -0,0 +1,139 @@
+package simulate
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChatMessage is one message of a chat completion request.
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// OpenAIServer is a local stand-in for OpenAI's streaming chat completions.
+// It answers each user message with its scripted reply, streamed a word at a
+// time, and echoes messages it has no reply for.
+type OpenAIServer struct {
+	// URL is the API root to use as the client's base URL
+	URL string
+	// WordDelay spaces the streamed words like a real model would
+	WordDelay time.Duration
+
+	server *httptest.Server
+
+	mu       sync.Mutex
+	replies  map[string]string
+	requests [][]ChatMessage
+}
+
+func NewOpenAIServer(replies map[string]string) *OpenAIServer {
+	s := &OpenAIServer{
+		WordDelay: 15 * time.Millisecond,
+		replies:   replies,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/chat/completions", s.handleCompletions)
+	s.server = httptest.NewServer(mux)
+	s.URL = s.server.URL + "/v1"
+	return s
+}
+
+// Requests returns the messages of every completion request, in order.
+func (s *OpenAIServer) Requests() [][]ChatMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]ChatMessage(nil), s.requests...)
+}
+
+// Asked reports whether text was sent to the model as a user message.
+func (s *OpenAIServer) Asked(text string) bool {
+	for _, messages := range s.Requests() {
+		for _, m := range messages {
+			if m.Role == "user" && m.Content == text {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *OpenAIServer) Close() {
+	s.server.Close()
+}
+
+func (s *OpenAIServer) reply(messages []ChatMessage) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, messages)
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != "user" {
+			continue
+		}
+		if reply, ok := s.replies[messages[i].Content]; ok {
+			return reply
+		}
+		return fmt.Sprintf("You said: %s", messages[i].Content)
+	}
+	return "Hello."
+}
+
+func (s *OpenAIServer) handleCompletions(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		http.Error(w, `{"error":{"message":"missing API key"}}`, http.StatusUnauthorized)
+		return
+	}
+	var req struct {
+		Model    string        `json:"model"`
+		Messages []ChatMessage `json:"messages"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reply := s.reply(req.Messages)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	flusher, _ := w.(http.Flusher)
+	send := func(delta map[string]string, finishReason interface{}) {
+		chunk := map[string]interface{}{
+			"id":      "chatcmpl-simulated",
+			"object":  "chat.completion.chunk",
+			"created": time.Now().Unix(),
+			"model":   req.Model,
+			"choices": []interface{}{map[string]interface{}{
+				"index":         0,
+				"delta":         delta,
+				"finish_reason": finishReason,
+			}},
+		}
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	words := strings.Fields(reply)
+	for i, word := range words {
+		if i > 0 {
+			word = " " + word
+			time.Sleep(s.WordDelay)
+		}
+		delta := map[string]string{"content": word}
+		if i == 0 {
+			delta["role"] = "assistant"
+		}
+		send(delta, nil)
+	}
+	send(map[string]string{}, "stop")
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
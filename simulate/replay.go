@@ -0,0 +1,253 @@
+package simulate
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	gws "github.com/gorilla/websocket"
+	"github.com/mrsingh-rishi/voice-bot/audio"
+)
+
+// Recording is a carrier's side of a media stream, captured as the websocket
+// messages it sent, with the scenario the fakes follow. The turns give the
+// transcripts, replies and expected phrases; the caller's audio is in Frames.
+type Recording struct {
+	Scenario
+	// Carrier picks the bot's endpoint, /stream/<carrier>
+	Carrier string          `json:"carrier"`
+	Frames  []RecordedFrame `json:"frames"`
+	// ExpectMessages lists control messages the bot must send, by their
+	// "event" or "action" name, e.g. "notify" or "checkpoint"
+	ExpectMessages []string `json:"expect_messages"`
+}
+
+// RecordedFrame is one websocket message, or a run of identical ones.
+type RecordedFrame struct {
+	// DelayMs is the wait before the frame, after the previous one
+	DelayMs int `json:"delay_ms"`
+	// Text is a text message, sent as recorded
+	Text json.RawMessage `json:"text,omitempty"`
+	// Binary is a base64 binary message
+	Binary string `json:"binary,omitempty"`
+	// Repeat sends the frame this many times, 20 ms apart, which is how
+	// runs of silence or a steady tone are kept short
+	Repeat int `json:"repeat,omitempty"`
+}
+
+// LoadRecording reads a recording file.
+func LoadRecording(path string) (*Recording, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read recording: %w", err)
+	}
+	var r Recording
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("parse recording %s: %w", path, err)
+	}
+	if r.Carrier == "" {
+		return nil, fmt.Errorf("recording %s names no carrier", path)
+	}
+	if len(r.Frames) == 0 {
+		return nil, fmt.Errorf("recording %s has no frames", path)
+	}
+	return &r, nil
+}
+
+// ReplayClient plays a carrier on the bot's media stream by replaying
+// recorded frames, and keeps what the bot sends back.
+type ReplayClient struct {
+	conn *gws.Conn
+
+	mu sync.Mutex
+	// messages counts the bot's control messages by name
+	messages    map[string]int
+	audioBytes  int
+	lastAudioAt time.Time
+	readErr     error
+}
+
+// DialReplay connects to the bot's media stream endpoint for a carrier.
+func DialReplay(streamURL string) (*ReplayClient, error) {
+	conn, _, err := gws.DefaultDialer.Dial(streamURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", streamURL, err)
+	}
+	c := &ReplayClient{conn: conn, messages: make(map[string]int)}
+	go c.readLoop()
+	return c, nil
+}
+
+// Replay sends the frames with their recorded timing and returns after the
+// last one.
+func (c *ReplayClient) Replay(frames []RecordedFrame) error {
+	for i, frame := range frames {
+		time.Sleep(time.Duration(frame.DelayMs) * time.Millisecond)
+		messageType, data := gws.TextMessage, []byte(frame.Text)
+		if frame.Binary != "" {
+			decoded, err := base64.StdEncoding.DecodeString(frame.Binary)
+			if err != nil {
+				return fmt.Errorf("frame %d: %w", i+1, err)
+			}
+			messageType, data = gws.BinaryMessage, decoded
+		}
+		for n := 0; n < max(frame.Repeat, 1); n++ {
+			if n > 0 {
+				time.Sleep(audio.FrameDuration)
+			}
+			if err := c.conn.WriteMessage(messageType, data); err != nil {
+				return fmt.Errorf("frame %d: %w", i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Messages returns how many control messages of each name the bot sent.
+func (c *ReplayClient) Messages() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	messages := make(map[string]int, len(c.messages))
+	for name, n := range c.messages {
+		messages[name] = n
+	}
+	return messages
+}
+
+// AudioBytes is how much audio the bot sent, in the carrier's format.
+func (c *ReplayClient) AudioBytes() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.audioBytes
+}
+
+// Quiet reports whether the bot has sent no audio for at least d.
+func (c *ReplayClient) Quiet(d time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Since(c.lastAudioAt) >= d
+}
+
+// Err returns why the bot's side of the stream ended, if it did.
+func (c *ReplayClient) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.readErr
+}
+
+func (c *ReplayClient) Close() error {
+	return c.conn.Close()
+}
+
+func (c *ReplayClient) readLoop() {
+	for {
+		messageType, data, err := c.conn.ReadMessage()
+		if err != nil {
+			c.mu.Lock()
+			c.readErr = err
+			c.mu.Unlock()
+			return
+		}
+		if messageType == gws.BinaryMessage {
+			c.receiveAudio(len(data))
+			continue
+		}
+		var msg struct {
+			Event  string `json:"event"`
+			Action string `json:"action"`
+			Media  struct {
+				Payload string `json:"payload"`
+			} `json:"media"`
+		}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+		name := msg.Event
+		if name == "" {
+			name = msg.Action
+		}
+		c.mu.Lock()
+		c.messages[name]++
+		c.mu.Unlock()
+		if msg.Media.Payload != "" {
+			c.receiveAudio(base64.StdEncoding.DecodedLen(len(msg.Media.Payload)))
+		}
+	}
+}
+
+func (c *ReplayClient) receiveAudio(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.audioBytes += n
+	c.lastAudioAt = time.Now()
+}
+
+// ReplayReport is the outcome of a replayed recording. It passed when
+// Failures is empty.
+type ReplayReport struct {
+	Carrier    string         `json:"carrier"`
+	Messages   map[string]int `json:"messages"`
+	AudioBytes int            `json:"audio_bytes"`
+	// Spoken is every text the bot sent to TTS
+	Spoken   []string `json:"spoken"`
+	Failures []string `json:"failures"`
+}
+
+func (r *ReplayReport) failf(format string, args ...interface{}) {
+	r.Failures = append(r.Failures, fmt.Sprintf(format, args...))
+}
+
+// RunRecording replays a recording on the bot's media stream endpoint for
+// its carrier and checks the bot heard every turn, said what was expected
+// and answered in the carrier's framing.
+func RunRecording(streamURL string, rec *Recording, fakes *Fakes) (*ReplayReport, error) {
+	client, err := DialReplay(streamURL)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	if err := client.Replay(rec.Frames); err != nil {
+		return nil, err
+	}
+	// A recording that ends without stopping the stream leaves the bot
+	// answering; wait for it to finish
+	if client.Err() == nil {
+		err := poll(2*time.Minute, func() (bool, error) {
+			return client.Quiet(quietPeriod), client.Err()
+		}, "the bot kept talking")
+		if err != nil && client.Err() == nil {
+			return nil, err
+		}
+	}
+
+	report := &ReplayReport{
+		Carrier:    rec.Carrier,
+		Messages:   client.Messages(),
+		AudioBytes: client.AudioBytes(),
+		Spoken:     fakes.ElevenLabs.Texts(),
+	}
+	if report.AudioBytes == 0 {
+		report.failf("the bot sent no audio")
+	}
+	for _, name := range rec.ExpectMessages {
+		if report.Messages[name] == 0 {
+			report.failf("the bot never sent %q", name)
+		}
+	}
+	said := strings.ToLower(strings.Join(report.Spoken, " "))
+	for i, turn := range rec.Turns {
+		if turn.Transcript != "" && !fakes.OpenAI.Asked(turn.Transcript) {
+			report.failf("turn %d: the model never received %q", i+1, turn.Transcript)
+		}
+		for _, phrase := range turn.Expect {
+			if !strings.Contains(said, strings.ToLower(phrase)) {
+				report.failf("turn %d: expected the bot to say %q, it said %q", i+1, phrase, report.Spoken)
+			}
+		}
+	}
+	return report, nil
+}
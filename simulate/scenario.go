@@ -0,0 +1,312 @@
+// Package simulate runs whole calls against the bot without any vendor
+// account. Fake Deepgram and OpenAI servers, the ElevenLabs fake from
+// package ttsfake and a client that speaks Twilio's media stream protocol
+// stand in for the real services, so conversations can run in CI. Other
+// carriers are covered by replaying recorded frames of their protocols.
+package simulate
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mrsingh-rishi/voice-bot/audio"
+	"github.com/mrsingh-rishi/voice-bot/tts/ttsfake"
+)
+
+const (
+	// defaultSpeechMs is how long a turn without a WAV file speaks for
+	defaultSpeechMs = 1200
+	// replyTimeout bounds the wait for the bot to start answering a turn
+	replyTimeout = 15 * time.Second
+	// quietPeriod of silence from the bot means it finished its reply
+	quietPeriod = 1500 * time.Millisecond
+	// hangupTimeout bounds the wait for a bot expected to hang up
+	hangupTimeout = 2 * time.Minute
+)
+
+// Scenario is a scripted conversation, loaded from JSON.
+type Scenario struct {
+	// Agent is the agent ID to call; empty means the default agent
+	Agent string `json:"agent"`
+	Turns []Turn `json:"turns"`
+	// ExpectHangup means the bot ends the call itself, e.g. on a silent
+	// caller or at the maximum call duration. Turns left once it has hung
+	// up are skipped.
+	ExpectHangup bool `json:"expect_hangup"`
+
+	dir string
+}
+
+// Turn is one thing the caller says and what the fakes make of it.
+type Turn struct {
+	// Audio is a WAV file with the caller's voice, relative to the scenario
+	// file. Without it SpeechMs of synthetic voice is sent.
+	Audio    string `json:"audio"`
+	SpeechMs int    `json:"speech_ms"`
+	// Transcript is what the fake Deepgram returns for the audio
+	Transcript string `json:"transcript"`
+	Language   string `json:"language"`
+	// Reply is what the fake OpenAI answers; empty echoes the transcript
+	Reply string `json:"reply"`
+	// Expect lists phrases the bot must say in response
+	Expect []string `json:"expect"`
+	// BargeIn starts speaking as soon as the bot starts answering the
+	// previous turn instead of waiting for it to finish
+	BargeIn bool `json:"barge_in"`
+}
+
+// LoadScenario reads a scenario file.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read scenario: %w", err)
+	}
+	var s Scenario
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse scenario %s: %w", path, err)
+	}
+	if len(s.Turns) == 0 {
+		return nil, fmt.Errorf("scenario %s has no turns", path)
+	}
+	s.dir = filepath.Dir(path)
+	return &s, nil
+}
+
+// callerAudio returns the turn's audio as 8 kHz mu-law.
+func (s *Scenario) callerAudio(turn Turn) ([]byte, error) {
+	if turn.Audio == "" {
+		ms := turn.SpeechMs
+		if ms <= 0 {
+			ms = defaultSpeechMs
+		}
+		return audio.EncodeMuLaw(syntheticSpeech(time.Duration(ms) * time.Millisecond)), nil
+	}
+	path := turn.Audio
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.dir, path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	wav, err := audio.ReadWAV(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	samples, err := wav.Samples()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return audio.EncodeMuLaw(audio.Resample(samples, wav.SampleRate, audio.Rate8k)), nil
+}
+
+// syntheticSpeech is a voiced, syllable-modulated tone the VAD takes for
+// speech: a 140 Hz pitch with falling harmonics, pulsing four times a second.
+func syntheticSpeech(d time.Duration) []int16 {
+	n := int(d * audio.Rate8k / time.Second)
+	samples := make([]int16, n)
+	for i := range samples {
+		t := float64(i) / audio.Rate8k
+		var v float64
+		for h := 1; h <= 12; h++ {
+			v += math.Sin(2*math.Pi*140*float64(h)*t) / float64(h)
+		}
+		envelope := 0.6 + 0.4*math.Sin(2*math.Pi*4*t)
+		samples[i] = int16(v * envelope * 4000)
+	}
+	return samples
+}
+
+// Fakes are the stand-in vendors for one scenario.
+type Fakes struct {
+	Deepgram   *DeepgramServer
+	OpenAI     *OpenAIServer
+	ElevenLabs *ttsfake.Server
+}
+
+// StartFakes starts fake vendors scripted with the scenario's transcripts
+// and replies.
+func StartFakes(s *Scenario) *Fakes {
+	var script []ScriptedTranscript
+	replies := map[string]string{}
+	for _, turn := range s.Turns {
+		script = append(script, ScriptedTranscript{Text: turn.Transcript, Language: turn.Language})
+		if turn.Reply != "" {
+			replies[turn.Transcript] = turn.Reply
+		}
+	}
+	return &Fakes{
+		Deepgram:   NewDeepgramServer(script),
+		OpenAI:     NewOpenAIServer(replies),
+		ElevenLabs: ttsfake.NewServer(),
+	}
+}
+
+// Env is the environment that points the bot at the fakes. The phrase cache
+// stays in memory so fake audio never reaches a real cache directory.
+func (f *Fakes) Env() map[string]string {
+	return map[string]string{
+		"DEEPGRAM_API_KEY":     "simulated",
+		"DEEPGRAM_URL":         f.Deepgram.URL,
+		"OPEN_AI_API_KEY":      "simulated",
+		"OPEN_AI_BASE_URL":     f.OpenAI.URL,
+		"ELEVEN_LABS_API_KEY":  "simulated",
+		"ELEVEN_LABS_BASE_URL": f.ElevenLabs.URL,
+		"TTS_CACHE_DIR":        "",
+	}
+}
+
+func (f *Fakes) Close() {
+	f.Deepgram.Close()
+	f.OpenAI.Close()
+	f.ElevenLabs.Close()
+}
+
+// TurnReport is what happened in one turn.
+type TurnReport struct {
+	Transcript string `json:"transcript"`
+	// Spoken is every text the bot sent to TTS while answering
+	Spoken []string `json:"spoken"`
+	// FirstAudioMs is from the end of the caller's audio to the bot's first
+	// audio, fillers included
+	FirstAudioMs int64 `json:"first_audio_ms"`
+	// Interrupted is set when the caller cut the bot off during the turn
+	Interrupted bool `json:"interrupted"`
+}
+
+// Report is the outcome of a scenario. The scenario passed when Failures is empty.
+type Report struct {
+	Turns []TurnReport `json:"turns"`
+	// HungUp is set when the bot ended the call
+	HungUp   bool     `json:"hung_up"`
+	Failures []string `json:"failures"`
+}
+
+func (r *Report) failf(format string, args ...interface{}) {
+	r.Failures = append(r.Failures, fmt.Sprintf(format, args...))
+}
+
+// Run places a simulated call on the bot's media stream endpoint and plays
+// the scenario through it. The error is for calls that could not run at
+// all; unmet expectations are reported as failures.
+func Run(streamURL string, callSid string, s *Scenario, fakes *Fakes) (*Report, error) {
+	client, err := DialTwilio(streamURL, callSid)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Stop()
+
+	report := &Report{}
+	// hungUp reports whether the bot ended the call, as the scenario expects
+	hungUp := func() bool {
+		report.HungUp = client.Err() != nil
+		return report.HungUp && s.ExpectHangup
+	}
+	// The greeting comes first
+	if err := waitForReply(client, 0); err != nil {
+		if hungUp() {
+			return report, nil
+		}
+		report.failf("greeting: %v", err)
+	} else if err := waitForQuiet(client); err != nil {
+		if hungUp() {
+			return report, nil
+		}
+		return report, err
+	}
+
+	for i, turn := range s.Turns {
+		mulaw, err := s.callerAudio(turn)
+		if err != nil {
+			return report, fmt.Errorf("turn %d: %w", i+1, err)
+		}
+		spokenBefore := len(fakes.ElevenLabs.Texts())
+		clearsBefore := client.Clears()
+		if err := client.Play(mulaw); err != nil {
+			if hungUp() {
+				return report, nil
+			}
+			return report, fmt.Errorf("turn %d: %w", i+1, err)
+		}
+		spokeAt := time.Now()
+		botBytes := client.BotBytes()
+
+		tr := TurnReport{Transcript: turn.Transcript}
+		if err := waitForReply(client, botBytes); err != nil {
+			if !hungUp() {
+				report.failf("turn %d: %v", i+1, err)
+			}
+		} else {
+			tr.FirstAudioMs = time.Since(spokeAt).Milliseconds()
+		}
+		if i+1 == len(s.Turns) || !s.Turns[i+1].BargeIn {
+			if err := waitForQuiet(client); err != nil && !hungUp() {
+				return report, err
+			}
+		}
+		tr.Spoken = fakes.ElevenLabs.Texts()[spokenBefore:]
+		tr.Interrupted = client.Clears() > clearsBefore
+		report.Turns = append(report.Turns, tr)
+
+		if turn.Transcript != "" && !fakes.OpenAI.Asked(turn.Transcript) {
+			report.failf("turn %d: the model never received %q", i+1, turn.Transcript)
+		}
+		said := strings.ToLower(strings.Join(tr.Spoken, " "))
+		for _, phrase := range turn.Expect {
+			if !strings.Contains(said, strings.ToLower(phrase)) {
+				report.failf("turn %d: expected the bot to say %q, it said %q", i+1, phrase, tr.Spoken)
+			}
+		}
+		if turn.BargeIn && !tr.Interrupted {
+			report.failf("turn %d: barging in did not interrupt the bot", i+1)
+		}
+		if hungUp() {
+			return report, nil
+		}
+	}
+	if s.ExpectHangup {
+		select {
+		case <-client.Done():
+			hungUp()
+		case <-time.After(hangupTimeout):
+			report.failf("the bot did not hang up within %s", hangupTimeout)
+		}
+	}
+	return report, nil
+}
+
+// waitForReply waits until the bot sends more than after bytes of audio.
+func waitForReply(client *TwilioClient, after int) error {
+	return poll(replyTimeout, func() (bool, error) {
+		return client.BotBytes() > after, client.Err()
+	}, "the bot did not answer within %s", replyTimeout)
+}
+
+// waitForQuiet waits until the bot's audio has played out and it has gone quiet.
+func waitForQuiet(client *TwilioClient) error {
+	timeout := 2 * time.Minute
+	return poll(timeout, func() (bool, error) {
+		return client.BotQuiet(quietPeriod), client.Err()
+	}, "the bot kept talking for %s", timeout)
+}
+
+func poll(timeout time.Duration, done func() (bool, error), format string, args ...interface{}) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		ok, err := done()
+		if ok {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("stream closed: %w", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return fmt.Errorf(format, args...)
+}
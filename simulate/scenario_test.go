@@ -0,0 +1,124 @@
+package simulate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	gws "github.com/gorilla/websocket"
+)
+
+func TestLoadScenario(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr string
+	}{
+		{"valid", `{"agent": "support", "expect_hangup": true, "turns": [{"transcript": "Hi"}]}`, ""},
+		{"no turns", `{"turns": []}`, "has no turns"},
+		{"invalid", `{"turns": `, "parse scenario"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "scenario.json")
+			if err := os.WriteFile(path, []byte(tt.json), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			s, err := LoadScenario(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadScenario() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.Agent != "support" || !s.ExpectHangup || len(s.Turns) != 1 {
+				t.Errorf("LoadScenario() = %+v", s)
+			}
+		})
+	}
+}
+
+func TestTestdataScenarios(t *testing.T) {
+	paths, err := filepath.Glob("testdata/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		if _, err := LoadScenario(path); err != nil {
+			if _, recErr := LoadRecording(path); recErr != nil {
+				t.Errorf("%s is neither a scenario (%v) nor a recording (%v)", path, err, recErr)
+			}
+		}
+	}
+}
+
+func TestCallerAudio(t *testing.T) {
+	s := &Scenario{}
+	mulaw, err := s.callerAudio(Turn{SpeechMs: 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mulaw) != 4000 {
+		t.Errorf("500 ms of speech is %d bytes, want 4000", len(mulaw))
+	}
+	if _, err := s.callerAudio(Turn{Audio: "missing.wav"}); err == nil {
+		t.Error("callerAudio read a missing WAV file")
+	}
+}
+
+// hangUpAfterGreeting is a bot that greets the caller and hangs up.
+func hangUpAfterGreeting(conn *gws.Conn, streamSid string) {
+	if botSpeaks(conn, streamSid, 200*time.Millisecond, "utterance-1") != nil {
+		return
+	}
+	time.Sleep(quietPeriod + 500*time.Millisecond)
+}
+
+func TestRunBotHangsUp(t *testing.T) {
+	tests := []struct {
+		name         string
+		expectHangup bool
+		wantErr      bool
+	}{
+		{"expected", true, false},
+		{"unexpected", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := fakeBot(t, hangUpAfterGreeting)
+			s := &Scenario{
+				ExpectHangup: tt.expectHangup,
+				Turns:        []Turn{{SpeechMs: 3000, Transcript: "Hello?"}},
+			}
+			fakes := StartFakes(s)
+			defer fakes.Close()
+
+			done := make(chan struct{})
+			var report *Report
+			var err error
+			go func() {
+				defer close(done)
+				report, err = Run(url, "CAtest", s, fakes)
+			}()
+			select {
+			case <-done:
+			case <-time.After(30 * time.Second):
+				t.Fatal("Run still blocked after the bot hung up")
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !report.HungUp {
+				t.Error("report does not say the bot hung up")
+			}
+			if tt.expectHangup && len(report.Failures) > 0 {
+				t.Errorf("failures: %v", report.Failures)
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package simulate
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gws "github.com/gorilla/websocket"
+	"github.com/mrsingh-rishi/voice-bot/audio"
+	"github.com/mrsingh-rishi/voice-bot/auth"
+	"github.com/mrsingh-rishi/voice-bot/logging"
+)
+
+// muLawSilence is digital silence in mu-law.
+const muLawSilence = 0xFF
+
+// TwilioAuthToken is the auth token the simulated Twilio account signs its
+// requests with; the bot under test must be started with it.
+const TwilioAuthToken = "simulated"
+
+// TwilioClient plays the part of Twilio on a bidirectional media stream. It
+// sends the caller's audio in real time, 20 ms frames with silence in
+// between, and plays the bot's audio back on a simulated clock so marks are
+// acked when Twilio would ack them.
+type TwilioClient struct {
+	StreamSid string
+	CallSid   string
+
+	conn     *gws.Conn
+	writeMu  sync.Mutex
+	sequence int
+	frames   chan []byte
+	// done is closed when the stream ends, from either side
+	done     chan struct{}
+	endOnce  sync.Once
+	stopOnce sync.Once
+
+	mu sync.Mutex
+	// sentFrames counts caller frames, silence included
+	sentFrames int
+	// botBytes counts the bot's audio received so far
+	botBytes    int
+	lastMediaAt time.Time
+	// playoutUntil is when the bot audio received so far finishes playing
+	playoutUntil time.Time
+	pendingMarks map[string]*time.Timer
+	clears       int
+	// err is why the bot's side of the stream ended
+	err error
+}
+
+// DialTwilio connects to the bot's media stream endpoint and starts the
+// stream the way Twilio does: a signed upgrade, then "connected" and
+// "start" events.
+func DialTwilio(streamURL string, callSid string) (*TwilioClient, error) {
+	header := http.Header{}
+	header.Set(auth.TwilioSignatureHeader, auth.TwilioSignature(TwilioAuthToken, streamURL, nil))
+	conn, _, err := gws.DefaultDialer.Dial(streamURL, header)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", streamURL, err)
+	}
+	c := &TwilioClient{
+		StreamSid:    "MZ" + strings.TrimPrefix(callSid, "CA"),
+		CallSid:      callSid,
+		conn:         conn,
+		frames:       make(chan []byte),
+		done:         make(chan struct{}),
+		pendingMarks: make(map[string]*time.Timer),
+	}
+	if err := c.send(map[string]interface{}{"event": "connected", "protocol": "Call", "version": "1.0.0"}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	err = c.send(map[string]interface{}{
+		"event":     "start",
+		"streamSid": c.StreamSid,
+		"start": map[string]interface{}{
+			"callSid":   callSid,
+			"streamSid": c.StreamSid,
+			"tracks":    []string{"inbound", "outbound"},
+			"mediaFormat": map[string]interface{}{
+				"encoding":   "audio/x-mulaw",
+				"sampleRate": 8000,
+				"channels":   1,
+			},
+		},
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go c.readLoop()
+	go c.writeLoop()
+	return c, nil
+}
+
+// Play sends mu-law audio as the caller's voice and returns once the last
+// frame is on the wire, or with an error once the stream has ended.
+func (c *TwilioClient) Play(mulaw []byte) error {
+	for _, frame := range audio.Frames(mulaw, audio.TwilioFrameBytes) {
+		select {
+		case c.frames <- frame:
+		case <-c.done:
+			if err := c.Err(); err != nil {
+				return fmt.Errorf("stream closed: %w", err)
+			}
+			return fmt.Errorf("stream stopped")
+		}
+	}
+	return nil
+}
+
+// Pause sends d of silence.
+func (c *TwilioClient) Pause(d time.Duration) error {
+	silence := make([]byte, audio.Telephony.FrameBytes(d))
+	for i := range silence {
+		silence[i] = muLawSilence
+	}
+	return c.Play(silence)
+}
+
+// BotBytes is how much of the bot's audio has been received.
+func (c *TwilioClient) BotBytes() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.botBytes
+}
+
+// Clears is how many times the bot cleared its buffered audio.
+func (c *TwilioClient) Clears() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.clears
+}
+
+// BotQuiet reports whether the bot's audio has finished playing and none
+// has arrived for at least quiet.
+func (c *TwilioClient) BotQuiet(quiet time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	return now.After(c.playoutUntil) && now.Sub(c.lastMediaAt) >= quiet
+}
+
+// Err returns why the bot's side of the stream ended, if it did.
+func (c *TwilioClient) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Done is closed once the stream has ended, e.g. because the bot hung up.
+func (c *TwilioClient) Done() <-chan struct{} {
+	return c.done
+}
+
+// end records why the stream ended and stops the write loop.
+func (c *TwilioClient) end(err error) {
+	c.endOnce.Do(func() {
+		c.mu.Lock()
+		c.err = err
+		c.mu.Unlock()
+		close(c.done)
+	})
+}
+
+// Stop ends the stream with a "stop" event and closes the socket.
+func (c *TwilioClient) Stop() error {
+	var err error
+	c.stopOnce.Do(func() {
+		c.end(nil)
+		err = c.send(map[string]interface{}{
+			"event":     "stop",
+			"streamSid": c.StreamSid,
+			"stop":      map[string]string{"callSid": c.CallSid},
+		})
+		c.mu.Lock()
+		for _, timer := range c.pendingMarks {
+			timer.Stop()
+		}
+		c.mu.Unlock()
+		time.Sleep(100 * time.Millisecond)
+		c.conn.Close()
+	})
+	return err
+}
+
+func (c *TwilioClient) send(event map[string]interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.sequence++
+	event["sequenceNumber"] = strconv.Itoa(c.sequence)
+	return c.conn.WriteJSON(event)
+}
+
+// writeLoop sends one frame every 20 ms, silence when the caller is quiet,
+// as Twilio streams continuously for the whole call.
+func (c *TwilioClient) writeLoop() {
+	ticker := time.NewTicker(audio.FrameDuration)
+	defer ticker.Stop()
+	silence := make([]byte, audio.TwilioFrameBytes)
+	for i := range silence {
+		silence[i] = muLawSilence
+	}
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+		frame := silence
+		select {
+		case frame = <-c.frames:
+		default:
+		}
+		c.mu.Lock()
+		c.sentFrames++
+		timestamp := c.sentFrames * int(audio.FrameDuration/time.Millisecond)
+		c.mu.Unlock()
+		err := c.send(map[string]interface{}{
+			"event":     "media",
+			"streamSid": c.StreamSid,
+			"media": map[string]string{
+				"track":     "inbound",
+				"timestamp": strconv.Itoa(timestamp),
+				"payload":   base64.StdEncoding.EncodeToString(frame),
+			},
+		})
+		if err != nil {
+			c.end(err)
+			return
+		}
+	}
+}
+
+func (c *TwilioClient) readLoop() {
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			c.end(err)
+			return
+		}
+		var ev struct {
+			Event string `json:"event"`
+			Media struct {
+				Payload string `json:"payload"`
+			} `json:"media"`
+			Mark struct {
+				Name string `json:"name"`
+			} `json:"mark"`
+		}
+		if err := json.Unmarshal(data, &ev); err != nil {
+			logging.Logger("simulate").Warn("Fake Twilio got invalid JSON", "error", err)
+			continue
+		}
+		switch ev.Event {
+		case "media":
+			c.receiveMedia(ev.Media.Payload)
+		case "mark":
+			c.scheduleAck(ev.Mark.Name)
+		case "clear":
+			c.clear()
+		}
+	}
+}
+
+func (c *TwilioClient) receiveMedia(payload string) {
+	chunk, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		logging.Logger("simulate").Warn("Fake Twilio got invalid media", "error", err)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if c.playoutUntil.Before(now) {
+		c.playoutUntil = now
+	}
+	c.playoutUntil = c.playoutUntil.Add(time.Duration(len(chunk)) * time.Second / 8000)
+	c.botBytes += len(chunk)
+	c.lastMediaAt = now
+}
+
+// scheduleAck echoes a mark once the audio sent before it has played.
+func (c *TwilioClient) scheduleAck(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pendingMarks[name] = time.AfterFunc(time.Until(c.playoutUntil), func() {
+		c.ack(name)
+	})
+}
+
+func (c *TwilioClient) ack(name string) {
+	c.mu.Lock()
+	_, ok := c.pendingMarks[name]
+	delete(c.pendingMarks, name)
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+	c.send(map[string]interface{}{
+		"event":     "mark",
+		"streamSid": c.StreamSid,
+		"mark":      map[string]string{"name": name},
+	})
+}
+
+// clear drops the buffered bot audio; Twilio acks every pending mark.
+func (c *TwilioClient) clear() {
+	c.mu.Lock()
+	c.clears++
+	c.playoutUntil = time.Now()
+	names := make([]string, 0, len(c.pendingMarks))
+	for name, timer := range c.pendingMarks {
+		timer.Stop()
+		names = append(names, name)
+	}
+	c.mu.Unlock()
+	for _, name := range names {
+		c.ack(name)
+	}
+}
@@ -0,0 +1,181 @@
+package simulate
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gws "github.com/gorilla/websocket"
+	"github.com/mrsingh-rishi/voice-bot/audio"
+)
+
+// fakeBot serves a media stream endpoint that hands every connection to
+// handle once the caller's "start" event arrived.
+func fakeBot(t *testing.T, handle func(conn *gws.Conn, streamSid string)) string {
+	t.Helper()
+	upgrader := gws.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var ev struct {
+				Event     string `json:"event"`
+				StreamSid string `json:"streamSid"`
+			}
+			if err := conn.ReadJSON(&ev); err != nil {
+				return
+			}
+			if ev.Event == "start" {
+				handle(conn, ev.StreamSid)
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// botSpeaks sends d of silence as the bot's audio, followed by a mark.
+func botSpeaks(conn *gws.Conn, streamSid string, d time.Duration, mark string) error {
+	chunk := make([]byte, audio.Telephony.FrameBytes(d))
+	err := conn.WriteJSON(map[string]interface{}{
+		"event":     "media",
+		"streamSid": streamSid,
+		"media":     map[string]string{"payload": base64.StdEncoding.EncodeToString(chunk)},
+	})
+	if err != nil || mark == "" {
+		return err
+	}
+	return conn.WriteJSON(map[string]interface{}{
+		"event":     "mark",
+		"streamSid": streamSid,
+		"mark":      map[string]string{"name": mark},
+	})
+}
+
+func TestPlayReturnsWhenBotHangsUp(t *testing.T) {
+	url := fakeBot(t, func(conn *gws.Conn, streamSid string) {
+		time.Sleep(100 * time.Millisecond)
+	})
+	client, err := DialTwilio(url, "CAtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Stop()
+
+	played := make(chan error, 1)
+	go func() {
+		played <- client.Play(make([]byte, audio.Telephony.FrameBytes(10*time.Second)))
+	}()
+	select {
+	case err := <-played:
+		if err == nil {
+			t.Fatal("Play succeeded on a stream the bot hung up")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Play still blocked after the bot hung up")
+	}
+	select {
+	case <-client.Done():
+	default:
+		t.Error("Done not closed after the bot hung up")
+	}
+	if client.Err() == nil {
+		t.Error("Err is nil after the bot hung up")
+	}
+}
+
+func TestMarkAckedAfterPlayout(t *testing.T) {
+	const speech = 300 * time.Millisecond
+	acked := make(chan time.Duration, 1)
+	url := fakeBot(t, func(conn *gws.Conn, streamSid string) {
+		sent := time.Now()
+		if err := botSpeaks(conn, streamSid, speech, "utterance-1"); err != nil {
+			return
+		}
+		for {
+			var ev struct {
+				Event string `json:"event"`
+				Mark  struct {
+					Name string `json:"name"`
+				} `json:"mark"`
+			}
+			if err := conn.ReadJSON(&ev); err != nil {
+				return
+			}
+			if ev.Event == "mark" && ev.Mark.Name == "utterance-1" {
+				acked <- time.Since(sent)
+				return
+			}
+		}
+	})
+	client, err := DialTwilio(url, "CAtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Stop()
+
+	select {
+	case after := <-acked:
+		if after < speech-50*time.Millisecond {
+			t.Errorf("mark acked after %s, before %s of audio played", after, speech)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("mark never acked")
+	}
+	if got, want := client.BotBytes(), audio.Telephony.FrameBytes(speech); got != want {
+		t.Errorf("BotBytes() = %d, want %d", got, want)
+	}
+}
+
+func TestClearAcksPendingMarks(t *testing.T) {
+	acked := make(chan string, 1)
+	url := fakeBot(t, func(conn *gws.Conn, streamSid string) {
+		if err := botSpeaks(conn, streamSid, 10*time.Second, "utterance-1"); err != nil {
+			return
+		}
+		if err := conn.WriteJSON(map[string]string{"event": "clear", "streamSid": streamSid}); err != nil {
+			return
+		}
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var ev struct {
+				Event string `json:"event"`
+				Mark  struct {
+					Name string `json:"name"`
+				} `json:"mark"`
+			}
+			if json.Unmarshal(data, &ev) == nil && ev.Event == "mark" {
+				acked <- ev.Mark.Name
+				return
+			}
+		}
+	})
+	client, err := DialTwilio(url, "CAtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Stop()
+
+	select {
+	case name := <-acked:
+		if name != "utterance-1" {
+			t.Errorf("acked mark %q, want utterance-1", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("clear did not ack the pending mark")
+	}
+	if client.Clears() != 1 {
+		t.Errorf("Clears() = %d, want 1", client.Clears())
+	}
+}
@@ -0,0 +1,199 @@
+// Package sip is a minimal SIP user agent over UDP so a PBX can send calls
+// straight to the bot. It answers INVITEs with G.711 over SDP, exchanges RTP
+// with RFC 2833 telephone events for keys, handles BYE and re-INVITE holds,
+// and hands every call to the pipeline as a transport.Transport.
+package sip
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// compactHeaders maps the single-letter header forms to their full names.
+var compactHeaders = map[string]string{
+	"v": "Via",
+	"f": "From",
+	"t": "To",
+	"i": "Call-ID",
+	"m": "Contact",
+	"l": "Content-Length",
+	"c": "Content-Type",
+	"k": "Supported",
+}
+
+type header struct {
+	Name  string
+	Value string
+}
+
+// Message is a SIP request or response. Requests have a Method; responses
+// a StatusCode.
+type Message struct {
+	Method     string
+	RequestURI string
+	StatusCode int
+	Reason     string
+	headers    []header
+	Body       []byte
+}
+
+// ParseMessage parses one SIP message from a UDP datagram.
+func ParseMessage(data []byte) (*Message, error) {
+	head, body, ok := bytes.Cut(data, []byte("\r\n\r\n"))
+	if !ok {
+		return nil, fmt.Errorf("sip message has no end of headers")
+	}
+	lines := strings.Split(string(head), "\r\n")
+	m := &Message{}
+	start := strings.SplitN(lines[0], " ", 3)
+	if len(start) < 3 {
+		return nil, fmt.Errorf("invalid start line %q", lines[0])
+	}
+	if strings.HasPrefix(start[0], "SIP/") {
+		code, err := strconv.Atoi(start[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid status line %q", lines[0])
+		}
+		m.StatusCode, m.Reason = code, start[2]
+	} else {
+		m.Method, m.RequestURI = start[0], start[1]
+	}
+	for _, line := range lines[1:] {
+		// Folded continuation lines belong to the previous header
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(m.headers) > 0 {
+			m.headers[len(m.headers)-1].Value += " " + strings.TrimSpace(line)
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		if full, ok := compactHeaders[strings.ToLower(name)]; ok {
+			name = full
+		}
+		m.headers = append(m.headers, header{Name: name, Value: strings.TrimSpace(value)})
+	}
+	if n, err := strconv.Atoi(m.Get("Content-Length")); err == nil && n <= len(body) {
+		body = body[:n]
+	}
+	m.Body = body
+	return m, nil
+}
+
+// Get returns the first value of a header.
+func (m *Message) Get(name string) string {
+	for _, h := range m.headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// Values returns every value of a header, in order.
+func (m *Message) Values(name string) []string {
+	var values []string
+	for _, h := range m.headers {
+		if strings.EqualFold(h.Name, name) {
+			values = append(values, h.Value)
+		}
+	}
+	return values
+}
+
+func (m *Message) Add(name, value string) {
+	m.headers = append(m.headers, header{Name: name, Value: value})
+}
+
+// CSeq returns the sequence number and method of the CSeq header.
+func (m *Message) CSeq() (int, string) {
+	seq, method, _ := strings.Cut(m.Get("CSeq"), " ")
+	n, _ := strconv.Atoi(seq)
+	return n, strings.TrimSpace(method)
+}
+
+// Bytes serializes the message, with Content-Length set from the body.
+func (m *Message) Bytes() []byte {
+	var b bytes.Buffer
+	if m.Method != "" {
+		fmt.Fprintf(&b, "%s %s SIP/2.0\r\n", m.Method, m.RequestURI)
+	} else {
+		fmt.Fprintf(&b, "SIP/2.0 %d %s\r\n", m.StatusCode, m.Reason)
+	}
+	for _, h := range m.headers {
+		if strings.EqualFold(h.Name, "Content-Length") {
+			continue
+		}
+		fmt.Fprintf(&b, "%s: %s\r\n", h.Name, h.Value)
+	}
+	fmt.Fprintf(&b, "Content-Length: %d\r\n\r\n", len(m.Body))
+	b.Write(m.Body)
+	return b.Bytes()
+}
+
+// Response builds a response to the request, copying the headers that tie
+// it to the transaction and dialog. toTag is added to To unless it already
+// has one.
+func (m *Message) Response(code int, reason string, toTag string) *Message {
+	r := &Message{StatusCode: code, Reason: reason}
+	for _, via := range m.Values("Via") {
+		r.Add("Via", via)
+	}
+	r.Add("From", m.Get("From"))
+	to := m.Get("To")
+	if toTag != "" && tag(to) == "" {
+		to += ";tag=" + toTag
+	}
+	r.Add("To", to)
+	r.Add("Call-ID", m.Get("Call-ID"))
+	r.Add("CSeq", m.Get("CSeq"))
+	return r
+}
+
+// tag returns the tag parameter of a From or To header.
+func tag(value string) string {
+	return param(value, "tag")
+}
+
+// param returns a ";name=value" parameter of a header value.
+func param(value, name string) string {
+	// Parameters of the URI itself sit inside <...>
+	if i := strings.LastIndex(value, ">"); i >= 0 {
+		value = value[i+1:]
+	}
+	for _, p := range strings.Split(value, ";")[1:] {
+		k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// uri returns the SIP URI of a From, To or Contact header.
+func uri(value string) string {
+	if start := strings.Index(value, "<"); start >= 0 {
+		if end := strings.Index(value[start:], ">"); end >= 0 {
+			return value[start+1 : start+end]
+		}
+	}
+	u, _, _ := strings.Cut(value, ";")
+	return strings.TrimSpace(u)
+}
+
+// user returns the user part of a SIP URI, e.g. "support" for
+// "sip:support@pbx.local:5060".
+func user(sipURI string) string {
+	rest := sipURI
+	if _, after, ok := strings.Cut(rest, ":"); ok {
+		rest = after
+	}
+	u, _, ok := strings.Cut(rest, "@")
+	if !ok {
+		return ""
+	}
+	return u
+}
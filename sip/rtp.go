@@ -0,0 +1,83 @@
+package sip
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const rtpHeaderBytes = 12
+
+// rtpPacket is the part of an RTP packet the gateway uses.
+type rtpPacket struct {
+	Marker      bool
+	PayloadType int
+	Sequence    uint16
+	Timestamp   uint32
+	SSRC        uint32
+	Payload     []byte
+}
+
+func parseRTP(data []byte) (rtpPacket, error) {
+	if len(data) < rtpHeaderBytes || data[0]>>6 != 2 {
+		return rtpPacket{}, fmt.Errorf("not an RTP packet")
+	}
+	p := rtpPacket{
+		Marker:      data[1]&0x80 != 0,
+		PayloadType: int(data[1] & 0x7F),
+		Sequence:    binary.BigEndian.Uint16(data[2:]),
+		Timestamp:   binary.BigEndian.Uint32(data[4:]),
+		SSRC:        binary.BigEndian.Uint32(data[8:]),
+	}
+	offset := rtpHeaderBytes + 4*int(data[0]&0x0F)
+	if data[0]&0x10 != 0 {
+		if len(data) < offset+4 {
+			return rtpPacket{}, fmt.Errorf("truncated RTP extension")
+		}
+		offset += 4 + 4*int(binary.BigEndian.Uint16(data[offset+2:]))
+	}
+	end := len(data)
+	if data[0]&0x20 != 0 && end > 0 {
+		end -= int(data[end-1])
+	}
+	if offset > end {
+		return rtpPacket{}, fmt.Errorf("truncated RTP packet")
+	}
+	p.Payload = data[offset:end]
+	return p, nil
+}
+
+func (p rtpPacket) Bytes() []byte {
+	data := make([]byte, rtpHeaderBytes+len(p.Payload))
+	data[0] = 2 << 6
+	data[1] = byte(p.PayloadType & 0x7F)
+	if p.Marker {
+		data[1] |= 0x80
+	}
+	binary.BigEndian.PutUint16(data[2:], p.Sequence)
+	binary.BigEndian.PutUint32(data[4:], p.Timestamp)
+	binary.BigEndian.PutUint32(data[8:], p.SSRC)
+	copy(data[rtpHeaderBytes:], p.Payload)
+	return data
+}
+
+// dtmfKeys are the RFC 2833 event codes of the keypad.
+const dtmfKeys = "0123456789*#ABCD"
+
+// telephoneEvent is an RFC 2833 event payload. A key press is sent as a run
+// of packets with the same RTP timestamp, the last ones with End set.
+type telephoneEvent struct {
+	Key      string
+	End      bool
+	Duration uint16
+}
+
+func parseTelephoneEvent(payload []byte) (telephoneEvent, bool) {
+	if len(payload) < 4 || int(payload[0]) >= len(dtmfKeys) {
+		return telephoneEvent{}, false
+	}
+	return telephoneEvent{
+		Key:      string(dtmfKeys[payload[0]]),
+		End:      payload[1]&0x80 != 0,
+		Duration: binary.BigEndian.Uint16(payload[2:]),
+	}, true
+}
@@ -0,0 +1,173 @@
+package sip
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/mrsingh-rishi/voice-bot/audio"
+)
+
+// Static RTP payload types of G.711.
+const (
+	payloadPCMU = 0
+	payloadPCMA = 8
+)
+
+// Direction is the SDP media direction attribute.
+type Direction string
+
+const (
+	SendRecv Direction = "sendrecv"
+	SendOnly Direction = "sendonly"
+	RecvOnly Direction = "recvonly"
+	Inactive Direction = "inactive"
+)
+
+// Offer is what a caller's SDP proposes for the audio stream.
+type Offer struct {
+	// Addr is where the caller receives RTP; nil means no audio is wanted,
+	// the old way to put a call on hold
+	Addr *net.UDPAddr
+	// Payloads lists the offered payload types in order of preference
+	Payloads []int
+	// Codecs maps dynamic payload types to "name/rate", e.g. "telephone-event/8000"
+	Codecs    map[int]string
+	Direction Direction
+}
+
+// ParseOffer reads the first audio stream of an SDP body.
+func ParseOffer(body []byte) (*Offer, error) {
+	o := &Offer{Codecs: make(map[int]string), Direction: SendRecv}
+	var sessionIP, mediaIP string
+	var port int
+	inAudio, seenAudio := false, false
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		kind, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch kind {
+		case "m":
+			fields := strings.Fields(value)
+			inAudio = !seenAudio && len(fields) >= 4 && fields[0] == "audio"
+			if !inAudio {
+				continue
+			}
+			seenAudio = true
+			port, _ = strconv.Atoi(fields[1])
+			for _, f := range fields[3:] {
+				if pt, err := strconv.Atoi(f); err == nil {
+					o.Payloads = append(o.Payloads, pt)
+				}
+			}
+		case "c":
+			fields := strings.Fields(value)
+			if len(fields) < 3 {
+				continue
+			}
+			if inAudio {
+				mediaIP = fields[2]
+			} else if !seenAudio {
+				sessionIP = fields[2]
+			}
+		case "a":
+			if !inAudio && seenAudio {
+				continue
+			}
+			name, attr, _ := strings.Cut(value, ":")
+			switch Direction(name) {
+			case SendRecv, SendOnly, RecvOnly, Inactive:
+				o.Direction = Direction(name)
+				continue
+			}
+			if name == "rtpmap" && inAudio {
+				pt, codec, _ := strings.Cut(attr, " ")
+				if n, err := strconv.Atoi(pt); err == nil {
+					o.Codecs[n] = strings.ToLower(strings.TrimSpace(codec))
+				}
+			}
+		}
+	}
+	if !seenAudio {
+		return nil, fmt.Errorf("sdp offers no audio stream")
+	}
+	ip := mediaIP
+	if ip == "" {
+		ip = sessionIP
+	}
+	if addr := net.ParseIP(ip); addr != nil && !addr.IsUnspecified() && port > 0 {
+		o.Addr = &net.UDPAddr{IP: addr, Port: port}
+	}
+	return o, nil
+}
+
+// Held reports whether the offer puts the call on hold.
+func (o *Offer) Held() bool {
+	return o.Addr == nil || o.Direction == SendOnly || o.Direction == Inactive
+}
+
+// Codec picks G.711 from the offer: the audio format and its payload type.
+func (o *Offer) Codec() (audio.Format, int, bool) {
+	for _, pt := range o.Payloads {
+		switch {
+		case pt == payloadPCMU || o.Codecs[pt] == "pcmu/8000":
+			return audio.Telephony, pt, true
+		case pt == payloadPCMA || o.Codecs[pt] == "pcma/8000":
+			return audio.Format{Codec: audio.CodecALaw, SampleRate: audio.Rate8k}, pt, true
+		}
+	}
+	return audio.Format{}, 0, false
+}
+
+// TelephoneEvent returns the payload type of RFC 2833 events, or -1 when the
+// caller does not send keys in band.
+func (o *Offer) TelephoneEvent() int {
+	for _, pt := range o.Payloads {
+		if o.Codecs[pt] == "telephone-event/8000" {
+			return pt
+		}
+	}
+	return -1
+}
+
+// Answer is the SDP the bot answers with.
+type Answer struct {
+	SessionID int64
+	Version   int
+	IP        net.IP
+	Port      int
+	Payload   int
+	Format    audio.Format
+	// DTMFPayload is the telephone-event payload type, -1 for none
+	DTMFPayload int
+	Direction   Direction
+}
+
+func (a Answer) Bytes() []byte {
+	codec := "PCMU"
+	if a.Format.Codec == audio.CodecALaw {
+		codec = "PCMA"
+	}
+	payloads := strconv.Itoa(a.Payload)
+	if a.DTMFPayload >= 0 {
+		payloads += " " + strconv.Itoa(a.DTMFPayload)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "v=0\r\n")
+	fmt.Fprintf(&b, "o=voice-bot %d %d IN IP4 %s\r\n", a.SessionID, a.Version, a.IP)
+	fmt.Fprintf(&b, "s=voice-bot\r\n")
+	fmt.Fprintf(&b, "c=IN IP4 %s\r\n", a.IP)
+	fmt.Fprintf(&b, "t=0 0\r\n")
+	fmt.Fprintf(&b, "m=audio %d RTP/AVP %s\r\n", a.Port, payloads)
+	fmt.Fprintf(&b, "a=rtpmap:%d %s/8000\r\n", a.Payload, codec)
+	if a.DTMFPayload >= 0 {
+		fmt.Fprintf(&b, "a=rtpmap:%d telephone-event/8000\r\n", a.DTMFPayload)
+		fmt.Fprintf(&b, "a=fmtp:%d 0-15\r\n", a.DTMFPayload)
+	}
+	fmt.Fprintf(&b, "a=ptime:20\r\n")
+	fmt.Fprintf(&b, "a=%s\r\n", a.Direction)
+	return []byte(b.String())
+}
@@ -0,0 +1,331 @@
+package sip
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mrsingh-rishi/voice-bot/logging"
+)
+
+const (
+	// t1 and t2 are the RFC 3261 retransmission timers for 2xx responses
+	t1 = 500 * time.Millisecond
+	t2 = 4 * time.Second
+	// ackTimeout ends a call whose 200 OK was never acknowledged
+	ackTimeout = 64 * t1
+
+	allowedMethods = "INVITE, ACK, BYE, CANCEL, OPTIONS"
+	userAgent      = "voice-bot"
+)
+
+// Server is a SIP user agent on UDP that answers every INVITE.
+type Server struct {
+	// OnCall, if set, runs each answered call; the call is hung up when it
+	// returns
+	OnCall func(s *Session)
+	Log    *slog.Logger
+
+	conn *net.UDPConn
+	// publicIP, if set, is advertised in SDP and Contact instead of the
+	// address the caller reached us on, e.g. behind NAT
+	publicIP net.IP
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// NewServer listens for SIP on addr, e.g. "0.0.0.0:5060". publicIP is
+// optional.
+func NewServer(addr string, publicIP string) (*Server, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SIP address %q: %w", addr, err)
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{conn: conn, sessions: make(map[string]*Session), Log: logging.Logger("sip")}
+	if publicIP != "" {
+		if s.publicIP = net.ParseIP(publicIP); s.publicIP == nil {
+			conn.Close()
+			return nil, fmt.Errorf("invalid public IP %q", publicIP)
+		}
+	}
+	return s, nil
+}
+
+// Addr is the address the server listens on.
+func (s *Server) Addr() *net.UDPAddr {
+	return s.conn.LocalAddr().(*net.UDPAddr)
+}
+
+// Serve handles SIP messages until the server is closed.
+func (s *Server) Serve() error {
+	buf := make([]byte, 65535)
+	for {
+		n, from, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			return err
+		}
+		data := string(buf[:n])
+		// CRLF keep-alives carry no message
+		if strings.TrimSpace(data) == "" {
+			continue
+		}
+		msg, err := ParseMessage([]byte(data))
+		if err != nil {
+			s.Log.Warn("Ignoring invalid SIP message", "from", from.String(), "error", err)
+			continue
+		}
+		if msg.Method != "" {
+			s.handleRequest(msg, from)
+		}
+	}
+}
+
+// Close hangs up every call and stops listening.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	sessions := make([]*Session, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	s.mu.Unlock()
+	for _, session := range sessions {
+		session.Close()
+	}
+	return s.conn.Close()
+}
+
+func (s *Server) handleRequest(req *Message, from *net.UDPAddr) {
+	callID := req.Get("Call-ID")
+	s.mu.Lock()
+	session := s.sessions[callID]
+	s.mu.Unlock()
+
+	switch req.Method {
+	case "INVITE":
+		if session == nil {
+			s.answer(req, from)
+		} else {
+			s.reinvite(session, req, from)
+		}
+	case "ACK":
+		if session != nil {
+			session.ack()
+		}
+	case "BYE":
+		if session == nil {
+			s.send(s.reply(req, 481, "Call/Transaction Does Not Exist", ""), from)
+			return
+		}
+		session.log.Info("Caller hung up")
+		s.send(s.reply(req, 200, "OK", session.localTag), from)
+		session.hangUp()
+	case "CANCEL":
+		// INVITEs are answered right away, so there is nothing left to cancel
+		s.send(s.reply(req, 200, "OK", ""), from)
+	case "OPTIONS":
+		resp := s.reply(req, 200, "OK", randomHex(8))
+		resp.Add("Allow", allowedMethods)
+		resp.Add("Accept", "application/sdp")
+		s.send(resp, from)
+	default:
+		resp := s.reply(req, 501, "Not Implemented", "")
+		resp.Add("Allow", allowedMethods)
+		s.send(resp, from)
+	}
+}
+
+// answer accepts a new call with G.711 and starts its media.
+func (s *Server) answer(req *Message, from *net.UDPAddr) {
+	offer, err := ParseOffer(req.Body)
+	if err != nil {
+		s.Log.Warn("Rejecting SIP call", "call_id", req.Get("Call-ID"), "error", err)
+		s.send(s.reply(req, 488, "Not Acceptable Here", randomHex(8)), from)
+		return
+	}
+	if _, _, ok := offer.Codec(); !ok {
+		s.Log.Warn("Rejecting SIP call: no G.711 in the offer", "call_id", req.Get("Call-ID"))
+		s.send(s.reply(req, 488, "Not Acceptable Here", randomHex(8)), from)
+		return
+	}
+	s.send(s.reply(req, 100, "Trying", ""), from)
+
+	ip := s.localIP(from)
+	rtp, err := net.ListenUDP("udp", &net.UDPAddr{IP: s.Addr().IP})
+	if err != nil {
+		s.Log.Error("Failed to open RTP port", "call_id", req.Get("Call-ID"), "error", err)
+		s.send(s.reply(req, 500, "Server Internal Error", randomHex(8)), from)
+		return
+	}
+	session := newSession(s, req, from, rtp, offer)
+	session.answer = Answer{
+		SessionID:   time.Now().Unix(),
+		Version:     1,
+		IP:          ip,
+		Port:        rtp.LocalAddr().(*net.UDPAddr).Port,
+		Payload:     session.payload,
+		Format:      session.format,
+		DTMFPayload: session.dtmfPayload,
+		Direction:   session.direction(),
+	}
+	s.mu.Lock()
+	s.sessions[session.CallID] = session
+	s.mu.Unlock()
+	session.log.Info("Answered SIP call", "from", session.From, "format", session.format.String())
+
+	s.sendAnswer(session, req, from)
+	go session.readLoop()
+	go session.sendLoop()
+	go func() {
+		defer session.Close()
+		if s.OnCall != nil {
+			s.OnCall(session)
+		}
+	}()
+}
+
+// reinvite handles a retransmitted INVITE or a new offer within the call,
+// e.g. putting it on hold.
+func (s *Server) reinvite(session *Session, req *Message, from *net.UDPAddr) {
+	cseq, _ := req.CSeq()
+	session.mu.Lock()
+	retransmission := cseq == session.inviteCSeq
+	last := session.lastResponse
+	session.mu.Unlock()
+	if retransmission {
+		if last != nil {
+			s.send(last, from)
+		}
+		return
+	}
+	offer, err := ParseOffer(req.Body)
+	if err != nil {
+		s.send(s.reply(req, 488, "Not Acceptable Here", session.localTag), from)
+		return
+	}
+	session.update(offer)
+	direction := session.direction()
+	session.mu.Lock()
+	session.inviteCSeq = cseq
+	session.answer.Version++
+	session.answer.Direction = direction
+	session.mu.Unlock()
+	s.sendAnswer(session, req, from)
+}
+
+// sendAnswer sends 200 OK with the session's SDP and repeats it until the
+// caller acknowledges it.
+func (s *Server) sendAnswer(session *Session, req *Message, from *net.UDPAddr) {
+	session.mu.Lock()
+	answer := session.answer
+	session.mu.Unlock()
+	resp := s.reply(req, 200, "OK", session.localTag)
+	resp.Add("Contact", fmt.Sprintf("<sip:%s@%s>", userAgent, net.JoinHostPort(answer.IP.String(), fmt.Sprint(s.Addr().Port))))
+	resp.Add("Allow", allowedMethods)
+	resp.Add("Content-Type", "application/sdp")
+	resp.Body = answer.Bytes()
+
+	acked := make(chan struct{})
+	session.mu.Lock()
+	session.lastResponse = resp
+	session.acked = acked
+	session.mu.Unlock()
+	s.send(resp, from)
+	go s.retransmit(session, resp, from, acked)
+}
+
+// retransmit repeats a 200 OK on the RFC 3261 schedule until it is acked,
+// and gives up on the call if it never is.
+func (s *Server) retransmit(session *Session, resp *Message, to *net.UDPAddr, acked chan struct{}) {
+	interval := t1
+	deadline := time.After(ackTimeout)
+	for {
+		select {
+		case <-acked:
+			return
+		case <-session.done:
+			return
+		case <-deadline:
+			session.log.Error("Caller never acknowledged our answer, hanging up")
+			session.Close()
+			return
+		case <-time.After(interval):
+			s.send(resp, to)
+			interval = min(2*interval, t2)
+		}
+	}
+}
+
+// sendBye hangs up a call from our side.
+func (s *Server) sendBye(session *Session) {
+	session.mu.Lock()
+	invite := session.invite
+	localTag := session.localTag
+	ip := session.answer.IP
+	session.mu.Unlock()
+	target := uri(invite.Get("Contact"))
+	if target == "" {
+		target = session.From
+	}
+	bye := &Message{Method: "BYE", RequestURI: target}
+	bye.Add("Via", fmt.Sprintf("SIP/2.0/UDP %s;branch=z9hG4bK%s;rport", net.JoinHostPort(ip.String(), fmt.Sprint(s.Addr().Port)), randomHex(8)))
+	bye.Add("Max-Forwards", "70")
+	bye.Add("From", invite.Get("To")+";tag="+localTag)
+	bye.Add("To", invite.Get("From"))
+	bye.Add("Call-ID", session.CallID)
+	bye.Add("CSeq", "1 BYE")
+	bye.Add("User-Agent", userAgent)
+	session.log.Info("Hanging up")
+	s.send(bye, session.signal)
+}
+
+// reply builds a response to req.
+func (s *Server) reply(req *Message, code int, reason string, toTag string) *Message {
+	resp := req.Response(code, reason, toTag)
+	resp.Add("Server", userAgent)
+	return resp
+}
+
+func (s *Server) send(msg *Message, to *net.UDPAddr) {
+	if _, err := s.conn.WriteToUDP(msg.Bytes(), to); err != nil {
+		s.Log.Error("SIP write error", "to", to.String(), "error", err)
+	}
+}
+
+func (s *Server) remove(callID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, callID)
+}
+
+// localIP is the address the caller can reach the bot's media on.
+func (s *Server) localIP(remote *net.UDPAddr) net.IP {
+	if s.publicIP != nil {
+		return s.publicIP
+	}
+	if ip := s.Addr().IP; ip != nil && !ip.IsUnspecified() {
+		return ip
+	}
+	// The source address the OS picks towards the caller
+	conn, err := net.DialUDP("udp", nil, remote)
+	if err != nil {
+		return net.IPv4(127, 0, 0, 1)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,351 @@
+package sip
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mrsingh-rishi/voice-bot/transport"
+)
+
+// loopTimeout bounds every wait on the loopback call.
+const loopTimeout = 5 * time.Second
+
+// dtmfPayloadType is the telephone-event payload type the caller offers.
+const dtmfPayloadType = 101
+
+// caller is the PBX side of a loopback call: a SIP socket and an RTP socket.
+type caller struct {
+	t      *testing.T
+	server *net.UDPAddr
+	sip    *net.UDPConn
+	rtp    *net.UDPConn
+	callID string
+	// toTag is the bot's dialog tag, from its answer
+	toTag string
+	// botRTP is where the bot's answer says to send RTP
+	botRTP *net.UDPAddr
+}
+
+func newCaller(t *testing.T, server *net.UDPAddr) *caller {
+	t.Helper()
+	listen := func() *net.UDPConn {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	return &caller{t: t, server: server, sip: listen(), rtp: listen(), callID: randomHex(8) + "@pbx.test"}
+}
+
+func (c *caller) request(method string, cseq int, body []byte) *Message {
+	addr := c.sip.LocalAddr().(*net.UDPAddr)
+	req := &Message{Method: method, RequestURI: fmt.Sprintf("sip:support@%s", c.server)}
+	req.Add("Via", fmt.Sprintf("SIP/2.0/UDP %s;branch=z9hG4bK%s", addr, randomHex(4)))
+	req.Add("Max-Forwards", "70")
+	req.Add("From", "<sip:+15550100@pbx.test>;tag=caller")
+	to := fmt.Sprintf("<sip:support@%s>", c.server)
+	if c.toTag != "" {
+		to += ";tag=" + c.toTag
+	}
+	req.Add("To", to)
+	req.Add("Call-ID", c.callID)
+	req.Add("CSeq", fmt.Sprintf("%d %s", cseq, method))
+	req.Add("Contact", fmt.Sprintf("<sip:pbx@%s>", addr))
+	if body != nil {
+		req.Add("Content-Type", "application/sdp")
+		req.Body = body
+	}
+	return req
+}
+
+func (c *caller) send(msg *Message) {
+	c.t.Helper()
+	if _, err := c.sip.WriteToUDP(msg.Bytes(), c.server); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// read returns the next SIP message that match accepts.
+func (c *caller) read(match func(m *Message) bool) *Message {
+	c.t.Helper()
+	buf := make([]byte, 65535)
+	c.sip.SetReadDeadline(time.Now().Add(loopTimeout))
+	for {
+		n, _, err := c.sip.ReadFromUDP(buf)
+		if err != nil {
+			c.t.Fatalf("no SIP message: %v", err)
+		}
+		msg, err := ParseMessage(buf[:n])
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		if match(msg) {
+			return msg
+		}
+	}
+}
+
+// invite calls the bot, offering PCMU and telephone events, and
+// acknowledges its answer.
+func (c *caller) invite() *Message {
+	c.t.Helper()
+	addr := c.rtp.LocalAddr().(*net.UDPAddr)
+	offer := fmt.Sprintf("v=0\r\n"+
+		"o=pbx 1 1 IN IP4 127.0.0.1\r\n"+
+		"s=call\r\n"+
+		"c=IN IP4 127.0.0.1\r\n"+
+		"t=0 0\r\n"+
+		"m=audio %d RTP/AVP 0 8 %d\r\n"+
+		"a=rtpmap:0 PCMU/8000\r\n"+
+		"a=rtpmap:8 PCMA/8000\r\n"+
+		"a=rtpmap:%d telephone-event/8000\r\n"+
+		"a=sendrecv\r\n", addr.Port, dtmfPayloadType, dtmfPayloadType)
+	c.send(c.request("INVITE", 1, []byte(offer)))
+	resp := c.read(func(m *Message) bool { return m.StatusCode >= 200 })
+	c.toTag = tag(resp.Get("To"))
+	c.send(c.request("ACK", 1, nil))
+	return resp
+}
+
+// sendRTP sends one RTP packet to the bot.
+func (c *caller) sendRTP(packet rtpPacket) {
+	c.t.Helper()
+	if _, err := c.rtp.WriteToUDP(packet.Bytes(), c.botRTP); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// readRTP returns the next packet from the bot whose payload does not
+// start with the silence byte.
+func (c *caller) readRTP(silence byte) rtpPacket {
+	c.t.Helper()
+	buf := make([]byte, 1500)
+	c.rtp.SetReadDeadline(time.Now().Add(loopTimeout))
+	for {
+		n, err := c.rtp.Read(buf)
+		if err != nil {
+			c.t.Fatalf("no RTP from the bot: %v", err)
+		}
+		packet, err := parseRTP(buf[:n])
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		if len(packet.Payload) > 0 && packet.Payload[0] != silence {
+			packet.Payload = append([]byte(nil), packet.Payload...)
+			return packet
+		}
+	}
+}
+
+// startServer runs a SIP server on the loopback interface whose calls hand
+// over their session and forward its events until the caller hangs up.
+func startServer(t *testing.T, onCall func(s *Session)) *Server {
+	t.Helper()
+	server, err := NewServer("127.0.0.1:0", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.OnCall = onCall
+	served := make(chan error, 1)
+	go func() { served <- server.Serve() }()
+	t.Cleanup(func() {
+		server.Close()
+		<-served
+	})
+	return server
+}
+
+// nextEvent returns the next event of type typ, skipping the others.
+func nextEvent(t *testing.T, events <-chan transport.Event, typ transport.EventType) transport.Event {
+	t.Helper()
+	deadline := time.After(loopTimeout)
+	for {
+		select {
+		case ev := <-events:
+			if ev.Type == typ {
+				return ev
+			}
+		case <-deadline:
+			t.Fatalf("no %s event", typ)
+		}
+	}
+}
+
+// telephoneEventPayload is an RFC 2833 payload for key, at volume 10.
+func telephoneEventPayload(key byte, end bool, duration uint16) []byte {
+	flags := byte(10)
+	if end {
+		flags |= 0x80
+	}
+	return []byte{key, flags, byte(duration >> 8), byte(duration)}
+}
+
+func TestLoopbackCall(t *testing.T) {
+	sessions := make(chan *Session, 1)
+	events := make(chan transport.Event, 64)
+	server := startServer(t, func(s *Session) {
+		sessions <- s
+		for {
+			ev, err := s.Receive()
+			if err != nil {
+				return
+			}
+			events <- ev
+			if ev.Type == transport.EventStop {
+				return
+			}
+		}
+	})
+	c := newCaller(t, server.Addr())
+
+	// INVITE is answered with PCMU, the caller's first choice, and its
+	// telephone events
+	resp := c.invite()
+	if resp.StatusCode != 200 {
+		t.Fatalf("INVITE answered %d %s", resp.StatusCode, resp.Reason)
+	}
+	if tag(resp.Get("To")) == "" {
+		t.Error("200 OK has no To tag")
+	}
+	if got := resp.Get("Content-Type"); got != "application/sdp" {
+		t.Errorf("200 OK Content-Type = %q", got)
+	}
+	answer, err := ParseOffer(resp.Body)
+	if err != nil {
+		t.Fatalf("invalid SDP answer: %v\n%s", err, resp.Body)
+	}
+	if len(answer.Payloads) != 2 || answer.Payloads[0] != payloadPCMU || answer.Payloads[1] != dtmfPayloadType {
+		t.Errorf("answer payloads = %v, want [0 %d]", answer.Payloads, dtmfPayloadType)
+	}
+	if answer.TelephoneEvent() != dtmfPayloadType {
+		t.Error("answer drops telephone events")
+	}
+	if answer.Direction != SendRecv || answer.Addr == nil {
+		t.Fatalf("answer direction %s, address %v", answer.Direction, answer.Addr)
+	}
+	c.botRTP = answer.Addr
+
+	var session *Session
+	select {
+	case session = <-sessions:
+	case <-time.After(loopTimeout):
+		t.Fatal("OnCall never ran")
+	}
+	if session.Agent != "support" || session.From != "sip:+15550100@pbx.test" {
+		t.Errorf("session agent %q, from %q", session.Agent, session.From)
+	}
+	if start := nextEvent(t, events, transport.EventStart); start.Start.CallSid != c.callID {
+		t.Errorf("start call SID = %q, want %q", start.Start.CallSid, c.callID)
+	}
+
+	// RTP in: a repeated sequence number is dropped
+	frame := func(b byte) []byte { return bytes.Repeat([]byte{b}, 160) }
+	c.sendRTP(rtpPacket{PayloadType: payloadPCMU, Sequence: 10, Timestamp: 160, SSRC: 1, Payload: frame(0x12)})
+	c.sendRTP(rtpPacket{PayloadType: payloadPCMU, Sequence: 10, Timestamp: 160, SSRC: 1, Payload: frame(0x99)})
+	c.sendRTP(rtpPacket{PayloadType: payloadPCMU, Sequence: 11, Timestamp: 320, SSRC: 1, Payload: frame(0x34)})
+	for _, want := range []byte{0x12, 0x34} {
+		if got := nextEvent(t, events, transport.EventAudio).Audio; !bytes.Equal(got, frame(want)) {
+			t.Errorf("audio event starts with %#x, want %#x", got[0], want)
+		}
+	}
+
+	// RFC 2833: a key press is a run of packets sharing a timestamp, the
+	// end repeated; each press is one event
+	for _, key := range []struct {
+		code      byte
+		timestamp uint32
+	}{{5, 1000}, {11, 2000}} {
+		c.sendRTP(rtpPacket{PayloadType: dtmfPayloadType, Sequence: 20, Timestamp: key.timestamp, SSRC: 1, Payload: telephoneEventPayload(key.code, false, 160)})
+		for i := 0; i < 3; i++ {
+			c.sendRTP(rtpPacket{PayloadType: dtmfPayloadType, Sequence: uint16(21 + i), Timestamp: key.timestamp, SSRC: 1, Payload: telephoneEventPayload(key.code, true, 800)})
+		}
+	}
+	for _, want := range []string{"5", "#"} {
+		if got := nextEvent(t, events, transport.EventDTMF).Digit; got != want {
+			t.Errorf("DTMF event %q, want %q", got, want)
+		}
+	}
+
+	// RTP out: the agent's audio is sent in 20 ms frames, then its mark is
+	// acked
+	silence := session.silence[0]
+	if err := session.SendAudio(bytes.Repeat([]byte{0x2a}, 320)); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Mark("utterance-1"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		packet := c.readRTP(silence)
+		if packet.PayloadType != payloadPCMU || !bytes.Equal(packet.Payload, frame(0x2a)) {
+			t.Errorf("RTP frame %d: payload type %d, %d bytes starting with %#x", i+1, packet.PayloadType, len(packet.Payload), packet.Payload[0])
+		}
+	}
+	if mark := nextEvent(t, events, transport.EventMark); mark.Mark != "utterance-1" {
+		t.Errorf("mark %q, want utterance-1", mark.Mark)
+	}
+
+	// BYE is answered, stops the call and is not sent back
+	c.send(c.request("BYE", 2, nil))
+	ok := c.read(func(m *Message) bool { _, method := m.CSeq(); return method == "BYE" })
+	if ok.StatusCode != 200 {
+		t.Errorf("BYE answered %d %s", ok.StatusCode, ok.Reason)
+	}
+	nextEvent(t, events, transport.EventStop)
+	deadline := time.Now().Add(loopTimeout)
+	for {
+		server.mu.Lock()
+		left := len(server.sessions)
+		server.mu.Unlock()
+		if left == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("session still open after BYE")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	buf := make([]byte, 65535)
+	c.sip.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if n, _, err := c.sip.ReadFromUDP(buf); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("bot sent %q after the caller hung up", buf[:n])
+	}
+}
+
+func TestBotHangsUp(t *testing.T) {
+	server := startServer(t, func(s *Session) {})
+	c := newCaller(t, server.Addr())
+	resp := c.invite()
+	if resp.StatusCode != 200 {
+		t.Fatalf("INVITE answered %d %s", resp.StatusCode, resp.Reason)
+	}
+	bye := c.read(func(m *Message) bool { return m.Method == "BYE" })
+	if bye.Get("Call-ID") != c.callID {
+		t.Errorf("BYE for call %q, want %q", bye.Get("Call-ID"), c.callID)
+	}
+	if bye.RequestURI != uri(c.request("INVITE", 1, nil).Get("Contact")) {
+		t.Errorf("BYE sent to %q instead of the caller's contact", bye.RequestURI)
+	}
+	if tag(bye.Get("From")) != tag(resp.Get("To")) {
+		t.Errorf("BYE From %q does not carry the bot's tag from %q", bye.Get("From"), resp.Get("To"))
+	}
+}
+
+func TestRejectsOfferWithoutG711(t *testing.T) {
+	server := startServer(t, func(s *Session) {
+		t.Error("call without G.711 was answered")
+	})
+	c := newCaller(t, server.Addr())
+	offer := "v=0\r\nc=IN IP4 127.0.0.1\r\nt=0 0\r\nm=audio 4000 RTP/AVP 9\r\na=rtpmap:9 G722/8000\r\n"
+	c.send(c.request("INVITE", 1, []byte(offer)))
+	if resp := c.read(func(m *Message) bool { return m.StatusCode >= 200 }); resp.StatusCode != 488 {
+		t.Errorf("INVITE answered %d %s, want 488", resp.StatusCode, resp.Reason)
+	}
+}
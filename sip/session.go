@@ -0,0 +1,312 @@
+package sip
+
+import (
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mrsingh-rishi/voice-bot/audio"
+	"github.com/mrsingh-rishi/voice-bot/metrics"
+	"github.com/mrsingh-rishi/voice-bot/transport"
+)
+
+// outbound is a frame of agent audio or a mark waiting to be sent.
+type outbound struct {
+	frame []byte
+	mark  string
+}
+
+// Session is one answered call. It is the call's transport.Transport: RTP
+// in and out, with the agent's audio paced out in real time and marks acked
+// once the audio queued before them has been sent.
+type Session struct {
+	CallID string
+	// Agent is the user part of the INVITE's request URI, e.g. "support"
+	// for sip:support@bot.example.com
+	Agent string
+	// From is the caller's URI
+	From string
+
+	server      *Server
+	signal      *net.UDPAddr // where the caller's SIP messages come from
+	rtp         *net.UDPConn
+	format      audio.Format
+	payload     int
+	dtmfPayload int
+	silence     []byte
+
+	events    chan transport.Event
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu sync.Mutex
+	// remote is where RTP goes; it follows the caller's packets
+	remote *net.UDPAddr
+	held   bool
+	queue  []outbound
+	framer *audio.Framer
+	// hungUp is set when the caller sent BYE, so no BYE is sent back
+	hungUp bool
+	// invite is the request that set up the dialog; inviteCSeq and
+	// lastResponse answer its retransmissions
+	invite       *Message
+	inviteCSeq   int
+	lastResponse *Message
+	localTag     string
+	answer       Answer
+	acked        chan struct{}
+
+	log *slog.Logger
+}
+
+func newSession(server *Server, invite *Message, signal *net.UDPAddr, rtp *net.UDPConn, offer *Offer) *Session {
+	format, payload, _ := offer.Codec()
+	s := &Session{
+		CallID:      invite.Get("Call-ID"),
+		Agent:       user(invite.RequestURI),
+		From:        uri(invite.Get("From")),
+		server:      server,
+		signal:      signal,
+		rtp:         rtp,
+		format:      format,
+		payload:     payload,
+		dtmfPayload: offer.TelephoneEvent(),
+		silence:     format.Encode(make([]int16, format.FrameBytes(audio.FrameDuration))),
+		events:      make(chan transport.Event, 64),
+		done:        make(chan struct{}),
+		remote:      offer.Addr,
+		held:        offer.Held(),
+		framer:      audio.NewFramer(format.FrameBytes(audio.FrameDuration)),
+		invite:      invite,
+		localTag:    randomHex(8),
+	}
+	s.log = server.Log.With(slog.Group("call", "sid", s.CallID))
+	s.inviteCSeq, _ = invite.CSeq()
+	s.events <- transport.Event{Type: transport.EventStart, Start: transport.Start{CallSid: s.CallID, StreamID: s.CallID}}
+	return s
+}
+
+func (s *Session) Format() audio.Format {
+	return s.format
+}
+
+func (s *Session) Receive() (transport.Event, error) {
+	select {
+	case ev := <-s.events:
+		return ev, nil
+	case <-s.done:
+		return transport.Event{}, io.EOF
+	}
+}
+
+func (s *Session) SendAudio(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, frame := range s.framer.Push(data) {
+		s.queue = append(s.queue, outbound{frame: frame})
+	}
+	return nil
+}
+
+// Clear drops the queued audio; its marks are acked, as Twilio does.
+func (s *Session) Clear() error {
+	s.mu.Lock()
+	s.framer.Flush()
+	var marks []string
+	for _, item := range s.queue {
+		if item.frame == nil {
+			marks = append(marks, item.mark)
+		}
+	}
+	s.queue = nil
+	s.mu.Unlock()
+	for _, name := range marks {
+		s.emit(transport.Event{Type: transport.EventMark, Mark: name})
+	}
+	return nil
+}
+
+func (s *Session) Mark(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rest := s.framer.Flush(); len(rest) > 0 {
+		frame := append([]byte(nil), s.silence...)
+		copy(frame, rest)
+		s.queue = append(s.queue, outbound{frame: frame})
+	}
+	s.queue = append(s.queue, outbound{mark: name})
+	return nil
+}
+
+// Close ends the call, with a BYE unless the caller hung up.
+func (s *Session) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.mu.Lock()
+		hungUp := s.hungUp
+		s.mu.Unlock()
+		if !hungUp {
+			s.server.sendBye(s)
+		}
+		s.rtp.Close()
+		s.server.remove(s.CallID)
+	})
+	return nil
+}
+
+func (s *Session) emit(ev transport.Event) {
+	select {
+	case s.events <- ev:
+	case <-s.done:
+	}
+}
+
+// hangUp handles the caller's BYE.
+func (s *Session) hangUp() {
+	s.mu.Lock()
+	s.hungUp = true
+	s.mu.Unlock()
+	s.emit(transport.Event{Type: transport.EventStop})
+}
+
+// ack stops the retransmission of the last 200 OK.
+func (s *Session) ack() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.acked != nil {
+		close(s.acked)
+		s.acked = nil
+	}
+}
+
+// update applies a re-INVITE: a new RTP address or a hold.
+func (s *Session) update(offer *Offer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if offer.Addr != nil {
+		s.remote = offer.Addr
+	}
+	if held := offer.Held(); held != s.held {
+		s.held = held
+		if held {
+			s.log.Info("Call put on hold")
+		} else {
+			s.log.Info("Call resumed")
+		}
+	}
+}
+
+// direction is what the bot answers with: it stops sending while held.
+func (s *Session) direction() Direction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.held {
+		return RecvOnly
+	}
+	return SendRecv
+}
+
+// sendLoop sends one 20 ms frame per tick: queued agent audio, or silence
+// so the PBX does not take the call for dead. Nothing is sent on hold.
+func (s *Session) sendLoop() {
+	ticker := time.NewTicker(audio.FrameDuration)
+	defer ticker.Stop()
+	packet := rtpPacket{
+		Marker:      true,
+		PayloadType: s.payload,
+		Sequence:    uint16(rand.Intn(1 << 16)),
+		Timestamp:   rand.Uint32(),
+		SSRC:        rand.Uint32(),
+	}
+	samplesPerFrame := uint32(s.format.SampleRate) * uint32(audio.FrameDuration/time.Millisecond) / 1000
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+		packet.Timestamp += samplesPerFrame
+		s.mu.Lock()
+		if s.held || s.remote == nil {
+			s.mu.Unlock()
+			packet.Marker = true
+			continue
+		}
+		var marks []string
+		frame := s.silence
+		for len(s.queue) > 0 {
+			item := s.queue[0]
+			s.queue = s.queue[1:]
+			if item.frame == nil {
+				marks = append(marks, item.mark)
+				continue
+			}
+			frame = item.frame
+			break
+		}
+		remote := s.remote
+		s.mu.Unlock()
+
+		for _, name := range marks {
+			s.emit(transport.Event{Type: transport.EventMark, Mark: name})
+		}
+		packet.Sequence++
+		packet.Payload = frame
+		if _, err := s.rtp.WriteToUDP(packet.Bytes(), remote); err != nil {
+			s.log.Error("RTP write error", "error", err)
+		}
+		packet.Marker = false
+	}
+}
+
+// readLoop turns the caller's RTP into audio and key events.
+func (s *Session) readLoop() {
+	buf := make([]byte, 1500)
+	var lastSeq uint16
+	started := false
+	var lastKeyTimestamp uint32
+	keySeen := false
+	for {
+		n, from, err := s.rtp.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		packet, err := parseRTP(buf[:n])
+		if err != nil {
+			continue
+		}
+		s.mu.Lock()
+		// Symmetric RTP: answer where the caller's packets come from, which
+		// gets through NAT when the SDP address does not
+		if s.remote == nil || !s.remote.IP.Equal(from.IP) || s.remote.Port != from.Port {
+			s.remote = from
+		}
+		held := s.held
+		s.mu.Unlock()
+
+		switch {
+		case packet.PayloadType == s.payload:
+			// Late or duplicated packets are dropped; there is no jitter buffer
+			if started && int16(packet.Sequence-lastSeq) <= 0 {
+				metrics.DroppedAudioChunks.WithLabelValues(metrics.DropOutOfOrder).Inc()
+				continue
+			}
+			started, lastSeq = true, packet.Sequence
+			if held {
+				continue
+			}
+			s.emit(transport.Event{Type: transport.EventAudio, Audio: append([]byte(nil), packet.Payload...)})
+		case packet.PayloadType == s.dtmfPayload:
+			ev, ok := parseTelephoneEvent(packet.Payload)
+			// The end of a key press is repeated; its timestamp identifies it
+			if !ok || !ev.End || (keySeen && packet.Timestamp == lastKeyTimestamp) {
+				continue
+			}
+			keySeen, lastKeyTimestamp = true, packet.Timestamp
+			s.emit(transport.Event{Type: transport.EventDTMF, Digit: ev.Key})
+		}
+	}
+}
@@ -4,30 +4,97 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"sync"
+	"time"
 
 	gws "github.com/gorilla/websocket"
+	"github.com/mrsingh-rishi/voice-bot/audio"
+	"github.com/mrsingh-rishi/voice-bot/fallback"
+	"github.com/mrsingh-rishi/voice-bot/logging"
+	"github.com/mrsingh-rishi/voice-bot/metrics"
+	"github.com/mrsingh-rishi/voice-bot/tracing"
 )
 
+const (
+	// keepAliveInterval is how long the socket may go without audio before a
+	// KeepAlive is sent; Deepgram closes streams after about 10 s of nothing
+	keepAliveInterval    = 5 * time.Second
+	reconnectMinBackoff  = 250 * time.Millisecond
+	reconnectMaxBackoff  = 8 * time.Second
+	maxReconnectAttempts = 6
+	// maxBufferedAudio is how much audio is held for replay while reconnecting
+	maxBufferedAudio = 10 * time.Second
+	// closeStreamTimeout bounds the wait for final results on shutdown
+	closeStreamTimeout = 2 * time.Second
+	// dialTimeout bounds each connection attempt, so a hung endpoint falls
+	// through to the next one
+	dialTimeout = 5 * time.Second
+)
+
+// Health is the state of the Deepgram connection.
+type Health int
+
+const (
+	HealthConnected Health = iota
+	// HealthReconnecting means the socket dropped; audio is buffered for replay
+	HealthReconnecting
+	// HealthFailed means every reconnect attempt failed and the call is deaf
+	HealthFailed
+)
+
+func (h Health) String() string {
+	switch h {
+	case HealthConnected:
+		return "connected"
+	case HealthReconnecting:
+		return "reconnecting"
+	case HealthFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
 type DeepgramClient struct {
-	ctx        context.Context
-	Cancel     context.CancelFunc
-	Connection *gws.Conn
-	APIKey     string
-	Endpoint   string
-	// TranscriptionChannel chan TranscriptionChannel
-	TranscriptionChannel chan string
+	ctx                   context.Context
+	Cancel                context.CancelFunc
+	Connection            *gws.Conn
+	APIKey                string
+	Endpoint              string
+	TranscriptionChannel  chan Transcript
 	TranscriptionChannel2 chan string
-	closeOnce sync.Once
-    writeMu   sync.Mutex
+	// InputFormat is what Deepgram is told to expect; telephony audio is
+	// transcoded into it when it differs
+	InputFormat audio.Format
+	transcoder  *audio.Transcoder
+	// endpoints are tried in order on every (re)connect; breakers skip the
+	// ones that keep failing
+	endpoints []deepgramEndpoint
+	breakers  *fallback.Registry
+	// OnHealthChange, if set, is called whenever the connection drops,
+	// recovers or gives up
+	OnHealthChange func(Health)
+	Log            *slog.Logger
+	closeOnce      sync.Once
+	// writeMu guards Connection, the fields below and every write to the socket
+	writeMu       sync.Mutex
+	connected     bool
+	closing       bool
+	readDone      chan struct{} // closed when the current connection's read loop exits
+	buffer        [][]byte      // audio waiting for a reconnect
+	bufferedBytes int
+	lastWrite     time.Time
 }
 
-type TranscriptionChannel struct {
-	Transcription string
-	Confidence    float64
-	Final         bool
+// Transcript is one recognized utterance.
+type Transcript struct {
+	Text       string
+	Confidence float64
+	Final      bool
+	// Language is the detected language (e.g. "en", "es"); empty when unknown
+	Language string
 }
 
 type TranscriptionMessage struct {
@@ -36,6 +103,8 @@ type TranscriptionMessage struct {
 		Alternatives []struct {
 			Transcript string  `json:"transcript"`
 			Confidence float64 `json:"confidence"`
+			// Languages lists the languages detected in the utterance, dominant first
+			Languages []string `json:"languages"`
 		} `json:"alternatives"`
 	} `json:"channel"`
 }
@@ -50,104 +119,345 @@ type DeepgramResponse struct {
 	} `json:"channel"`
 }
 
-// for now we will use default deepgram config
-func NewDeepgramClient(apikey string, transcriptionChannel chan string, transcriptionChannel2 chan string) (*DeepgramClient, error) {
-	dgURL := "wss://api.deepgram.com/v1/listen?model=nova-3&encoding=mulaw&sample_rate=8000&channels=1&language=multi&punctuate=true&smart_format=true&vad_events=true"
+// NewDeepgramClient connects with the first of options that works; the rest
+// are fallbacks, in order, used whenever the client has to (re)connect.
+// Connections are traced under the span in ctx; the client runs until
+// Close even if ctx is cancelled.
+func NewDeepgramClient(ctx context.Context, apikey string, options []DeepgramOptions, breakers *fallback.Registry, inputFormat audio.Format, transcriptionChannel chan Transcript, transcriptionChannel2 chan string) (*DeepgramClient, error) {
+	if len(options) == 0 {
+		options = []DeepgramOptions{DefaultDeepgramOptions()}
+	}
+	endpoints := make([]deepgramEndpoint, 0, len(options))
+	for _, o := range options {
+		dgURL, err := o.URL(inputFormat)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, deepgramEndpoint{name: o.name(), url: dgURL})
+	}
 
-	header := http.Header{
-		"Authorization": {fmt.Sprintf("Token %s", apikey)},
+	dg := &DeepgramClient{
+		APIKey:                apikey,
+		TranscriptionChannel:  transcriptionChannel,
+		TranscriptionChannel2: transcriptionChannel2,
+		InputFormat:           inputFormat,
+		transcoder:            audio.NewTranscoder(audio.Telephony, inputFormat),
+		endpoints:             endpoints,
+		breakers:              breakers,
+		connected:             true,
+		lastWrite:             time.Now(),
+		Log:                   logging.Logger("stt"),
 	}
-	dgConn, _, err := gws.DefaultDialer.Dial(dgURL, header)
+	dg.ctx, dg.Cancel = context.WithCancel(context.WithoutCancel(ctx))
+	dgConn, dgURL, err := dg.dial()
 	if err != nil {
-		log.Printf("❌ Deepgram dial error: %v", err)
+		dg.Log.Error("Deepgram dial error", "error", err)
+		dg.Cancel()
 		return nil, err
 	}
-	ctx, cancel := context.WithCancel(context.Background())
-	log.Printf("✅ Connected to Deepgram")
-	return &DeepgramClient{
-		ctx:                  ctx,
-		Cancel:               cancel,
-		Connection:           dgConn,
-		APIKey:               apikey,
-		Endpoint:             dgURL,
-		TranscriptionChannel: transcriptionChannel,
-		TranscriptionChannel2: transcriptionChannel2,
-		// TranscriptionChannel: make(chan TranscriptionChannel),
-	}, nil
+	dg.Connection = dgConn
+	dg.Endpoint = dgURL
+	dg.Log.Info("Connected to Deepgram")
+	return dg, nil
 }
 
+// deepgramEndpoint is one entry of the STT fallback chain.
+type deepgramEndpoint struct {
+	name string
+	url  string
+}
+
+// dial connects to the first endpoint whose breaker allows it, in order,
+// so the primary is preferred again as soon as it recovers.
+func (dg *DeepgramClient) dial() (*gws.Conn, string, error) {
+	header := http.Header{
+		"Authorization": {fmt.Sprintf("Token %s", dg.APIKey)},
+	}
+	dialer := *gws.DefaultDialer
+	dialer.HandshakeTimeout = dialTimeout
+	var lastErr error = fallback.ErrAllOpen
+	for _, endpoint := range dg.endpoints {
+		breaker := dg.breakers.Get("stt:" + endpoint.name)
+		if !breaker.Allow() {
+			continue
+		}
+		_, span := tracing.Start(dg.ctx, "stt.connect", tracing.Provider.String("stt:"+endpoint.name))
+		conn, _, err := dialer.Dial(endpoint.url, header)
+		tracing.Fail(span, err)
+		span.End()
+		if err != nil {
+			dg.Log.Warn("Deepgram endpoint failed", "endpoint", endpoint.name, "error", err)
+			breaker.Failure(err)
+			lastErr = err
+			continue
+		}
+		breaker.Success()
+		return conn, endpoint.url, nil
+	}
+	return nil, "", lastErr
+}
+
+// deepgramEncoding maps an audio format to Deepgram's encoding parameter.
+func deepgramEncoding(format audio.Format) (string, error) {
+	switch format.Codec {
+	case audio.CodecMuLaw:
+		return "mulaw", nil
+	case audio.CodecALaw:
+		return "alaw", nil
+	case audio.CodecPCM16:
+		return "linear16", nil
+	}
+	return "", fmt.Errorf("unsupported Deepgram input format %s", format)
+}
+
+// SendAudio streams audio from audioChannel to Deepgram and delivers
+// transcripts until Close. A dropped socket is redialed with backoff; audio
+// that arrives meanwhile is buffered and replayed.
 func (dg *DeepgramClient) SendAudio(audioChannel <-chan []byte) {
-	go func() {
-		for {
-			select {
-			case <-dg.ctx.Done():
+	dg.writeMu.Lock()
+	conn := dg.Connection
+	dg.readDone = make(chan struct{})
+	readDone := dg.readDone
+	dg.writeMu.Unlock()
+
+	go dg.readLoop(conn, readDone)
+	go dg.writeLoop(audioChannel)
+}
+
+func (dg *DeepgramClient) writeLoop(audioChannel <-chan []byte) {
+	ticker := time.NewTicker(keepAliveInterval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-dg.ctx.Done():
+			return
+		case audio, ok := <-audioChannel:
+			if !ok {
 				return
-			case audio := <-audioChannel:
-				if len(audio) == 0 {
-					continue
-				}
-				if dg.Connection == nil {
-					log.Println("❌ Deepgram connection is nil")
-					return
-				}
-				if err := dg.Connection.WriteMessage(gws.BinaryMessage, audio); err != nil {
-					log.Printf("❌ Deepgram write error: %v", err)
-					return
-				}
 			}
+			if len(audio) == 0 {
+				continue
+			}
+			dg.send(dg.transcoder.Process(audio))
+		case <-ticker.C:
+			dg.keepAlive()
 		}
-	}()
+	}
+}
 
-	go func() {
-		for {
-			select {
-			case <-dg.ctx.Done():
-				return
-			default:
-				_, message, err := dg.Connection.ReadMessage()
-				if err != nil {
-					log.Printf("Error reading response from Deepgram: %v\n", err)
-					continue
-				}
-
-				// Try to parse as array first
-				var arrayResp []TranscriptionMessage
-				if err := json.Unmarshal(message, &arrayResp); err == nil {
-					for _, resp := range arrayResp {
-						dg.processTranscription(resp)
-					}
-					continue
-				}
-
-				// If array parsing fails, try as single object
-				var singleResp TranscriptionMessage
-				if err := json.Unmarshal(message, &singleResp); err != nil {
-					log.Printf("Error parsing Deepgram response: %v\n", err)
-					continue
-				}
-				dg.processTranscription(singleResp)
+// send writes one chunk, or buffers it while the socket is down.
+func (dg *DeepgramClient) send(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	dg.writeMu.Lock()
+	defer dg.writeMu.Unlock()
+	if !dg.connected {
+		dg.bufferLocked(data)
+		return
+	}
+	if err := dg.Connection.WriteMessage(gws.BinaryMessage, data); err != nil {
+		dg.Log.Error("Deepgram write error", "error", err)
+		dg.bufferLocked(data)
+		dg.dropLocked(dg.Connection)
+		return
+	}
+	dg.lastWrite = time.Now()
+}
+
+// keepAlive stops Deepgram from closing the stream while no audio flows,
+// e.g. while the caller is on hold.
+func (dg *DeepgramClient) keepAlive() {
+	dg.writeMu.Lock()
+	defer dg.writeMu.Unlock()
+	if !dg.connected || time.Since(dg.lastWrite) < keepAliveInterval {
+		return
+	}
+	if err := dg.Connection.WriteMessage(gws.TextMessage, []byte(`{"type":"KeepAlive"}`)); err != nil {
+		dg.Log.Error("Deepgram keepalive error", "error", err)
+		dg.dropLocked(dg.Connection)
+		return
+	}
+	dg.lastWrite = time.Now()
+}
+
+// bufferLocked holds audio for replay, dropping the oldest beyond maxBufferedAudio.
+func (dg *DeepgramClient) bufferLocked(data []byte) {
+	limit := dg.InputFormat.FrameBytes(maxBufferedAudio)
+	dg.buffer = append(dg.buffer, data)
+	dg.bufferedBytes += len(data)
+	for dg.bufferedBytes > limit && len(dg.buffer) > 1 {
+		dg.bufferedBytes -= len(dg.buffer[0])
+		dg.buffer = dg.buffer[1:]
+		metrics.DroppedAudioChunks.WithLabelValues(metrics.DropSTTBuffer).Inc()
+	}
+}
+
+// dropLocked marks conn dead and starts reconnecting, unless that already
+// happened or the client is shutting down. Callers hold writeMu.
+func (dg *DeepgramClient) dropLocked(conn *gws.Conn) {
+	if dg.Connection != conn || !dg.connected || dg.closing {
+		return
+	}
+	dg.connected = false
+	conn.Close()
+	go dg.reconnect()
+}
+
+// reconnect redials with exponential backoff and replays buffered audio.
+func (dg *DeepgramClient) reconnect() {
+	dg.setHealth(HealthReconnecting)
+	backoff := reconnectMinBackoff
+	for attempt := 1; attempt <= maxReconnectAttempts; attempt++ {
+		select {
+		case <-dg.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, reconnectMaxBackoff)
+
+		conn, dgURL, err := dg.dial()
+		if err != nil {
+			dg.Log.Warn("Deepgram reconnect attempt failed", "attempt", attempt, "max_attempts", maxReconnectAttempts, "error", err)
+			continue
+		}
+		dg.writeMu.Lock()
+		if dg.closing {
+			dg.writeMu.Unlock()
+			conn.Close()
+			return
+		}
+		replayed := dg.replayLocked(conn)
+		if replayed != nil {
+			dg.writeMu.Unlock()
+			conn.Close()
+			dg.Log.Warn("Deepgram replay failed", "error", replayed)
+			continue
+		}
+		dg.Connection = conn
+		dg.Endpoint = dgURL
+		dg.connected = true
+		dg.lastWrite = time.Now()
+		dg.readDone = make(chan struct{})
+		readDone := dg.readDone
+		dg.writeMu.Unlock()
+
+		go dg.readLoop(conn, readDone)
+		dg.Log.Info("Reconnected to Deepgram", "attempts", attempt)
+		dg.setHealth(HealthConnected)
+		return
+	}
+	dg.setHealth(HealthFailed)
+}
+
+// replayLocked sends the buffered audio on a fresh connection. The buffer is
+// kept if the write fails so the next attempt can replay it. Callers hold writeMu.
+func (dg *DeepgramClient) replayLocked(conn *gws.Conn) error {
+	for len(dg.buffer) > 0 {
+		if err := conn.WriteMessage(gws.BinaryMessage, dg.buffer[0]); err != nil {
+			return err
+		}
+		dg.bufferedBytes -= len(dg.buffer[0])
+		dg.buffer = dg.buffer[1:]
+	}
+	return nil
+}
+
+func (dg *DeepgramClient) setHealth(health Health) {
+	dg.Log.Info("Deepgram connection health changed", "health", health.String())
+	if dg.OnHealthChange != nil {
+		dg.OnHealthChange(health)
+	}
+}
+
+// readLoop delivers transcripts from one connection until it closes.
+func (dg *DeepgramClient) readLoop(conn *gws.Conn, done chan struct{}) {
+	defer close(done)
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			dg.writeMu.Lock()
+			closing := dg.closing
+			if !closing {
+				dg.Log.Warn("Error reading response from Deepgram", "error", err)
+				dg.dropLocked(conn)
 			}
+			dg.writeMu.Unlock()
+			return
 		}
-	}()
+
+		// Try to parse as array first
+		var arrayResp []TranscriptionMessage
+		if err := json.Unmarshal(message, &arrayResp); err == nil {
+			for _, resp := range arrayResp {
+				dg.processTranscription(resp)
+			}
+			continue
+		}
+
+		// If array parsing fails, try as single object
+		var singleResp TranscriptionMessage
+		if err := json.Unmarshal(message, &singleResp); err != nil {
+			dg.Log.Warn("Error parsing Deepgram response", "error", err)
+			continue
+		}
+		dg.processTranscription(singleResp)
+	}
 }
 
 func (dg *DeepgramClient) processTranscription(resp TranscriptionMessage) {
 	if len(resp.Channel.Alternatives) > 0 {
-		text := resp.Channel.Alternatives[0].Transcript
+		alternative := resp.Channel.Alternatives[0]
+		text := alternative.Transcript
 		if text != "" && resp.IsFinal {
+			transcript := Transcript{
+				Text:       text,
+				Confidence: alternative.Confidence,
+				Final:      true,
+			}
+			if len(alternative.Languages) > 0 {
+				transcript.Language = alternative.Languages[0]
+			}
 			select {
+			case dg.TranscriptionChannel <- transcript:
 			case <-dg.ctx.Done():
 				return
-			default:
-				dg.TranscriptionChannel <- text
-				dg.TranscriptionChannel2 <- text
+			}
+			select {
+			case dg.TranscriptionChannel2 <- text:
+			case <-dg.ctx.Done():
 			}
 		}
 	}
 }
 
-// Close closes the Deepgram WebSocket connection
+// Close asks Deepgram to flush its final results with CloseStream, waits
+// briefly for them, and tears down the socket.
 func (dg *DeepgramClient) Close() error {
-	dg.Cancel()           // signal goroutines to stop
-    return dg.Connection.Close()  // immedately tear down socket
+	var err error
+	dg.closeOnce.Do(func() {
+		dg.writeMu.Lock()
+		dg.closing = true
+		conn := dg.Connection
+		readDone := dg.readDone
+		if dg.connected {
+			conn.WriteMessage(gws.TextMessage, []byte(`{"type":"CloseStream"}`))
+		}
+		dg.writeMu.Unlock()
+
+		if readDone != nil {
+			select {
+			case <-readDone:
+			case <-time.After(closeStreamTimeout):
+			}
+		}
+		dg.Cancel() // signal goroutines to stop
+		err = conn.Close()
+		// Once the read loop is gone nothing sends on the transcription
+		// channels any more
+		if readDone != nil {
+			<-readDone
+		}
+	})
+	return err
 }
@@ -0,0 +1,157 @@
+package stt
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mrsingh-rishi/voice-bot/audio"
+)
+
+const DefaultDeepgramEndpoint = "wss://api.deepgram.com/v1/listen"
+
+// DeepgramOptions are the parameters of a Deepgram streaming session. Zero
+// values keep Deepgram's defaults, except Model and Language, which default
+// to what the bot has always used.
+type DeepgramOptions struct {
+	// Endpoint overrides the listen URL, e.g. for a self-hosted or EU deployment
+	Endpoint string `json:"endpoint"`
+	Model    string `json:"model"`
+	Language string `json:"language"`
+	// EndpointingMs is how much silence ends an utterance; negative turns endpointing off
+	EndpointingMs int `json:"endpointing_ms"`
+	// UtteranceEndMs emits an UtteranceEnd event after this gap between words; needs InterimResults
+	UtteranceEndMs int  `json:"utterance_end_ms"`
+	InterimResults bool `json:"interim_results"`
+	// Keywords boosts words on older models, as "word" or "word:intensifier"
+	Keywords []string `json:"keywords"`
+	// Keyterms prompts nova-3 models with terms such as product and customer names
+	Keyterms        []string `json:"keyterms"`
+	Diarize         bool     `json:"diarize"`
+	Numerals        bool     `json:"numerals"`
+	ProfanityFilter bool     `json:"profanity_filter"`
+	// Redact removes sensitive spans from transcripts, e.g. "pci", "ssn", "numbers"
+	Redact []string `json:"redact"`
+}
+
+// DefaultDeepgramOptions are the options the bot used before they were configurable.
+func DefaultDeepgramOptions() DeepgramOptions {
+	return DeepgramOptions{
+		Endpoint: DefaultDeepgramEndpoint,
+		Model:    "nova-3",
+		Language: "multi",
+	}
+}
+
+func (o DeepgramOptions) withDefaults() DeepgramOptions {
+	d := DefaultDeepgramOptions()
+	if o.Endpoint == "" {
+		o.Endpoint = d.Endpoint
+	}
+	if o.Model == "" {
+		o.Model = d.Model
+	}
+	if o.Language == "" {
+		o.Language = d.Language
+	}
+	return o
+}
+
+// name identifies the endpoint and model for circuit breaking.
+func (o DeepgramOptions) name() string {
+	o = o.withDefaults()
+	host := o.Endpoint
+	if u, err := url.Parse(o.Endpoint); err == nil {
+		host = u.Host
+	}
+	return host + "/" + o.Model
+}
+
+// URL builds the listen URL for audio in the given format.
+func (o DeepgramOptions) URL(format audio.Format) (string, error) {
+	o = o.withDefaults()
+	encoding, err := deepgramEncoding(format)
+	if err != nil {
+		return "", err
+	}
+	if o.UtteranceEndMs > 0 && !o.InterimResults {
+		return "", fmt.Errorf("utterance_end_ms requires interim_results")
+	}
+	base, err := url.Parse(o.Endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid Deepgram endpoint %q: %w", o.Endpoint, err)
+	}
+
+	q := base.Query()
+	q.Set("model", o.Model)
+	q.Set("language", o.Language)
+	q.Set("encoding", encoding)
+	q.Set("sample_rate", strconv.Itoa(format.SampleRate))
+	q.Set("channels", "1")
+	q.Set("punctuate", "true")
+	q.Set("smart_format", "true")
+	q.Set("vad_events", "true")
+	switch {
+	case o.EndpointingMs < 0:
+		q.Set("endpointing", "false")
+	case o.EndpointingMs > 0:
+		q.Set("endpointing", strconv.Itoa(o.EndpointingMs))
+	}
+	if o.UtteranceEndMs > 0 {
+		q.Set("utterance_end_ms", strconv.Itoa(o.UtteranceEndMs))
+	}
+	if o.InterimResults {
+		q.Set("interim_results", "true")
+	}
+	for _, keyword := range o.Keywords {
+		q.Add("keywords", keyword)
+	}
+	for _, keyterm := range o.Keyterms {
+		q.Add("keyterm", keyterm)
+	}
+	if o.Diarize {
+		q.Set("diarize", "true")
+	}
+	if o.Numerals {
+		q.Set("numerals", "true")
+	}
+	if o.ProfanityFilter {
+		q.Set("profanity_filter", "true")
+	}
+	for _, redact := range o.Redact {
+		q.Add("redact", redact)
+	}
+	base.RawQuery = q.Encode()
+	return base.String(), nil
+}
+
+var variableRe = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// WithVariables returns a copy with "{{name}}" placeholders in keywords and
+// keyterms replaced from vars, e.g. to boost the customer's name. Terms that
+// reference a variable the call does not have are dropped.
+func (o DeepgramOptions) WithVariables(vars map[string]string) DeepgramOptions {
+	o.Keywords = expandTerms(o.Keywords, vars)
+	o.Keyterms = expandTerms(o.Keyterms, vars)
+	return o
+}
+
+func expandTerms(terms []string, vars map[string]string) []string {
+	expanded := make([]string, 0, len(terms))
+	for _, term := range terms {
+		missing := false
+		term = variableRe.ReplaceAllStringFunc(term, func(placeholder string) string {
+			value, ok := vars[variableRe.FindStringSubmatch(placeholder)[1]]
+			if !ok || strings.TrimSpace(value) == "" {
+				missing = true
+			}
+			return value
+		})
+		if !missing && strings.TrimSpace(term) != "" {
+			expanded = append(expanded, strings.TrimSpace(term))
+		}
+	}
+	return expanded
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mrsingh-rishi/voice-bot/agent"
+	"github.com/mrsingh-rishi/voice-bot/auth"
+	"github.com/mrsingh-rishi/voice-bot/call"
+	"github.com/mrsingh-rishi/voice-bot/fallback"
+	"github.com/mrsingh-rishi/voice-bot/logging"
+	"github.com/mrsingh-rishi/voice-bot/metrics"
+	"github.com/mrsingh-rishi/voice-bot/normalize"
+	"github.com/mrsingh-rishi/voice-bot/transport"
+	"github.com/mrsingh-rishi/voice-bot/tts"
+	"github.com/mrsingh-rishi/voice-bot/workspace"
+	twilio "github.com/twilio/twilio-go"
+)
+
+// tenant is a workspace with everything its calls run on. Nothing is
+// shared between tenants: agents, caches, provider health and pending call
+// variables are all their own.
+type tenant struct {
+	*workspace.Workspace
+	agents *agent.Registry
+	deps   call.Dependencies
+	// twilio is nil when the workspace has no Twilio account
+	twilio *twilio.RestClient
+	// callVariables hold the variables of outbound calls until their media
+	// stream connects
+	callVariables *call.VariableStore
+}
+
+// tenantKey is the fiber.Ctx local holding the request's tenant.
+const tenantKey = "tenant"
+
+// tenantOptions are the server settings every tenant is built with.
+type tenantOptions struct {
+	endpoints call.Endpoints
+	// cacheBytes is each tenant's in-memory TTS cache budget
+	cacheBytes int64
+	// cacheDir, if set, holds a disk cache directory per workspace
+	cacheDir string
+}
+
+func newTenant(w *workspace.Workspace, opts tenantOptions) (*tenant, error) {
+	agents, err := agent.LoadRegistry(w.AgentsDir)
+	if err != nil {
+		return nil, err
+	}
+	cacheDir := ""
+	if opts.cacheDir != "" {
+		cacheDir = filepath.Join(opts.cacheDir, w.ID)
+	}
+	phraseCache, err := tts.NewPhraseCache(opts.cacheBytes, cacheDir)
+	if err != nil {
+		return nil, err
+	}
+	t := &tenant{
+		Workspace: w,
+		agents:    agents,
+		deps: call.Dependencies{
+			Fillers:     tts.NewFillerCache(tts.DefaultFillerWords),
+			PhraseCache: phraseCache,
+			// A provider that fails 3 times in a row is skipped for 30s, then retried
+			Breakers:  fallback.NewRegistry(3, 30*time.Second),
+			Endpoints: opts.endpoints,
+			Credentials: call.Credentials{
+				Deepgram:   w.Keys.Deepgram,
+				OpenAI:     w.Keys.OpenAI,
+				ElevenLabs: w.Keys.ElevenLabs,
+			},
+			Workspace: w.ID,
+		},
+		callVariables: call.NewVariableStore(time.Hour),
+	}
+	if w.TwilioAuthToken != "" {
+		t.twilio = twilio.NewRestClientWithParams(twilio.ClientParams{
+			Username: w.Twilio.AccountSID,
+			Password: w.TwilioAuthToken,
+		})
+	}
+	return t, nil
+}
+
+// warm pre-synthesizes filler clips and configured phrases for every agent
+// voice, including those of the languages an agent may switch to, so it runs
+// in the background and startup is not blocked on ElevenLabs.
+func (t *tenant) warm() {
+	log := logging.Logger("tts")
+	for _, agentConfig := range t.agents.All() {
+		for _, voice := range agentConfig.Voices() {
+			ttsClient, _ := tts.NewElevenLabsClient(t.Keys.ElevenLabs, voice.VoiceID, voice.Settings.ModelId, nil)
+			if t.deps.Endpoints.ElevenLabs != "" {
+				ttsClient.BaseURL = t.deps.Endpoints.ElevenLabs
+			}
+			ttsClient.Settings = voice.Settings
+			ttsClient.Cache = t.deps.PhraseCache
+			if err := t.deps.Fillers.Warm(ttsClient); err != nil {
+				log.Warn("Failed to cache fillers", "workspace", t.ID, "agent", agentConfig.ID, "language", voice.Language, "error", err)
+			}
+			// Phrases are cached in the form the response worker will ask for
+			normalizer := normalize.New(voice.Locale, agentConfig.Pronunciations)
+			var phrases []string
+			for _, phrase := range agentConfig.PhrasesToWarm() {
+				phrases = append(phrases, normalizer.Normalize(phrase))
+			}
+			if err := ttsClient.Prewarm(phrases); err != nil {
+				log.Warn("Failed to prewarm phrases", "workspace", t.ID, "agent", agentConfig.ID, "language", voice.Language, "error", err)
+			}
+		}
+	}
+}
+
+// agent returns the tenant's agent with the given ID, or its default agent
+// when there is none.
+func (t *tenant) agent(id string) *agent.Config {
+	agentConfig, ok := t.agents.Get(id)
+	if !ok {
+		logging.Logger("call").Warn("Unknown agent, using default", "workspace", t.ID, "agent", id)
+		agentConfig, _ = t.agents.Get("")
+	}
+	return agentConfig
+}
+
+// runCall runs a call over tr until it ends. The call counts against the
+// workspace's limits and is reported to its webhooks; channel says where it
+// came from, e.g. "twilio" or "sip".
+func (t *tenant) runCall(channel string, tr transport.Transport, agentConfig *agent.Config, variables map[string]string) {
+	info := map[string]any{"channel": channel, "agent": agentConfig.ID}
+	end, err := t.StartCall()
+	if err != nil {
+		logging.Logger("call").Warn("Rejecting call", "workspace", t.ID, "channel", channel, "error", err)
+		info["reason"] = err.Error()
+		t.Webhooks.Send(workspace.EventCallRejected, info)
+		metrics.Calls.WithLabelValues(t.ID, channel, metrics.OutcomeRejected).Inc()
+		tr.Close()
+		return
+	}
+	defer end()
+	active := metrics.ActiveCalls.WithLabelValues(t.ID)
+	active.Inc()
+	defer active.Dec()
+
+	c, err := call.NewCall(tr, agentConfig, variables, t.deps)
+	if err != nil {
+		logging.Logger("call").Error("Error creating call", "workspace", t.ID, "channel", channel, "error", err)
+		metrics.Calls.WithLabelValues(t.ID, channel, metrics.OutcomeFailed).Inc()
+		tr.Close()
+		return
+	}
+	defer c.CleanupResources()
+	// OnStart runs on the call's receive loop
+	var mu sync.Mutex
+	var started time.Time
+	c.OnStart = func(start transport.Start) {
+		mu.Lock()
+		defer mu.Unlock()
+		started = time.Now()
+		info["call_id"] = start.CallSid
+		info["stream_id"] = start.StreamID
+		t.Webhooks.Send(workspace.EventCallStarted, info)
+	}
+	c.Start()
+	metrics.Calls.WithLabelValues(t.ID, channel, c.Outcome()).Inc()
+	mu.Lock()
+	if !started.IsZero() {
+		info["duration_sec"] = int(time.Since(started).Seconds())
+		t.Webhooks.Send(workspace.EventCallEnded, info)
+	}
+	mu.Unlock()
+}
+
+// tenantRegistry holds the tenant of every workspace by ID.
+type tenantRegistry map[string]*tenant
+
+// get returns the tenant of a workspace; an empty ID is the default one.
+func (ts tenantRegistry) get(id string) (*tenant, bool) {
+	if id == "" {
+		id = workspace.DefaultID
+	}
+	t, ok := ts[id]
+	return t, ok
+}
+
+// forRequest returns the tenant of the authenticated principal. The
+// bootstrap admin key belongs to no workspace and picks one with the
+// X-Workspace header, or gets the default one.
+func (ts tenantRegistry) forRequest(c *fiber.Ctx) (*tenant, bool) {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok {
+		return nil, false
+	}
+	if principal.Workspace == "" {
+		return ts.get(c.Get("X-Workspace"))
+	}
+	return ts.get(principal.Workspace)
+}
+
+// forTwilio returns the tenant a Twilio request is for: the workspace named
+// in the URL we gave Twilio, else the owner of the number that was called,
+// else the default one.
+func (ts tenantRegistry) forTwilio(c *fiber.Ctx) (*tenant, bool) {
+	if id := c.Query("workspace"); id != "" {
+		return ts.get(id)
+	}
+	if to := c.Query("To"); to != "" {
+		for _, t := range ts {
+			if t.OwnsNumber(to) {
+				return t, true
+			}
+		}
+	}
+	return ts.get("")
+}
@@ -0,0 +1,125 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Call is the trace of one call: a span covering the call, and a child span
+// per caller turn that lasts until the reply has played. Vendor requests
+// made for the call are traced under whichever of the two is current, and
+// carry the call's IDs themselves so they can be searched for on their own.
+// A nil Call traces nothing.
+type Call struct {
+	mu   sync.Mutex
+	ctx  context.Context
+	span trace.Span
+	// attrs identify the call on every span it starts
+	attrs []attribute.KeyValue
+	turn  trace.Span
+	// turnCtx carries turn; nil between turns
+	turnCtx context.Context
+	turns   int
+}
+
+// callKey is the context key of the Call a context was made for.
+type callKey struct{}
+
+// StartCall starts the trace of a call to an agent of a workspace.
+func StartCall(agentID string, workspaceID string) *Call {
+	attrs := []attribute.KeyValue{AgentID.String(agentID), Workspace.String(workspaceID)}
+	ctx, span := Start(context.Background(), "call", attrs...)
+	return &Call{ctx: ctx, span: span, attrs: attrs}
+}
+
+// Started tags the call with the IDs the far end gave it.
+func (c *Call) Started(callSid string, streamSid string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ids := []attribute.KeyValue{CallSID.String(callSid), StreamSID.String(streamSid)}
+	c.attrs = append(c.attrs, ids...)
+	c.span.SetAttributes(ids...)
+}
+
+// StartTurn ends the current turn, if any, and starts the next one.
+func (c *Call) StartTurn() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.endTurnLocked()
+	c.turns++
+	attrs := append([]attribute.KeyValue{TurnNumber.Int(c.turns)}, c.attrs...)
+	c.turnCtx, c.turn = Start(c.ctx, "turn", attrs...)
+}
+
+// EndTurn ends the current turn, if any.
+func (c *Call) EndTurn() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.endTurnLocked()
+}
+
+func (c *Call) endTurnLocked() {
+	if c.turn != nil {
+		c.turn.End()
+		c.turn, c.turnCtx = nil, nil
+	}
+}
+
+// Context returns ctx carrying the span of the current turn, or of the call
+// between turns, and the call itself so the spans started from it are
+// tagged with the call's IDs, including ones it only learns later. Its
+// deadline and cancellation stay those of ctx.
+func (c *Call) Context(ctx context.Context) context.Context {
+	if c == nil {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, callKey{}, c)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.turnCtx != nil {
+		return trace.ContextWithSpan(ctx, c.turn)
+	}
+	return trace.ContextWithSpan(ctx, c.span)
+}
+
+// identity returns the attributes that identify the call.
+func (c *Call) identity() []attribute.KeyValue {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]attribute.KeyValue(nil), c.attrs...)
+}
+
+// TraceID identifies the call's trace in the exporter; empty when tracing
+// is off.
+func (c *Call) TraceID() string {
+	if c == nil || !c.span.SpanContext().HasTraceID() {
+		return ""
+	}
+	return c.span.SpanContext().TraceID().String()
+}
+
+// End ends the call, and its current turn, with outcome; err marks the call
+// as failed.
+func (c *Call) End(outcome string, err error) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.endTurnLocked()
+	c.span.SetAttributes(attribute.String("call.outcome", outcome))
+	Fail(c.span, err)
+	c.span.End()
+}
@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestVendorSpansCarryCallIDs(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	call := StartCall("support", "acme")
+	// Made before the call SID is known, as the STT client's context is
+	ctx := call.Context(context.Background())
+	call.Started("CA123", "MZ456")
+	call.StartTurn()
+	_, connect := Start(ctx, "stt.connect", Provider.String("stt:deepgram"))
+	connect.End()
+	_, request := Start(call.Context(context.Background()), "llm.request", Provider.String("llm:openai"))
+	request.End()
+	call.End("completed", nil)
+
+	want := map[attribute.Key]string{
+		CallSID:   "CA123",
+		StreamSID: "MZ456",
+		AgentID:   "support",
+		Workspace: "acme",
+	}
+	vendorSpans := 0
+	for _, span := range recorder.Ended() {
+		if span.Name() != "stt.connect" && span.Name() != "llm.request" {
+			continue
+		}
+		vendorSpans++
+		got := map[attribute.Key]string{}
+		for _, kv := range span.Attributes() {
+			got[kv.Key] = kv.Value.Emit()
+		}
+		for key, value := range want {
+			if got[key] != value {
+				t.Errorf("%s span %s = %q, want %q", span.Name(), key, got[key], value)
+			}
+		}
+		if got[Provider] == "" {
+			t.Errorf("%s span has no provider", span.Name())
+		}
+	}
+	if vendorSpans != 2 {
+		t.Fatalf("recorded %d vendor spans, want 2", vendorSpans)
+	}
+}
+
+func TestNilCall(t *testing.T) {
+	var call *Call
+	ctx := context.Background()
+	if call.Context(ctx) != ctx {
+		t.Error("nil Call changed the context")
+	}
+	call.Started("CA123", "MZ456")
+	call.StartTurn()
+	call.EndTurn()
+	call.End("completed", nil)
+	if call.TraceID() != "" {
+		t.Error("nil Call has a trace ID")
+	}
+}
@@ -0,0 +1,90 @@
+// Package tracing sets up OpenTelemetry tracing: one trace per call, with a
+// span per caller turn and per request to an STT, LLM or TTS provider, so
+// the work of calls running at the same time can be told apart.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// serviceName is reported unless OTEL_SERVICE_NAME says otherwise.
+const serviceName = "voice-bot"
+
+// Span attributes.
+const (
+	CallSID    = attribute.Key("call.sid")
+	StreamSID  = attribute.Key("call.stream_sid")
+	AgentID    = attribute.Key("agent.id")
+	Workspace  = attribute.Key("workspace.id")
+	TurnNumber = attribute.Key("call.turn")
+	// Provider is the vendor endpoint a request went to, as named by its
+	// circuit breaker
+	Provider = attribute.Key("vendor.provider")
+)
+
+var tracer = otel.Tracer("github.com/mrsingh-rishi/voice-bot")
+
+// Setup installs the global tracer provider for exporter: "otlp" sends
+// spans to the collector named by the standard OTEL_EXPORTER_OTLP_*
+// variables, "stdout" prints them for local runs, and "" or "none" turns
+// tracing off. The returned function flushes pending spans.
+func Setup(ctx context.Context, exporter string) (func(context.Context) error, error) {
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		spanExporter, err = otlptracehttp.New(ctx)
+	case "stdout", "console":
+		spanExporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start starts a span as a child of the one in ctx, tagged with the IDs of
+// the call ctx was made for by Call.Context. Without a tracer provider it
+// is a no-op.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if c, ok := ctx.Value(callKey{}).(*Call); ok {
+		attrs = append(c.identity(), attrs...)
+	}
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Fail marks span as failed with err; a nil err leaves it alone.
+func Fail(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
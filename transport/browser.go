@@ -0,0 +1,103 @@
+package transport
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/gofiber/websocket/v2"
+	"github.com/mrsingh-rishi/voice-bot/audio"
+)
+
+// BrowserFormat is what browser clients send and play: 16 kHz PCM16,
+// little-endian.
+var BrowserFormat = audio.Format{Codec: audio.CodecPCM16, SampleRate: audio.Rate16k}
+
+// browserMessage is a control message of the browser protocol.
+type browserMessage struct {
+	Type  string `json:"type"`
+	ID    string `json:"id,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Digit string `json:"digit,omitempty"`
+}
+
+// Browser is the plain websocket protocol for browsers and web apps. Binary
+// messages carry BrowserFormat audio in both directions; text messages are
+// JSON control messages:
+//
+//	client -> bot  {"type":"start","id":"optional"}  first message of the stream
+//	               {"type":"mark","name":"..."}      once the audio sent before that mark has played
+//	               {"type":"dtmf","digit":"5"}
+//	               {"type":"stop"}
+//	bot -> client  {"type":"started","id":"..."}     the stream ID, answering start
+//	               {"type":"mark","name":"..."}      echo it back after the audio before it
+//	               {"type":"clear"}                  stop playing, and echo every pending mark
+type Browser struct {
+	*wsConn
+}
+
+func NewBrowser(conn Conn) *Browser {
+	return &Browser{wsConn: newWSConn(conn, "browser")}
+}
+
+func (b *Browser) Format() audio.Format {
+	return BrowserFormat
+}
+
+func (b *Browser) Receive() (Event, error) {
+	for {
+		messageType, data, err := b.read()
+		if err != nil {
+			return Event{}, err
+		}
+		if messageType == websocket.BinaryMessage {
+			return Event{Type: EventAudio, Audio: data}, nil
+		}
+		var msg browserMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			b.log.Warn("Browser stream sent invalid JSON", "error", err)
+			continue
+		}
+		switch msg.Type {
+		case "start":
+			id := msg.ID
+			if id == "" {
+				id = newStreamID()
+			}
+			if err := b.writeJSON(browserMessage{Type: "started", ID: id}); err != nil {
+				return Event{}, err
+			}
+			start := Start{StreamID: id}
+			b.started(start)
+			return Event{Type: EventStart, Start: start}, nil
+		case "mark":
+			return Event{Type: EventMark, Mark: msg.Name}, nil
+		case "dtmf":
+			return Event{Type: EventDTMF, Digit: msg.Digit}, nil
+		case "stop":
+			return Event{Type: EventStop}, nil
+		default:
+			b.log.Debug("Ignoring unknown browser message", "type", msg.Type)
+		}
+	}
+}
+
+func (b *Browser) SendAudio(data []byte) error {
+	return b.writeBinary(data)
+}
+
+func (b *Browser) Clear() error {
+	return b.writeJSON(browserMessage{Type: "clear"})
+}
+
+func (b *Browser) Mark(name string) error {
+	return b.writeJSON(browserMessage{Type: "mark", Name: name})
+}
+
+// newStreamID names a browser stream the way Twilio names its own, e.g.
+// "WS3f9a...".
+func newStreamID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "WS" + hex.EncodeToString(b)
+}
@@ -0,0 +1,133 @@
+package transport
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sync"
+
+	"github.com/mrsingh-rishi/voice-bot/audio"
+)
+
+// plivoEvent is one message of a Plivo audio stream.
+type plivoEvent struct {
+	Event    string `json:"event"` // "start", "media", "dtmf", "playedStream", "clearedAudio", "stop"
+	StreamID string `json:"streamId"`
+	Name     string `json:"name"`
+	Start    struct {
+		CallID      string `json:"callId"`
+		StreamID    string `json:"streamId"`
+		MediaFormat struct {
+			Encoding   string `json:"encoding"`
+			SampleRate int    `json:"sampleRate"`
+		} `json:"mediaFormat"`
+	} `json:"start"`
+	Media struct {
+		Track   string `json:"track"`
+		Payload string `json:"payload"`
+	} `json:"media"`
+	DTMF struct {
+		Digit string `json:"digit"`
+	} `json:"dtmf"`
+}
+
+// Plivo is a bidirectional Plivo audio stream: JSON messages carrying base64
+// audio in the stream's content type, audio/x-mulaw at 8 kHz or audio/x-l16
+// at 8 or 16 kHz. Audio is sent with "playAudio", marks are "checkpoint"
+// events acked by "playedStream", and "clearAudio" clears.
+type Plivo struct {
+	*wsConn
+	format audio.Format
+	// contentType is the stream's encoding name, repeated on every playAudio
+	contentType string
+
+	mu       sync.Mutex
+	streamID string
+}
+
+func NewPlivo(conn Conn) *Plivo {
+	return &Plivo{wsConn: newWSConn(conn, "plivo")}
+}
+
+func (p *Plivo) Format() audio.Format {
+	return p.format
+}
+
+func (p *Plivo) Receive() (Event, error) {
+	for {
+		_, msg, err := p.read()
+		if err != nil {
+			return Event{}, err
+		}
+		var ev plivoEvent
+		if err := json.Unmarshal(msg, &ev); err != nil {
+			p.log.Warn("Plivo stream sent invalid JSON", "error", err)
+			continue
+		}
+		switch ev.Event {
+		case "start":
+			format, err := mediaFormat(ev.Start.MediaFormat.Encoding, ev.Start.MediaFormat.SampleRate)
+			if err != nil {
+				return Event{}, err
+			}
+			p.format = format
+			p.contentType = ev.Start.MediaFormat.Encoding
+			p.mu.Lock()
+			p.streamID = ev.Start.StreamID
+			p.mu.Unlock()
+			start := Start{CallSid: ev.Start.CallID, StreamID: ev.Start.StreamID}
+			p.started(start)
+			return Event{Type: EventStart, Start: start}, nil
+		case "media":
+			if ev.Media.Track != "" && ev.Media.Track != "inbound" {
+				continue
+			}
+			chunk, err := base64.StdEncoding.DecodeString(ev.Media.Payload)
+			if err != nil {
+				p.log.Warn("Invalid media payload", "error", err)
+				continue
+			}
+			return Event{Type: EventAudio, Audio: chunk}, nil
+		case "playedStream":
+			return Event{Type: EventMark, Mark: ev.Name}, nil
+		case "dtmf":
+			return Event{Type: EventDTMF, Digit: ev.DTMF.Digit}, nil
+		case "stop":
+			return Event{Type: EventStop}, nil
+		case "clearedAudio":
+		default:
+			p.log.Debug("Ignoring unknown Plivo event", "event", ev.Event)
+		}
+	}
+}
+
+func (p *Plivo) SendAudio(data []byte) error {
+	return p.writeJSON(map[string]interface{}{
+		"event": "playAudio",
+		"media": map[string]interface{}{
+			"contentType": p.contentType,
+			"sampleRate":  p.format.SampleRate,
+			"payload":     base64.StdEncoding.EncodeToString(data),
+		},
+	})
+}
+
+func (p *Plivo) Clear() error {
+	return p.writeJSON(map[string]string{
+		"event":    "clearAudio",
+		"streamId": p.id(),
+	})
+}
+
+func (p *Plivo) Mark(name string) error {
+	return p.writeJSON(map[string]string{
+		"event":    "checkpoint",
+		"streamId": p.id(),
+		"name":     name,
+	})
+}
+
+func (p *Plivo) id() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.streamID
+}
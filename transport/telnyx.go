@@ -0,0 +1,121 @@
+package transport
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/mrsingh-rishi/voice-bot/audio"
+)
+
+// telnyxEvent is one message of Telnyx media streaming.
+type telnyxEvent struct {
+	Event    string `json:"event"` // "connected", "start", "media", "mark", "dtmf", "stop", "error"
+	StreamID string `json:"stream_id"`
+	Start    struct {
+		CallControlID string `json:"call_control_id"`
+		MediaFormat   struct {
+			Encoding   string `json:"encoding"`
+			SampleRate int    `json:"sample_rate"`
+		} `json:"media_format"`
+	} `json:"start"`
+	Media struct {
+		Track   string `json:"track"`
+		Payload string `json:"payload"`
+	} `json:"media"`
+	Mark struct {
+		Name string `json:"name"`
+	} `json:"mark"`
+	DTMF struct {
+		Digit string `json:"digit"`
+	} `json:"dtmf"`
+	Payload struct {
+		Code   int    `json:"code"`
+		Title  string `json:"title"`
+		Detail string `json:"detail"`
+	} `json:"payload"`
+}
+
+// Telnyx is a bidirectional Telnyx media stream: JSON messages carrying
+// base64 G.711, PCMU or PCMA as set up on the call, with Twilio-like media,
+// mark and clear events.
+type Telnyx struct {
+	*wsConn
+	format audio.Format
+}
+
+func NewTelnyx(conn Conn) *Telnyx {
+	return &Telnyx{wsConn: newWSConn(conn, "telnyx")}
+}
+
+func (t *Telnyx) Format() audio.Format {
+	return t.format
+}
+
+func (t *Telnyx) Receive() (Event, error) {
+	for {
+		_, msg, err := t.read()
+		if err != nil {
+			return Event{}, err
+		}
+		var ev telnyxEvent
+		if err := json.Unmarshal(msg, &ev); err != nil {
+			t.log.Warn("Telnyx stream sent invalid JSON", "error", err)
+			continue
+		}
+		switch ev.Event {
+		case "connected":
+		case "start":
+			format, err := mediaFormat(ev.Start.MediaFormat.Encoding, ev.Start.MediaFormat.SampleRate)
+			if err != nil {
+				return Event{}, err
+			}
+			t.format = format
+			start := Start{CallSid: ev.Start.CallControlID, StreamID: ev.StreamID}
+			t.started(start)
+			return Event{Type: EventStart, Start: start}, nil
+		case "media":
+			// Only the caller's side; the outbound track echoes our own audio
+			if ev.Media.Track != "" && ev.Media.Track != "inbound" {
+				continue
+			}
+			chunk, err := base64.StdEncoding.DecodeString(ev.Media.Payload)
+			if err != nil {
+				t.log.Warn("Invalid media payload", "error", err)
+				continue
+			}
+			return Event{Type: EventAudio, Audio: chunk}, nil
+		case "mark":
+			return Event{Type: EventMark, Mark: ev.Mark.Name}, nil
+		case "dtmf":
+			return Event{Type: EventDTMF, Digit: ev.DTMF.Digit}, nil
+		case "stop":
+			return Event{Type: EventStop}, nil
+		case "error":
+			t.log.Error("Telnyx stream error", "code", ev.Payload.Code, "title", ev.Payload.Title, "detail", ev.Payload.Detail)
+		default:
+			t.log.Debug("Ignoring unknown Telnyx event", "event", ev.Event)
+		}
+	}
+}
+
+func (t *Telnyx) SendAudio(data []byte) error {
+	return t.writeJSON(map[string]interface{}{
+		"event": "media",
+		"media": map[string]string{
+			"payload": base64.StdEncoding.EncodeToString(data),
+		},
+	})
+}
+
+func (t *Telnyx) Clear() error {
+	return t.writeJSON(map[string]string{"event": "clear"})
+}
+
+func (t *Telnyx) Mark(name string) error {
+	return t.writeJSON(map[string]interface{}{
+		"event": "mark",
+		"mark": map[string]string{
+			"name": name,
+		},
+	})
+}
@@ -0,0 +1,156 @@
+// Package transport carries a call's audio between the bot and the caller's
+// side of a media stream. Each carrier or client protocol is one Transport;
+// the call pipeline only deals with audio frames and a few control events.
+package transport
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/websocket/v2"
+	"github.com/mrsingh-rishi/voice-bot/audio"
+	"github.com/mrsingh-rishi/voice-bot/logging"
+)
+
+type EventType string
+
+const (
+	// EventStart opens the stream; nothing is sent before it
+	EventStart EventType = "start"
+	// EventAudio carries a frame of the caller's audio in the transport's Format
+	EventAudio EventType = "audio"
+	// EventMark acks a mark once everything sent before it has played or
+	// was cleared
+	EventMark EventType = "mark"
+	// EventDTMF is a key the caller pressed
+	EventDTMF EventType = "dtmf"
+	// EventStop ends the stream
+	EventStop EventType = "stop"
+)
+
+// Start describes a stream that has just started.
+type Start struct {
+	CallSid  string
+	StreamID string
+}
+
+// Event is something that arrived from the caller's side of the stream.
+type Event struct {
+	Type  EventType
+	Start Start
+	Audio []byte
+	Mark  string
+	Digit string
+}
+
+// Transport is one media stream. Receive is called from a single goroutine,
+// and so are SendAudio, Clear and Mark; Close may be called from anywhere,
+// more than once.
+type Transport interface {
+	// Format is the audio format of frames in both directions. When the
+	// carrier negotiates the codec it is only known once EventStart arrived.
+	Format() audio.Format
+	// Receive blocks until the next event; it returns io.EOF once the
+	// stream was closed normally
+	Receive() (Event, error)
+	// SendAudio sends a chunk of the agent's audio in Format
+	SendAudio(data []byte) error
+	// Clear makes the far end drop the audio it has buffered
+	Clear() error
+	// Mark asks the far end to ack name once everything sent before it has
+	// played
+	Mark(name string) error
+	Close() error
+}
+
+// New returns the transport for a carrier's media stream protocol: "twilio",
+// "vonage", "telnyx", "plivo" or "browser".
+func New(carrier string, conn Conn) (Transport, error) {
+	switch carrier {
+	case "twilio":
+		return NewTwilio(conn), nil
+	case "vonage":
+		return NewVonage(conn), nil
+	case "telnyx":
+		return NewTelnyx(conn), nil
+	case "plivo":
+		return NewPlivo(conn), nil
+	case "browser":
+		return NewBrowser(conn), nil
+	}
+	return nil, fmt.Errorf("unknown carrier %q", carrier)
+}
+
+// mediaFormat maps the encoding names carriers use, MIME types such as
+// "audio/x-mulaw" or RTP names such as "PCMU", to a format.
+func mediaFormat(encoding string, sampleRate int) (audio.Format, error) {
+	if sampleRate <= 0 {
+		sampleRate = audio.Rate8k
+	}
+	switch strings.ToLower(encoding) {
+	case "audio/x-mulaw", "audio/mulaw", "audio/pcmu", "pcmu":
+		return audio.Format{Codec: audio.CodecMuLaw, SampleRate: sampleRate}, nil
+	case "audio/x-alaw", "audio/alaw", "audio/pcma", "pcma":
+		return audio.Format{Codec: audio.CodecALaw, SampleRate: sampleRate}, nil
+	case "audio/l16", "audio/x-l16":
+		return audio.Format{Codec: audio.CodecPCM16, SampleRate: sampleRate}, nil
+	}
+	return audio.Format{}, fmt.Errorf("unsupported media encoding %q", encoding)
+}
+
+// Conn is the websocket a transport runs over; *websocket.Conn satisfies it.
+type Conn interface {
+	ReadMessage() (messageType int, data []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+	WriteJSON(v interface{}) error
+	Close() error
+}
+
+// wsConn serializes writes to a Conn and closes it once.
+type wsConn struct {
+	conn      Conn
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+	// log is only used by the goroutine receiving events
+	log *slog.Logger
+}
+
+func newWSConn(conn Conn, carrier string) *wsConn {
+	return &wsConn{conn: conn, log: logging.Logger("transport").With("carrier", carrier)}
+}
+
+// started names the stream in everything logged from now on.
+func (c *wsConn) started(start Start) {
+	c.log = c.log.With(slog.Group("call", "sid", start.CallSid, "stream_sid", start.StreamID))
+}
+
+func (c *wsConn) read() (int, []byte, error) {
+	messageType, data, err := c.conn.ReadMessage()
+	if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+		return 0, nil, io.EOF
+	}
+	return messageType, data, err
+}
+
+func (c *wsConn) writeJSON(v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteJSON(v)
+}
+
+func (c *wsConn) writeBinary(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
+func (c *wsConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		err = c.conn.Close()
+	})
+	return err
+}
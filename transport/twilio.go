@@ -0,0 +1,117 @@
+package transport
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sync"
+
+	"github.com/mrsingh-rishi/voice-bot/audio"
+)
+
+// twilioEvent is one message of Twilio's media stream protocol.
+type twilioEvent struct {
+	Event string `json:"event"` // "connected", "start", "media", "mark", "dtmf", "stop"
+	Media struct {
+		Payload string `json:"payload"` // base64 audio
+	} `json:"media"`
+	Start struct {
+		CallSid   string `json:"callSid"`
+		StreamSid string `json:"streamSid"`
+	} `json:"start"`
+	Mark struct {
+		Name string `json:"name"`
+	} `json:"mark"`
+	DTMF struct {
+		Digit string `json:"digit"`
+	} `json:"dtmf"`
+}
+
+// Twilio is a bidirectional Twilio media stream: JSON messages carrying
+// base64 8 kHz mu-law.
+type Twilio struct {
+	*wsConn
+
+	mu        sync.Mutex
+	streamSid string
+}
+
+func NewTwilio(conn Conn) *Twilio {
+	return &Twilio{wsConn: newWSConn(conn, "twilio")}
+}
+
+func (t *Twilio) Format() audio.Format {
+	return audio.Telephony
+}
+
+func (t *Twilio) Receive() (Event, error) {
+	for {
+		_, msg, err := t.read()
+		if err != nil {
+			return Event{}, err
+		}
+		var ev twilioEvent
+		if err := json.Unmarshal(msg, &ev); err != nil {
+			t.log.Warn("Twilio stream sent invalid JSON", "error", err)
+			continue
+		}
+		switch ev.Event {
+		case "connected":
+			continue
+		case "start":
+			t.mu.Lock()
+			t.streamSid = ev.Start.StreamSid
+			t.mu.Unlock()
+			start := Start{CallSid: ev.Start.CallSid, StreamID: ev.Start.StreamSid}
+			t.started(start)
+			return Event{Type: EventStart, Start: start}, nil
+		case "media":
+			chunk, err := base64.StdEncoding.DecodeString(ev.Media.Payload)
+			if err != nil {
+				t.log.Warn("Invalid media payload", "error", err)
+				continue
+			}
+			return Event{Type: EventAudio, Audio: chunk}, nil
+		case "mark":
+			return Event{Type: EventMark, Mark: ev.Mark.Name}, nil
+		case "dtmf":
+			return Event{Type: EventDTMF, Digit: ev.DTMF.Digit}, nil
+		case "stop":
+			return Event{Type: EventStop}, nil
+		default:
+			t.log.Debug("Ignoring unknown Twilio event", "event", ev.Event)
+		}
+	}
+}
+
+func (t *Twilio) SendAudio(data []byte) error {
+	return t.writeJSON(map[string]interface{}{
+		"event":     "media",
+		"streamSid": t.sid(),
+		"media": map[string]string{
+			"payload": base64.StdEncoding.EncodeToString(data),
+		},
+	})
+}
+
+func (t *Twilio) Clear() error {
+	return t.writeJSON(map[string]interface{}{
+		"event":     "clear",
+		"streamSid": t.sid(),
+	})
+}
+
+func (t *Twilio) Mark(name string) error {
+	return t.writeJSON(map[string]interface{}{
+		"event":     "mark",
+		"streamSid": t.sid(),
+		"mark": map[string]string{
+			"name": name,
+		},
+	})
+}
+
+func (t *Twilio) sid() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.streamSid
+}
@@ -0,0 +1,116 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strconv"
+
+	"github.com/gofiber/websocket/v2"
+	"github.com/mrsingh-rishi/voice-bot/audio"
+)
+
+// vonageEvent is a text message of the Vonage Voice API websocket.
+type vonageEvent struct {
+	Event       string `json:"event"` // "websocket:connected", "websocket:dtmf", "websocket:notify", "websocket:cleared"
+	ContentType string `json:"content-type"`
+	Digit       string `json:"digit"`
+	Payload     struct {
+		Name string `json:"name"`
+	} `json:"payload"`
+}
+
+// Vonage is a Vonage Voice API websocket. The first text message names the
+// content type, "audio/l16;rate=16000" or "audio/l16;rate=8000", and audio
+// flows as binary linear16 in both directions. Vonage only plays whole 20 ms
+// frames, so outgoing audio is reframed and the last frame before a mark is
+// padded with silence. Marks are "notify" actions, echoed once the audio
+// before them has played.
+type Vonage struct {
+	*wsConn
+	format audio.Format
+	// framer is only used by the goroutine sending audio
+	framer *audio.Framer
+}
+
+func NewVonage(conn Conn) *Vonage {
+	return &Vonage{wsConn: newWSConn(conn, "vonage")}
+}
+
+func (v *Vonage) Format() audio.Format {
+	return v.format
+}
+
+func (v *Vonage) Receive() (Event, error) {
+	for {
+		messageType, data, err := v.read()
+		if err != nil {
+			return Event{}, err
+		}
+		if messageType == websocket.BinaryMessage {
+			return Event{Type: EventAudio, Audio: data}, nil
+		}
+		var ev vonageEvent
+		if err := json.Unmarshal(data, &ev); err != nil {
+			v.log.Warn("Vonage stream sent invalid JSON", "error", err)
+			continue
+		}
+		switch ev.Event {
+		case "websocket:connected":
+			format, err := vonageFormat(ev.ContentType)
+			if err != nil {
+				return Event{}, err
+			}
+			v.format = format
+			v.framer = audio.NewFramer(format.FrameBytes(audio.FrameDuration))
+			start := Start{StreamID: newStreamID()}
+			v.started(start)
+			return Event{Type: EventStart, Start: start}, nil
+		case "websocket:dtmf":
+			return Event{Type: EventDTMF, Digit: ev.Digit}, nil
+		case "websocket:notify":
+			return Event{Type: EventMark, Mark: ev.Payload.Name}, nil
+		case "websocket:cleared":
+		default:
+			v.log.Debug("Ignoring unknown Vonage event", "event", ev.Event)
+		}
+	}
+}
+
+// vonageFormat parses a content type such as "audio/l16;rate=16000".
+func vonageFormat(contentType string) (audio.Format, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return audio.Format{}, fmt.Errorf("invalid Vonage content type %q", contentType)
+	}
+	rate, _ := strconv.Atoi(params["rate"])
+	return mediaFormat(mediaType, rate)
+}
+
+func (v *Vonage) SendAudio(data []byte) error {
+	for _, frame := range v.framer.Push(data) {
+		if err := v.writeBinary(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Vonage) Clear() error {
+	v.framer.Flush()
+	return v.writeJSON(map[string]string{"action": "clear"})
+}
+
+func (v *Vonage) Mark(name string) error {
+	if rest := v.framer.Flush(); len(rest) > 0 {
+		frame := make([]byte, v.format.FrameBytes(audio.FrameDuration))
+		copy(frame, rest)
+		if err := v.writeBinary(frame); err != nil {
+			return err
+		}
+	}
+	return v.writeJSON(map[string]interface{}{
+		"action":  "notify",
+		"payload": map[string]string{"name": name},
+	})
+}
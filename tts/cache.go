@@ -0,0 +1,206 @@
+package tts
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mrsingh-rishi/voice-bot/logging"
+)
+
+// CacheKey identifies a synthesized phrase. Two requests that share every
+// field produce the same audio, so the key is content-addressed.
+type CacheKey struct {
+	Provider string
+	Voice    string
+	Model    string
+	Settings string // canonical encoding of the voice settings and output format
+	Text     string
+}
+
+// Hash returns the hex digest used as the cache entry name.
+func (k CacheKey) Hash() string {
+	text := strings.Join(strings.Fields(k.Text), " ")
+	sum := sha256.Sum256([]byte(strings.Join([]string{k.Provider, k.Voice, k.Model, k.Settings, text}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// CacheStats are the counters exposed for monitoring.
+type CacheStats struct {
+	Hits        int64 `json:"hits"`
+	DiskHits    int64 `json:"disk_hits"`
+	Misses      int64 `json:"misses"`
+	MemoryItems int   `json:"memory_items"`
+	MemoryBytes int64 `json:"memory_bytes"`
+}
+
+type cacheEntry struct {
+	key   string
+	audio []byte
+}
+
+// PhraseCache stores synthesized audio in an in-memory LRU bounded by bytes,
+// backed by an optional directory so entries survive restarts. It is meant
+// for the fixed phrases an agent is warmed with: the disk tier is not
+// evicted, and anything put in it is shared by every call.
+type PhraseCache struct {
+	maxBytes int64
+	dir      string
+
+	mu    sync.Mutex
+	lru   *list.List
+	items map[string]*list.Element
+	bytes int64
+
+	hits     atomic.Int64
+	diskHits atomic.Int64
+	misses   atomic.Int64
+}
+
+// NewPhraseCache creates a cache holding up to maxBytes of audio in memory.
+// An empty dir disables the disk tier.
+func NewPhraseCache(maxBytes int64, dir string) (*PhraseCache, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("cache size must be positive")
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("create cache dir: %w", err)
+		}
+	}
+	return &PhraseCache{
+		maxBytes: maxBytes,
+		dir:      dir,
+		lru:      list.New(),
+		items:    make(map[string]*list.Element),
+	}, nil
+}
+
+// Get returns the cached audio for key, checking memory first and then disk.
+// Disk hits are promoted into memory.
+func (c *PhraseCache) Get(key CacheKey) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	hash := key.Hash()
+
+	c.mu.Lock()
+	if el, ok := c.items[hash]; ok {
+		c.lru.MoveToFront(el)
+		audio := el.Value.(*cacheEntry).audio
+		c.mu.Unlock()
+		c.hits.Add(1)
+		return audio, true
+	}
+	c.mu.Unlock()
+
+	if c.dir != "" {
+		if audio, err := os.ReadFile(c.path(hash)); err == nil && len(audio) > 0 {
+			c.store(hash, audio)
+			c.hits.Add(1)
+			c.diskHits.Add(1)
+			return audio, true
+		}
+	}
+	c.misses.Add(1)
+	return nil, false
+}
+
+// Put stores audio under key in memory and, if enabled, on disk.
+func (c *PhraseCache) Put(key CacheKey, audio []byte) {
+	if c == nil || len(audio) == 0 {
+		return
+	}
+	hash := key.Hash()
+	c.store(hash, audio)
+
+	if c.dir != "" {
+		if err := c.writeFile(hash, audio); err != nil {
+			logging.Logger("tts").Error("TTS cache disk write error", "error", err)
+		}
+	}
+}
+
+// Contains reports whether key is cached without touching the hit counters.
+func (c *PhraseCache) Contains(key CacheKey) bool {
+	if c == nil {
+		return false
+	}
+	hash := key.Hash()
+	c.mu.Lock()
+	_, ok := c.items[hash]
+	c.mu.Unlock()
+	if ok {
+		return true
+	}
+	if c.dir == "" {
+		return false
+	}
+	_, err := os.Stat(c.path(hash))
+	return err == nil
+}
+
+func (c *PhraseCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Hits:        c.hits.Load(),
+		DiskHits:    c.diskHits.Load(),
+		Misses:      c.misses.Load(),
+		MemoryItems: c.lru.Len(),
+		MemoryBytes: c.bytes,
+	}
+}
+
+func (c *PhraseCache) store(hash string, audio []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[hash]; ok {
+		c.lru.MoveToFront(el)
+		return
+	}
+	if int64(len(audio)) > c.maxBytes {
+		return
+	}
+	c.items[hash] = c.lru.PushFront(&cacheEntry{key: hash, audio: audio})
+	c.bytes += int64(len(audio))
+	for c.bytes > c.maxBytes {
+		oldest := c.lru.Back()
+		entry := oldest.Value.(*cacheEntry)
+		c.lru.Remove(oldest)
+		delete(c.items, entry.key)
+		c.bytes -= int64(len(entry.audio))
+	}
+}
+
+func (c *PhraseCache) path(hash string) string {
+	return filepath.Join(c.dir, hash[:2], hash+".audio")
+}
+
+// writeFile writes through a temp file so a crash never leaves a truncated entry.
+func (c *PhraseCache) writeFile(hash string, audio []byte) error {
+	path := c.path(hash)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), hash+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(audio); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
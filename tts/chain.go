@@ -0,0 +1,113 @@
+package tts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mrsingh-rishi/voice-bot/fallback"
+	"github.com/mrsingh-rishi/voice-bot/logging"
+	"github.com/mrsingh-rishi/voice-bot/tracing"
+)
+
+// DefaultFirstAudioTimeout is how long a TTS provider gets to produce its
+// first audio before the next one in the chain is tried.
+const DefaultFirstAudioTimeout = 4 * time.Second
+
+// ErrAllProvidersFailed is returned when no TTS provider could speak.
+var ErrAllProvidersFailed = errors.New("all TTS providers failed")
+
+// Speaker is a TTS provider that can take part in a fallback chain.
+type Speaker interface {
+	// Name identifies the provider's circuit breaker
+	Name() string
+	// SpeakContext synthesizes text and hands every base64 mu-law chunk to emit
+	SpeakContext(ctx context.Context, text string, override *VoiceSettings, emit func(audioBase64 string)) error
+}
+
+// Chain speaks through the first provider that works. A provider is skipped
+// while its breaker is open, and abandoned if no audio arrives within
+// Timeout. Once a provider has emitted audio it is not replaced, since the
+// caller has already heard part of the sentence.
+type Chain struct {
+	Speakers            []Speaker
+	Breakers            *fallback.Registry
+	Timeout             time.Duration
+	OutputDeviceChannel chan<- string
+	// OnFirstAudio, if set, is called when a provider emits the first audio
+	// of the sentence
+	OnFirstAudio func()
+	// Log is the "tts" subsystem logger when nil
+	Log *slog.Logger
+}
+
+// Speak plays text, followed by an end-of-utterance sentinel. Nothing more
+// is queued once ctx ends.
+func (c *Chain) Speak(ctx context.Context, text string, override *VoiceSettings) error {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultFirstAudioTimeout
+	}
+	logger := c.Log
+	if logger == nil {
+		logger = logging.Logger("tts")
+	}
+	for _, speaker := range c.Speakers {
+		breaker := c.Breakers.Get("tts:" + speaker.Name())
+		if !breaker.Allow() {
+			logger.Info("Skipping TTS provider: circuit open", "provider", speaker.Name())
+			continue
+		}
+		_, span := tracing.Start(ctx, "tts.request", tracing.Provider.String("tts:"+speaker.Name()))
+		attemptCtx, cancel := context.WithCancel(ctx)
+		timer := time.AfterFunc(timeout, cancel)
+		emitted := false
+		err := speaker.SpeakContext(attemptCtx, text, override, func(audioBase64 string) {
+			if !emitted {
+				emitted = true
+				timer.Stop()
+				span.AddEvent("first audio")
+				if c.OnFirstAudio != nil {
+					c.OnFirstAudio()
+				}
+			}
+			c.send(ctx, audioBase64)
+		})
+		timer.Stop()
+		timedOut := attemptCtx.Err() != nil && ctx.Err() == nil
+		cancel()
+		if err == nil {
+			span.End()
+			breaker.Success()
+			c.send(ctx, EndOfUtterance)
+			return nil
+		}
+		if ctx.Err() != nil {
+			span.End()
+			return ctx.Err()
+		}
+		if timedOut && !emitted {
+			err = fmt.Errorf("no audio within %s", timeout)
+		}
+		tracing.Fail(span, err)
+		span.End()
+		logger.Warn("TTS provider failed", "provider", speaker.Name(), "error", err)
+		breaker.Failure(err)
+		if emitted {
+			// Close the partial utterance rather than repeat it in another voice
+			c.send(ctx, EndOfUtterance)
+			return err
+		}
+	}
+	return ErrAllProvidersFailed
+}
+
+// send queues audio for output unless ctx ends first.
+func (c *Chain) send(ctx context.Context, payload string) {
+	select {
+	case c.OutputDeviceChannel <- payload:
+	case <-ctx.Done():
+	}
+}
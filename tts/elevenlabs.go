@@ -2,56 +2,224 @@ package tts
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+
+	"github.com/mrsingh-rishi/voice-bot/audio"
 )
 
 const EndOfUtterance = "__END_OF_UTTERANCE__"
 
+const (
+	elevenLabsProvider = "elevenlabs"
+
+	DefaultElevenLabsBaseURL = "https://api.elevenlabs.io"
+)
+
 type ElevenLabsClient struct {
+	// BaseURL is the API root; tests point it at a local fake server
+	BaseURL string
 	APIKey string
 	VoiceId string
 	ModelId string
+	// Settings are the agent's voice settings; GenerateSpeechWith can override them per utterance
+	Settings VoiceSettings
 	OutputDeviceChannel chan <- string
+	// Cache serves warmed phrases without calling ElevenLabs; nil disables it.
+	// Only Synthesize fills it, so replies to callers are never stored.
+	Cache *PhraseCache
 }
 
 func NewElevenLabsClient(apiKey string, voiceId string, modelId string, outputDeviceChannel chan <- string) (*ElevenLabsClient, error) {
 
 	return &ElevenLabsClient{
+		BaseURL: DefaultElevenLabsBaseURL,
 		APIKey: apiKey,
 		VoiceId: voiceId,
 		ModelId: modelId,
+		Settings: DefaultVoiceSettings(modelId),
 		OutputDeviceChannel: outputDeviceChannel,
 	}, nil
 }
 
 func (client *ElevenLabsClient) GenerateSpeech(text string) (error) {
-	
+	return client.GenerateSpeechWith(text, nil)
+}
+
+// GenerateSpeechWith speaks text with the client's settings merged with
+// override, so a single utterance can change its delivery.
+func (client *ElevenLabsClient) GenerateSpeechWith(text string, override *VoiceSettings) error {
+	return client.GenerateSpeechContext(context.Background(), text, override)
+}
+
+// GenerateSpeechContext is GenerateSpeechWith that stops queueing audio once
+// ctx ends, e.g. when the call hangs up.
+func (client *ElevenLabsClient) GenerateSpeechContext(ctx context.Context, text string, override *VoiceSettings) error {
+	err := client.speak(ctx, text, client.Settings.Merge(override), false, func(audioBase64 string) {
+		// Send the audioBase64 to the output device channel
+		select {
+		case client.OutputDeviceChannel <- audioBase64:
+		case <-ctx.Done():
+		}
+	})
+	if err != nil {
+		return err
+	}
+	// 6️⃣ Send end-of-utterance signal
+	// This is a sentinel value to indicate the end of the utterance
+	select {
+	case client.OutputDeviceChannel <- EndOfUtterance:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+// Synthesize returns the whole utterance as base64 mu-law chunks instead of
+// streaming them to the output device, for clips that are played later. The
+// audio is kept in the phrase cache, so text must not be specific to a call.
+func (client *ElevenLabsClient) Synthesize(text string) ([]string, error) {
+	var chunks []string
+	err := client.speak(context.Background(), text, client.Settings, true, func(audioBase64 string) {
+		chunks = append(chunks, audioBase64)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return chunks, nil
+}
+
+// Prewarm synthesizes every phrase that is not cached yet, so greetings,
+// confirmations and voicemail drops play instantly on the first call.
+func (client *ElevenLabsClient) Prewarm(phrases []string) error {
+	if client.Cache == nil {
+		return fmt.Errorf("phrase cache is not configured")
+	}
+	for _, phrase := range phrases {
+		if phrase == "" || client.Cached(phrase, nil) {
+			continue
+		}
+		if _, err := client.Synthesize(phrase); err != nil {
+			return fmt.Errorf("prewarm %q: %w", phrase, err)
+		}
+	}
+	return nil
+}
+
+// Cached reports whether text would be served from the phrase cache.
+func (client *ElevenLabsClient) Cached(text string, override *VoiceSettings) bool {
+	return client.Cache.Contains(client.cacheKey(text, client.Settings.Merge(override)))
+}
+
+func (client *ElevenLabsClient) cacheKey(text string, settings VoiceSettings) CacheKey {
+	return CacheKey{
+		Provider: elevenLabsProvider,
+		Voice:    client.VoiceId,
+		Model:    settings.ModelId,
+		Settings: settings.canonical(),
+		Text:     text,
+	}
+}
+
+// Name identifies ElevenLabs in fallback chains.
+func (client *ElevenLabsClient) Name() string {
+	return elevenLabsProvider
+}
+
+// SpeakContext implements Speaker.
+func (client *ElevenLabsClient) SpeakContext(ctx context.Context, text string, override *VoiceSettings, emit func(audioBase64 string)) error {
+	return client.speak(ctx, text, client.Settings.Merge(override), false, emit)
+}
+
+// speak serves text from the phrase cache when possible, emitting every frame
+// at once. Otherwise it streams from ElevenLabs, caching the result if store
+// is set.
+func (client *ElevenLabsClient) speak(ctx context.Context, text string, settings VoiceSettings, store bool, emit func(audioBase64 string)) error {
+	key := client.cacheKey(text, settings)
+	// A call's own replies are never stored, so they are only looked up when
+	// warmed; counting each of them as a miss would bury the hit ratio.
+	// Cached audio is already in the telephony format.
+	if store || client.Cache.Contains(key) {
+		if cached, ok := client.Cache.Get(key); ok {
+			for _, frame := range audio.Frames(cached, audio.TwilioFrameBytes) {
+				emit(base64.StdEncoding.EncodeToString(frame))
+			}
+			return nil
+		}
+	}
+
+	transcoder, err := settings.telephonyTranscoder()
+	if err != nil {
+		return err
+	}
+	cache := client.Cache
+	if !store {
+		cache = nil
+	}
+	var speech []byte
+	err = client.streamSpeech(ctx, text, settings, func(audioBase64 string) {
+		if transcoder == nil && cache == nil {
+			emit(audioBase64)
+			return
+		}
+		decoded, err := base64.StdEncoding.DecodeString(audioBase64)
+		if err != nil {
+			return
+		}
+		if transcoder != nil {
+			decoded = transcoder.Process(decoded)
+			if len(decoded) == 0 {
+				return
+			}
+			audioBase64 = base64.StdEncoding.EncodeToString(decoded)
+		}
+		emit(audioBase64)
+		if cache != nil {
+			speech = append(speech, decoded...)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	cache.Put(key, speech)
+	return nil
+}
+
+// streamSpeech calls the ElevenLabs streaming endpoint and hands every audio
+// chunk to emit as soon as it is decoded.
+func (client *ElevenLabsClient) streamSpeech(ctx context.Context, text string, settings VoiceSettings, emit func(audioBase64 string)) error {
 	base, _ := url.Parse(
-        fmt.Sprintf("https://api.elevenlabs.io/v1/text-to-speech/%s/stream/with-timestamps", client.VoiceId),
+        fmt.Sprintf("%s/v1/text-to-speech/%s/stream/with-timestamps", client.BaseURL, client.VoiceId),
     )
 
 	q := base.Query()
-    q.Set("output_format", "ulaw_8000")
+	for k, v := range settings.queryParams() {
+		q.Set(k, v)
+	}
 	base.RawQuery = q.Encode()
 	// 2️⃣ Prepare JSON payload
 	payload := map[string]interface{}{
-		"text":     text,
-		"model_id": "eleven_multilingual_v2",
-		"voice_settings": map[string]float64{
-			"stability":        0.75,
-			"similarity_boost": 0.7,
-		},
+		"text":           text,
+		"model_id":       settings.ModelId,
+		"voice_settings": settings.voiceSettingsPayload(),
+	}
+	if settings.LanguageCode != "" {
+		payload["language_code"] = settings.LanguageCode
+	}
+	if settings.Seed != nil {
+		payload["seed"] = *settings.Seed
 	}
 	bodyBytes, err := json.Marshal(payload)
     if err != nil {
         return fmt.Errorf("❌ marshal payload: %w", err)
     }
 
-	req, err := http.NewRequest("POST", base.String(), bytes.NewReader(bodyBytes))
+	req, err := http.NewRequestWithContext(ctx, "POST", base.String(), bytes.NewReader(bodyBytes))
     if err != nil {
         return fmt.Errorf("❌ build request: %w", err)
     }
@@ -91,12 +259,11 @@ func (client *ElevenLabsClient) GenerateSpeech(text string) (error) {
 
         // 3️⃣ Use the extracted audio_base64
         audioBase64 := chunk.AudioBase64
+		if audioBase64 == "" {
+			continue
+		}
 
-		// Send the audioBase64 to the output device channel
-		client.OutputDeviceChannel <- audioBase64
+		emit(audioBase64)
     }
-	// 6️⃣ Send end-of-utterance signal
-	// This is a sentinel value to indicate the end of the utterance
-	client.OutputDeviceChannel <- EndOfUtterance
 	return nil
 }
\ No newline at end of file
@@ -0,0 +1,323 @@
+package tts
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"unicode"
+
+	gws "github.com/gorilla/websocket"
+	"github.com/mrsingh-rishi/voice-bot/audio"
+	"github.com/mrsingh-rishi/voice-bot/logging"
+)
+
+// Alignment maps a chunk of synthesized audio back to the characters it speaks.
+type Alignment struct {
+	Chars            []string `json:"chars"`
+	CharStartTimesMs []int    `json:"charStartTimesMs"`
+	CharDurationsMs  []int    `json:"charDurationsMs"`
+}
+
+// streamInputMessage is one frame received on the stream-input websocket.
+type streamInputMessage struct {
+	Audio     string     `json:"audio"`
+	IsFinal   *bool      `json:"isFinal"`
+	Alignment *Alignment `json:"alignment"`
+	Message   string     `json:"message"`
+	Error     string     `json:"error"`
+}
+
+type pendingText struct {
+	text  string
+	chars int // letters and digits still waiting for audio
+}
+
+// ElevenLabsStream is a persistent stream-input websocket used for a whole
+// call. Text is pushed as soon as each sentence is ready and audio is read
+// back continuously, saving the HTTP and TLS setup that GenerateSpeech pays
+// per sentence. Alignment data tells us where each sentence's audio ends, so
+// an end-of-utterance sentinel still follows every sentence.
+type ElevenLabsStream struct {
+	conn                *gws.Conn
+	OutputDeviceChannel chan<- string
+	transcoder          *audio.Transcoder
+	// OnAlignment, if set, receives the alignment of every audio chunk
+	OnAlignment func(Alignment)
+	// OnAudio, if set, is called as each audio chunk arrives, before it is
+	// queued for output
+	OnAudio func()
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	pending []pendingText
+	idle    chan struct{} // closed whenever nothing is pending
+	done    chan struct{}
+	err     error
+	// closed stops audio from being queued once Close is called
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// OpenStream dials the stream-input endpoint for the client's voice. Voice
+// settings are fixed for the life of the stream.
+func (client *ElevenLabsClient) OpenStream() (*ElevenLabsStream, error) {
+	settings := client.Settings
+	transcoder, err := settings.telephonyTranscoder()
+	if err != nil {
+		return nil, err
+	}
+	base, err := url.Parse(fmt.Sprintf("%s/v1/text-to-speech/%s/stream-input", client.BaseURL, client.VoiceId))
+	if err != nil {
+		return nil, fmt.Errorf("❌ stream url: %w", err)
+	}
+	switch base.Scheme {
+	case "https":
+		base.Scheme = "wss"
+	case "http":
+		base.Scheme = "ws"
+	}
+	q := base.Query()
+	for k, v := range settings.queryParams() {
+		q.Set(k, v)
+	}
+	q.Set("model_id", settings.ModelId)
+	if settings.LanguageCode != "" {
+		q.Set("language_code", settings.LanguageCode)
+	}
+	q.Set("sync_alignment", "true")
+	q.Set("inactivity_timeout", "180")
+	base.RawQuery = q.Encode()
+
+	conn, _, err := gws.DefaultDialer.Dial(base.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("❌ stream dial: %w", err)
+	}
+	stream := &ElevenLabsStream{
+		conn:                conn,
+		OutputDeviceChannel: client.OutputDeviceChannel,
+		transcoder:          transcoder,
+		idle:                make(chan struct{}),
+		done:                make(chan struct{}),
+		closed:              make(chan struct{}),
+	}
+	close(stream.idle)
+
+	// The first message carries auth and settings and must contain a single space
+	init := map[string]interface{}{
+		"text":           " ",
+		"xi_api_key":     client.APIKey,
+		"voice_settings": settings.voiceSettingsPayload(),
+	}
+	if err := stream.writeJSON(init); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("❌ stream init: %w", err)
+	}
+	go stream.readLoop()
+	logging.Logger("tts").Debug("Opened ElevenLabs stream", "voice", client.VoiceId)
+	return stream, nil
+}
+
+// SendText queues one sentence and asks ElevenLabs to synthesize it right away.
+func (s *ElevenLabsStream) SendText(text string) error {
+	chars := countSpoken(text)
+	if chars == 0 {
+		return nil
+	}
+	select {
+	case <-s.done:
+		return fmt.Errorf("stream closed: %w", s.Err())
+	default:
+	}
+	// Hold mu across the write so the read loop cannot credit audio for this
+	// sentence before it is queued
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.writeJSON(map[string]interface{}{
+		"text":  text + " ",
+		"flush": true,
+	})
+	if err != nil {
+		return err
+	}
+	if len(s.pending) == 0 {
+		s.idle = make(chan struct{})
+	}
+	s.pending = append(s.pending, pendingText{text: text, chars: chars})
+	return nil
+}
+
+// Idle reports whether every sentence sent so far has been fully played out.
+func (s *ElevenLabsStream) Idle() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending) == 0
+}
+
+// IdleChan is closed once every sentence sent so far has been fully played out.
+func (s *ElevenLabsStream) IdleChan() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.idle
+}
+
+// Done is closed when the websocket stops delivering audio.
+func (s *ElevenLabsStream) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *ElevenLabsStream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Unspoken returns the sentences whose audio never fully arrived, so the
+// caller can replay them over HTTP.
+func (s *ElevenLabsStream) Unspoken() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	texts := make([]string, 0, len(s.pending))
+	for _, p := range s.pending {
+		texts = append(texts, p.text)
+	}
+	s.clearPending()
+	return texts
+}
+
+// Close ends the input stream and tears down the socket.
+// Audio still arriving is dropped.
+func (s *ElevenLabsStream) Close() error {
+	s.closeOnce.Do(func() { close(s.closed) })
+	// An empty text tells ElevenLabs the input is finished
+	s.writeJSON(map[string]string{"text": ""})
+	return s.conn.Close()
+}
+
+// send queues audio for output unless the stream is closed first.
+func (s *ElevenLabsStream) send(payload string) {
+	select {
+	case s.OutputDeviceChannel <- payload:
+	case <-s.closed:
+	}
+}
+
+func (s *ElevenLabsStream) writeJSON(v interface{}) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.conn.WriteJSON(v)
+}
+
+func (s *ElevenLabsStream) readLoop() {
+	defer close(s.done)
+	for {
+		var msg streamInputMessage
+		if err := s.conn.ReadJSON(&msg); err != nil {
+			if !gws.IsCloseError(err, gws.CloseNormalClosure) {
+				s.fail(err)
+			}
+			return
+		}
+		if msg.Error != "" {
+			s.fail(fmt.Errorf("%s: %s", msg.Error, msg.Message))
+			return
+		}
+		if chunk := s.toTelephony(msg.Audio); chunk != "" {
+			if s.OnAudio != nil {
+				s.OnAudio()
+			}
+			s.send(chunk)
+		}
+		if msg.Alignment != nil {
+			if s.OnAlignment != nil {
+				s.OnAlignment(*msg.Alignment)
+			}
+			s.advance(msg.Alignment.Chars)
+		}
+		if msg.IsFinal != nil && *msg.IsFinal {
+			s.finishAll()
+		}
+	}
+}
+
+// toTelephony converts a base64 chunk in the stream's output format into
+// base64 8 kHz mu-law.
+func (s *ElevenLabsStream) toTelephony(audioBase64 string) string {
+	if s.transcoder == nil || audioBase64 == "" {
+		return audioBase64
+	}
+	decoded, err := base64.StdEncoding.DecodeString(audioBase64)
+	if err != nil {
+		return ""
+	}
+	converted := s.transcoder.Process(decoded)
+	if len(converted) == 0 {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(converted)
+}
+
+// advance credits spoken characters to the oldest pending sentences and
+// emits an end-of-utterance for every sentence that is now complete.
+func (s *ElevenLabsStream) advance(chars []string) {
+	spoken := countSpoken(strings.Join(chars, ""))
+	s.mu.Lock()
+	completed := 0
+	for spoken > 0 && len(s.pending) > 0 {
+		head := &s.pending[0]
+		if spoken < head.chars {
+			head.chars -= spoken
+			break
+		}
+		spoken -= head.chars
+		s.pending = s.pending[1:]
+		completed++
+	}
+	if completed > 0 && len(s.pending) == 0 {
+		s.clearPending()
+	}
+	s.mu.Unlock()
+	for i := 0; i < completed; i++ {
+		s.send(EndOfUtterance)
+	}
+}
+
+func (s *ElevenLabsStream) finishAll() {
+	s.mu.Lock()
+	completed := len(s.pending)
+	s.clearPending()
+	s.mu.Unlock()
+	for i := 0; i < completed; i++ {
+		s.send(EndOfUtterance)
+	}
+}
+
+// clearPending drops every pending sentence and wakes idle waiters. Callers hold mu.
+func (s *ElevenLabsStream) clearPending() {
+	s.pending = nil
+	select {
+	case <-s.idle:
+	default:
+		close(s.idle)
+	}
+}
+
+func (s *ElevenLabsStream) fail(err error) {
+	logging.Logger("tts").Error("ElevenLabs stream error", "error", err)
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+// countSpoken counts letters and digits, which survive ElevenLabs' own text
+// normalization better than whitespace and punctuation do.
+func countSpoken(text string) int {
+	n := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,164 @@
+package tts
+
+import (
+	"encoding/base64"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mrsingh-rishi/voice-bot/tts/ttsfake"
+)
+
+// streamTimeout bounds every wait on the fake server.
+const streamTimeout = 5 * time.Second
+
+func newFakeClient(t *testing.T, apiKey string, output chan string) (*ElevenLabsClient, *ttsfake.Server) {
+	t.Helper()
+	fake := ttsfake.NewServer()
+	t.Cleanup(fake.Close)
+	client, err := NewElevenLabsClient(apiKey, "voice", "", output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.BaseURL = fake.URL
+	return client, fake
+}
+
+// collectUtterances reads the output channel until n end-of-utterance
+// sentinels arrived and returns the audio bytes of each utterance.
+func collectUtterances(t *testing.T, output <-chan string, n int) []int {
+	t.Helper()
+	var sizes []int
+	size := 0
+	for len(sizes) < n {
+		select {
+		case payload := <-output:
+			if payload == EndOfUtterance {
+				sizes = append(sizes, size)
+				size = 0
+				continue
+			}
+			chunk, err := base64.StdEncoding.DecodeString(payload)
+			if err != nil {
+				t.Fatalf("invalid audio chunk: %v", err)
+			}
+			size += len(chunk)
+		case <-time.After(streamTimeout):
+			t.Fatalf("got %d of %d utterances", len(sizes), n)
+		}
+	}
+	return sizes
+}
+
+func TestStreamSpeaksEachSentence(t *testing.T) {
+	output := make(chan string, 64)
+	client, fake := newFakeClient(t, "key", output)
+	stream, err := client.OpenStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	var audioChunks atomic.Int32
+	stream.OnAudio = func() { audioChunks.Add(1) }
+
+	sentences := []string{"Hello there.", "How can I help you today?"}
+	for _, text := range sentences {
+		if err := stream.SendText(text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sizes := collectUtterances(t, output, len(sentences))
+	for i, text := range sentences {
+		// The fake speaks 60 ms of 8 kHz audio per character
+		if want := countSpoken(text) * 60 * 8; sizes[i] < want {
+			t.Errorf("sentence %d got %d bytes of audio, want at least %d", i+1, sizes[i], want)
+		}
+	}
+	select {
+	case <-stream.IdleChan():
+	case <-time.After(streamTimeout):
+		t.Error("stream not idle after every sentence was spoken")
+	}
+	if got := fake.Texts(); !slices.Equal(got, []string{"Hello there. ", "How can I help you today? "}) {
+		t.Errorf("fake was sent %q", got)
+	}
+	if audioChunks.Load() == 0 {
+		t.Error("OnAudio never called")
+	}
+	if unspoken := stream.Unspoken(); len(unspoken) != 0 {
+		t.Errorf("Unspoken() = %q after every sentence was spoken", unspoken)
+	}
+}
+
+func TestStreamSkipsUnspeakableText(t *testing.T) {
+	output := make(chan string, 8)
+	client, fake := newFakeClient(t, "key", output)
+	stream, err := client.OpenStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	if err := stream.SendText("..."); err != nil {
+		t.Fatal(err)
+	}
+	if !stream.Idle() {
+		t.Error("punctuation alone left the stream waiting for audio")
+	}
+	if got := fake.Texts(); len(got) != 0 {
+		t.Errorf("fake was sent %q", got)
+	}
+}
+
+func TestStreamCloseUnblocksOutput(t *testing.T) {
+	// Nobody reads the output, as after a call has hung up
+	output := make(chan string)
+	client, _ := newFakeClient(t, "key", output)
+	stream, err := client.OpenStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.SendText("This sentence is never played."); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	stream.Close()
+	select {
+	case <-stream.Done():
+	case <-time.After(streamTimeout):
+		t.Fatal("read loop still blocked on the output after Close")
+	}
+	if unspoken := stream.Unspoken(); !slices.Equal(unspoken, []string{"This sentence is never played."}) {
+		t.Errorf("Unspoken() = %q", unspoken)
+	}
+}
+
+func TestStreamAuthError(t *testing.T) {
+	output := make(chan string, 8)
+	client, _ := newFakeClient(t, "", output)
+	stream, err := client.OpenStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	select {
+	case <-stream.Done():
+	case <-time.After(streamTimeout):
+		t.Fatal("stream still open after an auth error")
+	}
+	if stream.Err() == nil {
+		t.Error("Err() is nil after an auth error")
+	}
+	if err := stream.SendText("Hello."); err == nil {
+		t.Error("SendText succeeded on a failed stream")
+	}
+}
+
+func TestStreamUnavailable(t *testing.T) {
+	client, fake := newFakeClient(t, "key", make(chan string))
+	fake.FailStream = true
+	if _, err := client.OpenStream(); err == nil {
+		t.Error("OpenStream succeeded while the endpoint rejects connections")
+	}
+}
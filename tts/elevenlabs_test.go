@@ -0,0 +1,43 @@
+package tts
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestSpeakCountsOnlyCacheableLookups(t *testing.T) {
+	client, fake := newFakeClient(t, "key", make(chan string))
+	cache, err := NewPhraseCache(1<<20, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cache = cache
+	speak := func(text string) {
+		t.Helper()
+		chunks := 0
+		if err := client.SpeakContext(context.Background(), text, nil, func(string) { chunks++ }); err != nil {
+			t.Fatal(err)
+		}
+		if chunks == 0 {
+			t.Fatalf("no audio for %q", text)
+		}
+	}
+
+	if err := client.Prewarm([]string{"Thanks for calling."}); err != nil {
+		t.Fatal(err)
+	}
+	speak("Your order ships on Monday.")
+	speak("Thanks for calling.")
+	speak("Your order ships on Monday.")
+
+	stats := cache.Stats()
+	// Prewarming is the only miss; the live reply is never looked up
+	if stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("hits %d, misses %d, want 1 and 1", stats.Hits, stats.Misses)
+	}
+	want := []string{"Thanks for calling.", "Your order ships on Monday.", "Your order ships on Monday."}
+	if got := fake.Texts(); !slices.Equal(got, want) {
+		t.Errorf("fake was sent %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,68 @@
+package tts
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/mrsingh-rishi/voice-bot/logging"
+)
+
+// DefaultFillerWords are the short acknowledgements that can be played while
+// the agent is still working out its real answer.
+var DefaultFillerWords = []string{"hmm", "okay", "right", "sure", "so", "well", "let me see"}
+
+// FillerCache holds pre-synthesized filler clips per voice so that playing a
+// filler never waits on a TTS request.
+type FillerCache struct {
+	Words []string
+
+	mu    sync.RWMutex
+	clips map[string]map[string][]string // voiceId -> word -> base64 mu-law chunks
+}
+
+func NewFillerCache(words []string) *FillerCache {
+	if len(words) == 0 {
+		words = DefaultFillerWords
+	}
+	return &FillerCache{
+		Words: words,
+		clips: make(map[string]map[string][]string),
+	}
+}
+
+// Warm synthesizes every filler word with the client's voice. Voices that are
+// already cached are skipped.
+func (c *FillerCache) Warm(client *ElevenLabsClient) error {
+	c.mu.RLock()
+	_, done := c.clips[client.VoiceId]
+	c.mu.RUnlock()
+	if done {
+		return nil
+	}
+
+	clips := make(map[string][]string, len(c.Words))
+	for _, word := range c.Words {
+		chunks, err := client.Synthesize(word)
+		if err != nil {
+			return fmt.Errorf("synthesize filler %q: %w", word, err)
+		}
+		clips[word] = chunks
+	}
+
+	c.mu.Lock()
+	c.clips[client.VoiceId] = clips
+	c.mu.Unlock()
+	logging.Logger("tts").Info("Cached filler clips", "voice", client.VoiceId, "count", len(clips))
+	return nil
+}
+
+// Clip returns the cached audio for word in the given voice.
+func (c *FillerCache) Clip(voiceId string, word string) ([]string, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	chunks, ok := c.clips[voiceId][word]
+	return chunks, ok && len(chunks) > 0
+}
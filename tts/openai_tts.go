@@ -0,0 +1,108 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/mrsingh-rishi/voice-bot/audio"
+)
+
+const (
+	DefaultOpenAIBaseURL  = "https://api.openai.com/v1"
+	DefaultOpenAITTSModel = "gpt-4o-mini-tts"
+	DefaultOpenAITTSVoice = "alloy"
+)
+
+// openAIPCMFormat is what the speech endpoint returns for response_format "pcm".
+var openAIPCMFormat = audio.Format{Codec: audio.CodecPCM16, SampleRate: 24000}
+
+// OpenAITTSClient speaks through an OpenAI-compatible /audio/speech endpoint.
+// It is meant as a fallback voice, so ElevenLabs voice settings are ignored.
+type OpenAITTSClient struct {
+	BaseURL string
+	APIKey  string
+	Model   string
+	Voice   string
+}
+
+func NewOpenAITTSClient(apiKey string, baseURL string, model string, voice string) (*OpenAITTSClient, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("API key is required")
+	}
+	if baseURL == "" {
+		baseURL = DefaultOpenAIBaseURL
+	}
+	if model == "" {
+		model = DefaultOpenAITTSModel
+	}
+	if voice == "" {
+		voice = DefaultOpenAITTSVoice
+	}
+	return &OpenAITTSClient{
+		BaseURL: baseURL,
+		APIKey:  apiKey,
+		Model:   model,
+		Voice:   voice,
+	}, nil
+}
+
+// Name identifies the provider in fallback chains.
+func (client *OpenAITTSClient) Name() string {
+	return fmt.Sprintf("%s/%s/%s", client.BaseURL, client.Model, client.Voice)
+}
+
+// SpeakContext streams raw PCM from the speech endpoint and emits it as
+// telephony frames.
+func (client *OpenAITTSClient) SpeakContext(ctx context.Context, text string, _ *VoiceSettings, emit func(audioBase64 string)) error {
+	body, err := json.Marshal(map[string]string{
+		"model":           client.Model,
+		"voice":           client.Voice,
+		"input":           text,
+		"response_format": "pcm",
+	})
+	if err != nil {
+		return fmt.Errorf("❌ marshal payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", client.BaseURL+"/audio/speech", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("❌ build request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+client.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("❌ HTTP request error: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("❌ bad status: %s", resp.Status)
+	}
+
+	transcoder := audio.NewTranscoder(openAIPCMFormat, audio.Telephony)
+	framer := audio.NewFramer(audio.TwilioFrameBytes)
+	buf := make([]byte, 4800)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			for _, frame := range framer.Push(transcoder.Process(buf[:n])) {
+				emit(base64.StdEncoding.EncodeToString(frame))
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("❌ read audio: %w", err)
+		}
+	}
+	if rest := framer.Flush(); len(rest) > 0 {
+		emit(base64.StdEncoding.EncodeToString(rest))
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+// Package ttsfake is a local stand-in for the ElevenLabs API. It serves both
+// the HTTP streaming endpoint and the stream-input websocket, returning
+// silent mu-law audio sized to the text, so TTS code paths can be exercised
+// without an account.
+package ttsfake
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"unicode"
+
+	gws "github.com/gorilla/websocket"
+	"github.com/mrsingh-rishi/voice-bot/logging"
+)
+
+const (
+	// msPerChar is how much audio the fake produces per spoken character
+	msPerChar = 60
+	// bytesPerMs is 8 kHz mu-law
+	bytesPerMs   = 8
+	chunkBytes   = 640
+	muLawSilence = 0xFF
+)
+
+type alignment struct {
+	Chars            []string `json:"chars"`
+	CharStartTimesMs []int    `json:"charStartTimesMs"`
+	CharDurationsMs  []int    `json:"charDurationsMs"`
+}
+
+// Server is a running fake ElevenLabs API. Point ElevenLabsClient.BaseURL at URL.
+type Server struct {
+	URL string
+
+	server   *httptest.Server
+	upgrader gws.Upgrader
+
+	mu    sync.Mutex
+	texts []string
+	// FailStream makes the websocket endpoint reject connections, to exercise
+	// the HTTP fallback
+	FailStream bool
+}
+
+func NewServer() *Server {
+	s := &Server{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/text-to-speech/{voice}/stream/with-timestamps", s.handleHTTP)
+	mux.HandleFunc("GET /v1/text-to-speech/{voice}/stream-input", s.handleStream)
+	s.server = httptest.NewServer(mux)
+	s.URL = s.server.URL
+	return s
+}
+
+// Texts returns every text the fake was asked to synthesize, in order.
+func (s *Server) Texts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.texts...)
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+func (s *Server) record(text string) {
+	s.mu.Lock()
+	s.texts = append(s.texts, text)
+	s.mu.Unlock()
+}
+
+func (s *Server) handleHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("xi-api-key") == "" {
+		http.Error(w, "missing xi-api-key", http.StatusUnauthorized)
+		return
+	}
+	var body struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.record(body.Text)
+
+	enc := json.NewEncoder(w)
+	for _, chunk := range synthesize(body.Text) {
+		enc.Encode(map[string]interface{}{
+			"audio_base64": chunk.audio,
+			"alignment":    chunk.alignment,
+		})
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+}
+
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	if s.FailStream {
+		http.Error(w, "stream disabled", http.StatusServiceUnavailable)
+		return
+	}
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	authenticated := false
+	for {
+		var msg struct {
+			Text   string `json:"text"`
+			APIKey string `json:"xi_api_key"`
+			Flush  bool   `json:"flush"`
+		}
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		if !authenticated {
+			if msg.APIKey == "" {
+				conn.WriteJSON(map[string]string{"error": "auth_error", "message": "missing xi_api_key"})
+				return
+			}
+			authenticated = true
+			continue
+		}
+		if msg.Text == "" {
+			conn.WriteJSON(map[string]interface{}{"isFinal": true})
+			return
+		}
+		s.record(msg.Text)
+		for _, chunk := range synthesize(msg.Text) {
+			if err := conn.WriteJSON(map[string]interface{}{
+				"audio":     chunk.audio,
+				"alignment": chunk.alignment,
+				"isFinal":   nil,
+			}); err != nil {
+				logging.Logger("simulate").Warn("Fake ElevenLabs write error", "error", err)
+				return
+			}
+		}
+	}
+}
+
+type fakeChunk struct {
+	audio     string
+	alignment alignment
+}
+
+// synthesize produces msPerChar of silence for every character, split into
+// chunks that each carry the alignment of the characters they cover.
+func synthesize(text string) []fakeChunk {
+	charsPerChunk := chunkBytes / (msPerChar * bytesPerMs)
+	if charsPerChunk < 1 {
+		charsPerChunk = 1
+	}
+	runes := []rune(text)
+	var chunks []fakeChunk
+	for start := 0; start < len(runes); start += charsPerChunk {
+		end := min(start+charsPerChunk, len(runes))
+		var a alignment
+		audio := make([]byte, 0, (end-start)*msPerChar*bytesPerMs)
+		for i, r := range runes[start:end] {
+			a.Chars = append(a.Chars, string(r))
+			a.CharStartTimesMs = append(a.CharStartTimesMs, (start+i)*msPerChar)
+			a.CharDurationsMs = append(a.CharDurationsMs, msPerChar)
+			if unicode.IsSpace(r) {
+				continue
+			}
+			for j := 0; j < msPerChar*bytesPerMs; j++ {
+				audio = append(audio, muLawSilence)
+			}
+		}
+		chunks = append(chunks, fakeChunk{
+			audio:     base64.StdEncoding.EncodeToString(audio),
+			alignment: a,
+		})
+	}
+	return chunks
+}
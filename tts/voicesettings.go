@@ -0,0 +1,173 @@
+package tts
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mrsingh-rishi/voice-bot/audio"
+)
+
+const (
+	DefaultModelId      = "eleven_multilingual_v2"
+	DefaultOutputFormat = "ulaw_8000"
+)
+
+// VoiceSettings controls how ElevenLabs renders speech. Pointer fields are
+// optional so an override only changes what it sets; unset fields keep the
+// provider default.
+type VoiceSettings struct {
+	ModelId                  string   `json:"model_id,omitempty"`
+	Stability                *float64 `json:"stability,omitempty"`
+	SimilarityBoost          *float64 `json:"similarity_boost,omitempty"`
+	Style                    *float64 `json:"style,omitempty"`
+	UseSpeakerBoost          *bool    `json:"use_speaker_boost,omitempty"`
+	Speed                    *float64 `json:"speed,omitempty"`
+	OptimizeStreamingLatency *int     `json:"optimize_streaming_latency,omitempty"`
+	OutputFormat             string   `json:"output_format,omitempty"`
+	LanguageCode             string   `json:"language_code,omitempty"`
+	Seed                     *int     `json:"seed,omitempty"`
+}
+
+// DefaultVoiceSettings are the settings the bot has always used, with the
+// given model (or DefaultModelId when empty).
+func DefaultVoiceSettings(modelId string) VoiceSettings {
+	if modelId == "" {
+		modelId = DefaultModelId
+	}
+	stability, similarity := 0.75, 0.7
+	return VoiceSettings{
+		ModelId:         modelId,
+		Stability:       &stability,
+		SimilarityBoost: &similarity,
+		OutputFormat:    DefaultOutputFormat,
+	}
+}
+
+// Merge returns a copy of s with every field that override sets replaced.
+func (s VoiceSettings) Merge(override *VoiceSettings) VoiceSettings {
+	if override == nil {
+		return s
+	}
+	merged := s
+	if override.ModelId != "" {
+		merged.ModelId = override.ModelId
+	}
+	if override.Stability != nil {
+		merged.Stability = override.Stability
+	}
+	if override.SimilarityBoost != nil {
+		merged.SimilarityBoost = override.SimilarityBoost
+	}
+	if override.Style != nil {
+		merged.Style = override.Style
+	}
+	if override.UseSpeakerBoost != nil {
+		merged.UseSpeakerBoost = override.UseSpeakerBoost
+	}
+	if override.Speed != nil {
+		merged.Speed = override.Speed
+	}
+	if override.OptimizeStreamingLatency != nil {
+		merged.OptimizeStreamingLatency = override.OptimizeStreamingLatency
+	}
+	if override.OutputFormat != "" {
+		merged.OutputFormat = override.OutputFormat
+	}
+	if override.LanguageCode != "" {
+		merged.LanguageCode = override.LanguageCode
+	}
+	if override.Seed != nil {
+		merged.Seed = override.Seed
+	}
+	return merged
+}
+
+// voiceSettingsPayload is the "voice_settings" object of a synthesis request.
+func (s VoiceSettings) voiceSettingsPayload() map[string]interface{} {
+	payload := map[string]interface{}{}
+	if s.Stability != nil {
+		payload["stability"] = *s.Stability
+	}
+	if s.SimilarityBoost != nil {
+		payload["similarity_boost"] = *s.SimilarityBoost
+	}
+	if s.Style != nil {
+		payload["style"] = *s.Style
+	}
+	if s.UseSpeakerBoost != nil {
+		payload["use_speaker_boost"] = *s.UseSpeakerBoost
+	}
+	if s.Speed != nil {
+		payload["speed"] = *s.Speed
+	}
+	return payload
+}
+
+// queryParams are the settings ElevenLabs takes in the URL rather than the body.
+func (s VoiceSettings) queryParams() map[string]string {
+	params := map[string]string{"output_format": s.outputFormat()}
+	if s.OptimizeStreamingLatency != nil {
+		params["optimize_streaming_latency"] = strconv.Itoa(*s.OptimizeStreamingLatency)
+	}
+	return params
+}
+
+func (s VoiceSettings) outputFormat() string {
+	if s.OutputFormat == "" {
+		return DefaultOutputFormat
+	}
+	return s.OutputFormat
+}
+
+// canonical is a stable encoding of everything that changes the audio, used
+// in cache keys. Latency optimization does not change the audio and is left out.
+func (s VoiceSettings) canonical() string {
+	s.ModelId = ""
+	s.OptimizeStreamingLatency = nil
+	s.OutputFormat = s.outputFormat()
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+// directiveRe matches a leading delivery tag such as "[excited]".
+var directiveRe = regexp.MustCompile(`^\s*\[([A-Za-z_-]+)\]\s*`)
+
+// ParseDirective strips a leading "[preset]" tag from text and returns the
+// matching preset. Tags that are not presets are left in the text.
+func ParseDirective(text string, presets map[string]VoiceSettings) (string, *VoiceSettings) {
+	m := directiveRe.FindStringSubmatch(text)
+	if m == nil {
+		return text, nil
+	}
+	preset, ok := presets[strings.ToLower(m[1])]
+	if !ok {
+		return text, nil
+	}
+	return text[len(m[0]):], &preset
+}
+
+// Directive returns the name of the leading "[name]" tag of text, or "" when
+// there is none.
+func Directive(text string) string {
+	m := directiveRe.FindStringSubmatch(text)
+	if m == nil {
+		return ""
+	}
+	return strings.ToLower(m[1])
+}
+
+// telephonyTranscoder converts the provider's output format into the 8 kHz
+// mu-law the telephony transport plays. It is nil when no conversion is needed.
+func (s VoiceSettings) telephonyTranscoder() (*audio.Transcoder, error) {
+	format, err := audio.ParseFormat(s.outputFormat())
+	if err != nil {
+		return nil, fmt.Errorf("output format %q cannot be played on a call: %w", s.outputFormat(), err)
+	}
+	if format == audio.Telephony {
+		return nil, nil
+	}
+	return audio.NewTranscoder(format, audio.Telephony), nil
+}
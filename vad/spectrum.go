@@ -0,0 +1,80 @@
+package vad
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// spectrum computes the spectral flatness of frames with a windowed FFT.
+type spectrum struct {
+	size   int // FFT length, a power of two >= frame size
+	window []float64
+	buf    []complex128
+}
+
+func newSpectrum(frameSize int) *spectrum {
+	size := 1
+	for size < frameSize {
+		size <<= 1
+	}
+	window := make([]float64, frameSize)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(frameSize-1))
+	}
+	return &spectrum{
+		size:   size,
+		window: window,
+		buf:    make([]complex128, size),
+	}
+}
+
+// flatness is the ratio of the geometric to the arithmetic mean of the power
+// spectrum: near 1 for white noise, much lower for voiced speech.
+func (s *spectrum) flatness(frame []int16) float64 {
+	for i := range s.buf {
+		s.buf[i] = 0
+	}
+	for i, v := range frame {
+		s.buf[i] = complex(float64(v)/32768*s.window[i], 0)
+	}
+	fft(s.buf)
+
+	var logSum, sum float64
+	bins := s.size / 2
+	for i := 1; i <= bins; i++ {
+		p := real(s.buf[i])*real(s.buf[i]) + imag(s.buf[i])*imag(s.buf[i]) + 1e-12
+		logSum += math.Log(p)
+		sum += p
+	}
+	arith := sum / float64(bins)
+	geo := math.Exp(logSum / float64(bins))
+	return geo / arith
+}
+
+// fft is an in-place iterative radix-2 Cooley-Tukey transform.
+func fft(a []complex128) {
+	n := len(a)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			a[i], a[j] = a[j], a[i]
+		}
+	}
+	for length := 2; length <= n; length <<= 1 {
+		w := cmplx.Exp(complex(0, -2*math.Pi/float64(length)))
+		for i := 0; i < n; i += length {
+			wn := complex(1, 0)
+			for k := 0; k < length/2; k++ {
+				u := a[i+k]
+				v := a[i+k+length/2] * wn
+				a[i+k] = u + v
+				a[i+k+length/2] = u - v
+				wn *= w
+			}
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// Package vad detects speech in 16-bit mono audio without a network round
+// trip. Each 20 ms frame is classified using its energy relative to an
+// adaptive noise floor and its spectral flatness, which separates voiced
+// speech (harmonic, low flatness) from broadband noise (flat spectrum).
+package vad
+
+import (
+	"math"
+	"time"
+)
+
+type EventType int
+
+const (
+	SpeechStart EventType = iota
+	SpeechEnd
+)
+
+func (t EventType) String() string {
+	if t == SpeechStart {
+		return "speech_start"
+	}
+	return "speech_end"
+}
+
+// Event marks a transition between silence and speech. Offset is the
+// position in the audio stream where the transition was detected.
+type Event struct {
+	Type   EventType
+	Offset time.Duration
+}
+
+// Config tunes the detector. Zero values fall back to DefaultConfig.
+type Config struct {
+	SampleRate int `json:"sample_rate"`
+	// FrameMs is the analysis window.
+	FrameMs int `json:"frame_ms"`
+	// MinEnergyDB is the absolute level (dBFS) below which a frame is never speech.
+	MinEnergyDB float64 `json:"min_energy_db"`
+	// NoiseMarginDB is how far above the adaptive noise floor a frame must be.
+	NoiseMarginDB float64 `json:"noise_margin_db"`
+	// MaxFlatness rejects frames whose spectrum is too flat to be voice (0..1).
+	MaxFlatness float64 `json:"max_flatness"`
+	// StartFrames is how many consecutive speech frames trigger SpeechStart.
+	StartFrames int `json:"start_frames"`
+	// HangoverFrames is how many consecutive non-speech frames trigger SpeechEnd.
+	HangoverFrames int `json:"hangover_frames"`
+}
+
+// DefaultConfig suits 8 kHz telephony audio.
+func DefaultConfig() Config {
+	return Config{
+		SampleRate:     8000,
+		FrameMs:        20,
+		MinEnergyDB:    -45,
+		NoiseMarginDB:  10,
+		MaxFlatness:    0.5,
+		StartFrames:    5,
+		HangoverFrames: 20,
+	}
+}
+
+func (c Config) withDefaults() Config {
+	d := DefaultConfig()
+	if c.SampleRate <= 0 {
+		c.SampleRate = d.SampleRate
+	}
+	if c.FrameMs <= 0 {
+		c.FrameMs = d.FrameMs
+	}
+	if c.MinEnergyDB == 0 {
+		c.MinEnergyDB = d.MinEnergyDB
+	}
+	if c.NoiseMarginDB == 0 {
+		c.NoiseMarginDB = d.NoiseMarginDB
+	}
+	if c.MaxFlatness == 0 {
+		c.MaxFlatness = d.MaxFlatness
+	}
+	if c.StartFrames <= 0 {
+		c.StartFrames = d.StartFrames
+	}
+	if c.HangoverFrames <= 0 {
+		c.HangoverFrames = d.HangoverFrames
+	}
+	return c
+}
+
+// Detector is a streaming voice activity detector. It is not safe for
+// concurrent use.
+type Detector struct {
+	cfg        Config
+	frameSize  int
+	pending    []int16
+	frames     int64
+	speaking   bool
+	speechRun  int
+	silenceRun int
+	noiseFloor float64
+	spectrum   *spectrum
+}
+
+func New(cfg Config) *Detector {
+	cfg = cfg.withDefaults()
+	frameSize := cfg.SampleRate * cfg.FrameMs / 1000
+	return &Detector{
+		cfg:        cfg,
+		frameSize:  frameSize,
+		noiseFloor: cfg.MinEnergyDB - cfg.NoiseMarginDB,
+		spectrum:   newSpectrum(frameSize),
+	}
+}
+
+// Speaking reports whether the detector currently considers speech active.
+func (d *Detector) Speaking() bool {
+	return d.speaking
+}
+
+// Process consumes samples of any length and returns the transitions they caused.
+func (d *Detector) Process(samples []int16) []Event {
+	d.pending = append(d.pending, samples...)
+	var events []Event
+	for len(d.pending) >= d.frameSize {
+		frame := d.pending[:d.frameSize]
+		if ev, ok := d.processFrame(frame); ok {
+			events = append(events, ev)
+		}
+		d.pending = d.pending[d.frameSize:]
+	}
+	return events
+}
+
+func (d *Detector) processFrame(frame []int16) (Event, bool) {
+	d.frames++
+	energy := energyDB(frame)
+	isSpeech := energy > d.cfg.MinEnergyDB &&
+		energy > d.noiseFloor+d.cfg.NoiseMarginDB &&
+		d.spectrum.flatness(frame) < d.cfg.MaxFlatness
+
+	if !isSpeech {
+		// Track the noise floor quickly downward and slowly upward, so a
+		// burst of speech that was missed does not lift it much.
+		if energy < d.noiseFloor {
+			d.noiseFloor = 0.8*d.noiseFloor + 0.2*energy
+		} else {
+			d.noiseFloor = 0.99*d.noiseFloor + 0.01*energy
+		}
+	}
+
+	offset := time.Duration(d.frames*int64(d.cfg.FrameMs)) * time.Millisecond
+	if isSpeech {
+		d.speechRun++
+		d.silenceRun = 0
+		if !d.speaking && d.speechRun >= d.cfg.StartFrames {
+			d.speaking = true
+			return Event{Type: SpeechStart, Offset: offset}, true
+		}
+		return Event{}, false
+	}
+	d.silenceRun++
+	d.speechRun = 0
+	if d.speaking && d.silenceRun >= d.cfg.HangoverFrames {
+		d.speaking = false
+		return Event{Type: SpeechEnd, Offset: offset}, true
+	}
+	return Event{}, false
+}
+
+// energyDB returns the frame's RMS level in dB relative to full scale.
+func energyDB(frame []int16) float64 {
+	var sum float64
+	for _, s := range frame {
+		v := float64(s) / 32768
+		sum += v * v
+	}
+	rms := math.Sqrt(sum / float64(len(frame)))
+	if rms < 1e-9 {
+		return -180
+	}
+	return 20 * math.Log10(rms)
+}
@@ -2,9 +2,17 @@ package workers
 
 import (
 	"context"
-	"log"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
 
+	"github.com/mrsingh-rishi/voice-bot/fallback"
+	"github.com/mrsingh-rishi/voice-bot/logging"
+	"github.com/mrsingh-rishi/voice-bot/normalize"
+	"github.com/mrsingh-rishi/voice-bot/tracing"
 	"github.com/mrsingh-rishi/voice-bot/tts"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type AgentResponseWorker struct {
@@ -13,16 +21,58 @@ type AgentResponseWorker struct {
 	StreamingChannel    <-chan string
 	OutputDeviceChannel chan<- string
 	TTSClient           tts.ElevenLabsClient
+	// Normalizer rewrites agent text into speakable form before synthesis
+	Normalizer *normalize.Normalizer
+	// UseStreaming sends sentences over one persistent websocket instead of
+	// one HTTP request each; HTTP remains the fallback
+	UseStreaming bool
+	// stream is only touched by the Start goroutine, which closes it on Stop
+	stream     *tts.ElevenLabsStream
+	interrupts chan struct{}
+	// Presets are the delivery styles the agent can pick per sentence with a leading "[name]" tag
+	Presets map[string]tts.VoiceSettings
+	// Fallbacks are tried in order when ElevenLabs fails or sends no audio
+	// within TTSTimeout
+	Fallbacks  []tts.Speaker
+	Breakers   *fallback.Registry
+	TTSTimeout time.Duration
+	// FallbackMessage is said, from the phrase cache only, when every TTS
+	// provider failed
+	FallbackMessage string
+	lastApology     time.Time
+	// TurnContext, if set, decorates the context each sentence is
+	// synthesized in, e.g. with the turn's trace span
+	TurnContext func(ctx context.Context) context.Context
+	// OnFirstAudio, if set, is called when TTS produces the first audio of
+	// a sentence
+	OnFirstAudio func()
+	Log          *slog.Logger
+
+	voiceMu      sync.Mutex
+	pendingVoice *voiceChange
+}
+
+// apologyInterval keeps an outage from turning every sentence into an apology.
+const apologyInterval = 10 * time.Second
+
+// voiceChange is a switch of voice requested by SetVoice, applied before the
+// next sentence is synthesized.
+type voiceChange struct {
+	voiceId    string
+	settings   tts.VoiceSettings
+	normalizer *normalize.Normalizer
 }
 
-func NewAgentResponseWorker(apikey string, voiceId string, modelId string, streamingChannel <-chan string, outputDeviceChannel chan<- string) (*AgentResponseWorker, error) {
-	client, err := tts.NewElevenLabsClient(apikey, voiceId, modelId, outputDeviceChannel)
+func NewAgentResponseWorker(apikey string, voiceId string, settings tts.VoiceSettings, normalizer *normalize.Normalizer, cache *tts.PhraseCache, streamingChannel <-chan string, outputDeviceChannel chan<- string) (*AgentResponseWorker, error) {
+	client, err := tts.NewElevenLabsClient(apikey, voiceId, settings.ModelId, outputDeviceChannel)
 	if err != nil {
 		return nil, err
 	}
 	if client == nil {
 		return nil, err
 	}
+	client.Settings = settings
+	client.Cache = cache
 	ctx, cancel := context.WithCancel(context.Background())
 	agentResponseWorker := &AgentResponseWorker{
 		ctx:                 ctx,
@@ -30,28 +80,45 @@ func NewAgentResponseWorker(apikey string, voiceId string, modelId string, strea
 		StreamingChannel:    streamingChannel,
 		OutputDeviceChannel: outputDeviceChannel,
 		TTSClient:           *client,
+		Normalizer:          normalizer,
+		interrupts:          make(chan struct{}, 1),
+		Log:                 logging.Logger("tts"),
 	}
 	return agentResponseWorker, nil
 }
 
 func (w *AgentResponseWorker) Start() error {
-	log.Println("AgentResponseWorker started")
+	w.Log.Debug("AgentResponseWorker started")
 
 	go func() {
 		for {
 			select{
 				case <-w.ctx.Done():
-					log.Println("AgentResponseWorker context done, exiting...")
+					w.Log.Debug("AgentResponseWorker context done, exiting")
+					if w.stream != nil {
+						w.stream.Close()
+						w.stream = nil
+					}
 					return
+				case <-w.streamDone():
+					w.recoverStream()
+				case <-w.interrupts:
+					w.discardPending()
 				case response := <- w.StreamingChannel: 
 					if response == "" {
-						log.Println("Received empty response, skipping...")
+						w.Log.Debug("Received empty response, skipping")
+						continue
+					}
+					w.Log.Debug("Received response", "text", response)
+					w.applyVoiceChange()
+					text, override := tts.ParseDirective(response, w.Presets)
+					speakable := w.Normalizer.Normalize(text)
+					if speakable == "" {
 						continue
 					}
-					log.Printf("Received response: %s\n", response)
 					// Send the response to the TTS client
-					if err := w.TTSClient.GenerateSpeech(response); err != nil {
-						log.Printf("Error streaming response: %v\n", err)
+					if err := w.speakTraced(speakable, override); err != nil {
+						w.Log.Error("Error streaming response", "error", err)
 						continue
 					}
 					// Send the audio data to the output device channel	
@@ -62,7 +129,175 @@ func (w *AgentResponseWorker) Start() error {
 	return nil
 }
 
-// Stop signals Start() to exit.
+// speakTraced is speak within a span of the current turn.
+func (w *AgentResponseWorker) speakTraced(text string, override *tts.VoiceSettings) error {
+	ctx := w.ctx
+	if w.TurnContext != nil {
+		ctx = w.TurnContext(ctx)
+	}
+	ctx, span := tracing.Start(ctx, "tts.speak", attribute.Bool("tts.streaming", w.UseStreaming))
+	defer span.End()
+	err := w.speak(ctx, text, override)
+	tracing.Fail(span, err)
+	return err
+}
+
+// speak synthesizes one sentence, preferring the persistent stream when enabled.
+func (w *AgentResponseWorker) speak(ctx context.Context, text string, override *tts.VoiceSettings) error {
+	if !w.UseStreaming {
+		return w.speakHTTP(ctx, text, override)
+	}
+	// The stream's voice settings are fixed, so overridden sentences go over
+	// HTTP, as do cached phrases. Both wait for the stream to finish what it
+	// already has queued so audio stays in order.
+	if override != nil || w.TTSClient.Cached(text, nil) {
+		w.waitStreamIdle()
+		return w.speakHTTP(ctx, text, override)
+	}
+	if w.stream == nil {
+		_, span := tracing.Start(ctx, "tts.stream.open", tracing.Provider.String("tts:"+w.TTSClient.Name()))
+		stream, err := w.TTSClient.OpenStream()
+		tracing.Fail(span, err)
+		span.End()
+		if err != nil {
+			w.Log.Warn("Falling back to HTTP TTS", "error", err)
+			return w.speakHTTP(ctx, text, nil)
+		}
+		stream.OnAudio = w.OnFirstAudio
+		w.stream = stream
+	}
+	if err := w.stream.SendText(text); err != nil {
+		w.Log.Warn("Falling back to HTTP TTS", "error", err)
+		w.recoverStream()
+		return w.speakHTTP(ctx, text, nil)
+	}
+	return nil
+}
+
+// speakHTTP synthesizes one sentence through the fallback chain, ElevenLabs
+// first. When no provider can speak, the caller hears the fallback message
+// instead of silence.
+func (w *AgentResponseWorker) speakHTTP(ctx context.Context, text string, override *tts.VoiceSettings) error {
+	chain := tts.Chain{
+		Speakers:            append([]tts.Speaker{&w.TTSClient}, w.Fallbacks...),
+		Breakers:            w.Breakers,
+		Timeout:             w.TTSTimeout,
+		OutputDeviceChannel: w.OutputDeviceChannel,
+		OnFirstAudio:        w.OnFirstAudio,
+		Log:                 w.Log,
+	}
+	err := chain.Speak(ctx, text, override)
+	if errors.Is(err, tts.ErrAllProvidersFailed) {
+		w.apologize()
+	}
+	return err
+}
+
+func (w *AgentResponseWorker) apologize() {
+	message := w.Normalizer.Normalize(w.FallbackMessage)
+	if message == "" || time.Since(w.lastApology) < apologyInterval || !w.TTSClient.Cached(message, nil) {
+		return
+	}
+	w.lastApology = time.Now()
+	if err := w.TTSClient.GenerateSpeechContext(w.ctx, message, nil); err != nil {
+		w.Log.Error("Error playing fallback message", "error", err)
+	}
+}
+
+func (w *AgentResponseWorker) waitStreamIdle() {
+	if w.stream == nil {
+		return
+	}
+	select {
+	case <-w.stream.IdleChan():
+	case <-w.stream.Done():
+		w.recoverStream()
+	case <-w.ctx.Done():
+	}
+}
+
+// streamDone is nil while no stream is open, which disables its select case.
+func (w *AgentResponseWorker) streamDone() <-chan struct{} {
+	if w.stream == nil {
+		return nil
+	}
+	return w.stream.Done()
+}
+
+// recoverStream drops a dead stream and replays over HTTP whatever it did not
+// finish speaking. The next sentence opens a fresh stream.
+func (w *AgentResponseWorker) recoverStream() {
+	if w.stream == nil {
+		return
+	}
+	stream := w.stream
+	w.stream = nil
+	stream.Close()
+	for _, text := range stream.Unspoken() {
+		if err := w.speakHTTP(w.ctx, text, nil); err != nil {
+			w.Log.Error("Error replaying response over HTTP", "error", err)
+		}
+	}
+}
+
+// SetVoice changes the voice, voice settings and normalizer used from the
+// next sentence on, e.g. when the call switches language.
+func (w *AgentResponseWorker) SetVoice(voiceId string, settings tts.VoiceSettings, normalizer *normalize.Normalizer) {
+	w.voiceMu.Lock()
+	defer w.voiceMu.Unlock()
+	w.pendingVoice = &voiceChange{voiceId: voiceId, settings: settings, normalizer: normalizer}
+}
+
+// applyVoiceChange switches to the voice SetVoice asked for, if any. It runs
+// on the Start goroutine, between sentences.
+func (w *AgentResponseWorker) applyVoiceChange() {
+	w.voiceMu.Lock()
+	change := w.pendingVoice
+	w.pendingVoice = nil
+	w.voiceMu.Unlock()
+	if change == nil {
+		return
+	}
+	// A stream is bound to one voice; let it finish and open a new one
+	if w.stream != nil {
+		w.waitStreamIdle()
+		if w.stream != nil {
+			w.stream.Close()
+			w.stream = nil
+		}
+	}
+	w.TTSClient.VoiceId = change.voiceId
+	w.TTSClient.ModelId = change.settings.ModelId
+	w.TTSClient.Settings = change.settings
+	w.Normalizer = change.normalizer
+	w.Log.Debug("Switched voice", "voice", change.voiceId)
+}
+
+// Interrupt drops every sentence that has not been spoken yet.
+func (w *AgentResponseWorker) Interrupt() {
+	select {
+	case w.interrupts <- struct{}{}:
+	default:
+	}
+}
+
+func (w *AgentResponseWorker) discardPending() {
+	for {
+		select {
+		case <-w.StreamingChannel:
+		default:
+			if w.stream != nil {
+				// Whatever the stream still owes belongs to the interrupted reply
+				w.stream.Unspoken()
+				w.stream.Close()
+				w.stream = nil
+			}
+			return
+		}
+	}
+}
+
+// Stop signals Start() to exit; it closes the TTS stream on its way out.
 func (w *AgentResponseWorker) Stop() {
 	w.cancel()
 }
@@ -3,9 +3,13 @@ package workers
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"sync"
 
 	"github.com/mrsingh-rishi/voice-bot/llm"
+	"github.com/mrsingh-rishi/voice-bot/logging"
+	"github.com/mrsingh-rishi/voice-bot/redact"
+	"github.com/mrsingh-rishi/voice-bot/stt"
 )
 
 type AgentWorker struct {
@@ -13,11 +17,27 @@ type AgentWorker struct {
 	cancel                  context.CancelFunc
 	OpenAIClient            llm.OpenAIClient
 	AgentOutputChannel        chan<- string
-	AgentInputChannel       <-chan string
+	AgentInputChannel       <-chan stt.Transcript
+	turnMu                  sync.Mutex
+	turnCancel              context.CancelFunc // cancels the reply being generated
+	// ShouldReply, if set, is asked before a transcript starts a turn; the
+	// transcripts it turns down are dropped, e.g. while the call is ending
+	ShouldReply func(transcript stt.Transcript) bool
+	// OnTurnStart, if set, is called when a transcript is handed to the LLM
+	OnTurnStart func(transcript stt.Transcript)
+	// OnTurnEnd, if set, is called with the generated reply once the LLM is done
+	OnTurnEnd func(reply string)
+	// TurnContext, if set, decorates the context each reply is generated in,
+	// e.g. with the turn's trace span
+	TurnContext func(ctx context.Context) context.Context
+	// HidePIIFromModel masks personal data in transcripts before the LLM
+	// sees them
+	HidePIIFromModel bool
+	Log              *slog.Logger
 	// TODO: Add other fields like ActionChannel, FillerResponse Generator, ActionWorker, etc.
 }
 
-func NewAgentWorker(apikey string, model string, streamingChannel chan<- string, transcriptionChannel <-chan string) (*AgentWorker, error) {
+func NewAgentWorker(apikey string, model string, systemInstructions string, streamingChannel chan<- string, transcriptionChannel <-chan stt.Transcript) (*AgentWorker, error) {
 	// Params Validation
 	if apikey == "" {
 		return nil, fmt.Errorf("API key is required")
@@ -33,7 +53,7 @@ func NewAgentWorker(apikey string, model string, streamingChannel chan<- string,
 	}
 	
 	// Create OpenAI client and FillerResponseGenerator
-	client, err1 := llm.NewOpenAIClient(apikey, "You are a helpful assistant.", model, streamingChannel) // System instructions will be updated later
+	client, err1 := llm.NewOpenAIClient(apikey, systemInstructions, model, streamingChannel)
 	if err1 != nil {
 		return nil, err1
 	}
@@ -47,6 +67,7 @@ func NewAgentWorker(apikey string, model string, streamingChannel chan<- string,
 		OpenAIClient:            *client,
 		AgentOutputChannel:        streamingChannel,
 		AgentInputChannel:       transcriptionChannel,
+		Log:                     logging.Logger("llm"),
 	}
 
 	return agentWorker, nil
@@ -54,12 +75,11 @@ func NewAgentWorker(apikey string, model string, streamingChannel chan<- string,
 
 func (aw *AgentWorker) Start() {
 	go func() {
-		log.Printf("Streaming channel AW address: %p\n", aw.AgentOutputChannel)
 		for {
 			select {
 			case <-aw.ctx.Done():
 				// context cancelled → exit
-				log.Println("AgentWorker context done, exiting...")
+				aw.Log.Debug("AgentWorker context done, exiting")
 				return
 
 			case transcript, ok := <-aw.AgentInputChannel:
@@ -67,14 +87,44 @@ func (aw *AgentWorker) Start() {
 					// upstream closed → exit
 					return
 				}
-				log.Print("Received transcript: ", transcript)
+				aw.Log.Debug("Received transcript", "text", transcript.Text)
+				if aw.ShouldReply != nil && !aw.ShouldReply(transcript) {
+					continue
+				}
+				if aw.OnTurnStart != nil {
+					aw.OnTurnStart(transcript)
+				}
+				turnCtx, cancel := context.WithCancel(aw.ctx)
+				if aw.TurnContext != nil {
+					turnCtx = aw.TurnContext(turnCtx)
+				}
+				aw.turnMu.Lock()
+				aw.turnCancel = cancel
+				aw.turnMu.Unlock()
 				// Send the transcript to the OpenAI client for processing
-				aw.OpenAIClient.StreamResponse(transcript)
+				input := transcript.Text
+				if aw.HidePIIFromModel {
+					input = redact.String(input)
+				}
+				reply := aw.OpenAIClient.StreamResponseContext(turnCtx, input)
+				cancel()
+				if aw.OnTurnEnd != nil {
+					aw.OnTurnEnd(reply)
+				}
 			}
 		}
 	}()
 }
 
+// Interrupt abandons the reply currently being generated, if any.
+func (aw *AgentWorker) Interrupt() {
+	aw.turnMu.Lock()
+	defer aw.turnMu.Unlock()
+	if aw.turnCancel != nil {
+		aw.turnCancel()
+	}
+}
+
 func (aw *AgentWorker) Stop() {
 	// Stop the agent worker
 	aw.cancel()
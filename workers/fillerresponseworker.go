@@ -3,26 +3,33 @@ package workers
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/mrsingh-rishi/voice-bot/llm"
+	"github.com/mrsingh-rishi/voice-bot/output"
+	"github.com/mrsingh-rishi/voice-bot/tts"
 )
 
 type FillerResponseWorker struct {
-	ctx                     context.Context
-	cancel                  context.CancelFunc
-	OpenAIClient            llm.OpenAIClient
-	FillerOutputChannel     chan<- string
-	FillerInputChannel      <-chan string
+	ctx                 context.Context
+	cancel              context.CancelFunc
+	Fillers             *tts.FillerCache
+	VoiceId             string
+	Threshold           time.Duration // how long the caller waits for real audio before a filler plays
+	FillerOutputChannel chan<- output.Filler
+	FillerInputChannel  <-chan string
+	turns               int
+	voiceMu             sync.Mutex
 }
 
-
-func NewFillerResponseWorker(apikey string, model string, fillerOutputChannel chan<- string, fillerInputChannel <-chan string) (*FillerResponseWorker, error) {
+func NewFillerResponseWorker(fillers *tts.FillerCache, voiceId string, threshold time.Duration, fillerOutputChannel chan<- output.Filler, fillerInputChannel <-chan string) (*FillerResponseWorker, error) {
 	// Params Validation
-	if apikey == "" {
-		return nil, fmt.Errorf("API key is required")
+	if fillers == nil {
+		return nil, fmt.Errorf("filler cache is required")
 	}
-	if model == "" {
-		return nil, fmt.Errorf("model is required")
+	if voiceId == "" {
+		return nil, fmt.Errorf("voice id is required")
 	}
 	if fillerOutputChannel == nil {
 		return nil, fmt.Errorf("filler output channel is required")
@@ -30,27 +37,16 @@ func NewFillerResponseWorker(apikey string, model string, fillerOutputChannel ch
 	if fillerInputChannel == nil {
 		return nil, fmt.Errorf("filler input channel is required")
 	}
-	fillerPrompt := `You are given a user's spontaneous utterance as the next input plus the following list of common human filler‑words:
-
-uh, um, er, erm, ah, oh, uh‑huh, hmm, mm, mmm, yeah, yep, y'know, like, well, so, actually, basically, literally, seriously, honestly, frankly, admittedly, anyway, anyways, sort of, kind of, you see, I mean, let's see, if you will, as it were, now, then, meanwhile, perhaps, maybe, you know, right, okay, gotcha, sure, alright, wow, oops, sigh, gasp, hmm‑mm, oh‑no, ah‑ha
-
-Your task is to choose the single filler‑word from that list that a person would most likely utter immediately after the given input. Return **only** that one word—no punctuation, no extra text. Do not include examples, explanations, or formatting—just the bare filler‑word.`
-
-	fillerResponseGenerator, err2 := llm.NewOpenAIClient(apikey, fillerPrompt, model, fillerOutputChannel)
-	if err2 != nil {
-		return nil, err2
-	}
-	if fillerResponseGenerator == nil {
-		return nil, err2
-	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	fillerResponseWorker := &FillerResponseWorker{
 		ctx:                 ctx,
 		cancel:              cancel,
+		Fillers:             fillers,
+		VoiceId:             voiceId,
+		Threshold:           threshold,
 		FillerOutputChannel: fillerOutputChannel,
 		FillerInputChannel:  fillerInputChannel,
-		OpenAIClient:        *fillerResponseGenerator,
 	}
 
 	return fillerResponseWorker, nil
@@ -62,16 +58,75 @@ func (frw *FillerResponseWorker) Start() {
 			select {
 			case <-frw.ctx.Done():
 				return
-			case input := <-frw.FillerInputChannel:
-				if input == "" {
+			case input, ok := <-frw.FillerInputChannel:
+				if !ok {
+					return
+				}
+				if input == "" || frw.Threshold <= 0 {
 					continue
 				}
-				frw.OpenAIClient.StreamResponse(input)
+				frw.scheduleFiller(input, time.Now())
 			}
 		}
 	}()
 }
 
+// scheduleFiller hands a clip to the output once the threshold has passed.
+// The output drops it if the agent's answer started playing in the meantime.
+func (frw *FillerResponseWorker) scheduleFiller(input string, turnStart time.Time) {
+	frw.turns++
+	word := frw.chooseFiller(input)
+	chunks, ok := frw.Fillers.Clip(frw.voice(), word)
+	if !ok {
+		return
+	}
+	time.AfterFunc(frw.Threshold, func() {
+		select {
+		case <-frw.ctx.Done():
+		case frw.FillerOutputChannel <- output.Filler{Word: word, TurnStart: turnStart, Chunks: chunks}:
+		}
+	})
+}
+
+// chooseFiller picks a filler that fits the utterance without an LLM round trip,
+// rotating through the neutral ones so the caller does not hear the same word every turn.
+func (frw *FillerResponseWorker) chooseFiller(input string) string {
+	text := strings.ToLower(strings.TrimSpace(input))
+	var preferred []string
+	switch {
+	case strings.HasSuffix(text, "?"):
+		preferred = []string{"hmm", "let me see", "well"}
+	case strings.Contains(text, "thank") || strings.Contains(text, "please"):
+		preferred = []string{"sure", "okay"}
+	default:
+		preferred = []string{"okay", "right", "so"}
+	}
+	for i := range preferred {
+		word := preferred[(frw.turns+i)%len(preferred)]
+		if _, ok := frw.Fillers.Clip(frw.voice(), word); ok {
+			return word
+		}
+	}
+	if len(frw.Fillers.Words) == 0 {
+		return ""
+	}
+	return frw.Fillers.Words[frw.turns%len(frw.Fillers.Words)]
+}
+
 func (frw *FillerResponseWorker) Stop() {
 	frw.cancel()
-}
\ No newline at end of file
+}
+
+// SetVoice switches the voice fillers are played in. Only clips warmed for
+// that voice are played, so a voice without clips plays no fillers.
+func (frw *FillerResponseWorker) SetVoice(voiceId string) {
+	frw.voiceMu.Lock()
+	defer frw.voiceMu.Unlock()
+	frw.VoiceId = voiceId
+}
+
+func (frw *FillerResponseWorker) voice() string {
+	frw.voiceMu.Lock()
+	defer frw.voiceMu.Unlock()
+	return frw.VoiceId
+}
@@ -0,0 +1,96 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrLimitReached is returned when a call would exceed a workspace limit.
+var ErrLimitReached = errors.New("workspace usage limit reached")
+
+// Limits cap a workspace's usage. Zero means no limit.
+type Limits struct {
+	// MaxConcurrentCalls caps the calls and chats in progress at once
+	MaxConcurrentCalls int `json:"max_concurrent_calls"`
+	// MaxCallsPerDay caps the calls and chats started per UTC day
+	MaxCallsPerDay int `json:"max_calls_per_day"`
+}
+
+// Usage is what a workspace is using right now.
+type Usage struct {
+	ActiveCalls int `json:"active_calls"`
+	CallsToday  int `json:"calls_today"`
+}
+
+type usage struct {
+	limits Limits
+
+	mu     sync.Mutex
+	active int
+	day    string
+	today  int
+}
+
+func newUsage(limits Limits) *usage {
+	return &usage{limits: limits}
+}
+
+// roll starts a new count at midnight UTC. The caller holds u.mu.
+func (u *usage) roll(now time.Time) {
+	if day := now.UTC().Format(time.DateOnly); day != u.day {
+		u.day, u.today = day, 0
+	}
+}
+
+// check returns ErrLimitReached when another call would exceed a limit.
+// The caller holds u.mu.
+func (u *usage) check(now time.Time) error {
+	u.roll(now)
+	if u.limits.MaxConcurrentCalls > 0 && u.active >= u.limits.MaxConcurrentCalls {
+		return fmt.Errorf("%w: %d concurrent calls", ErrLimitReached, u.limits.MaxConcurrentCalls)
+	}
+	if u.limits.MaxCallsPerDay > 0 && u.today >= u.limits.MaxCallsPerDay {
+		return fmt.Errorf("%w: %d calls per day", ErrLimitReached, u.limits.MaxCallsPerDay)
+	}
+	return nil
+}
+
+// CheckLimits reports whether a call could start now, e.g. before asking
+// Twilio to place one.
+func (w *Workspace) CheckLimits() error {
+	w.usage.mu.Lock()
+	defer w.usage.mu.Unlock()
+	return w.usage.check(time.Now())
+}
+
+// StartCall counts a call against the workspace's limits. end must be
+// called once the call is over.
+func (w *Workspace) StartCall() (end func(), err error) {
+	u := w.usage
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if err := u.check(time.Now()); err != nil {
+		return nil, err
+	}
+	u.active++
+	u.today++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			u.mu.Lock()
+			u.active--
+			u.mu.Unlock()
+		})
+	}, nil
+}
+
+// Usage returns the workspace's current usage.
+func (w *Workspace) Usage() Usage {
+	u := w.usage
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.roll(time.Now())
+	return Usage{ActiveCalls: u.active, CallsToday: u.today}
+}
@@ -0,0 +1,114 @@
+package workspace
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mrsingh-rishi/voice-bot/logging"
+)
+
+// Call events sent to workspace webhooks.
+const (
+	EventCallStarted  = "call.started"
+	EventCallEnded    = "call.ended"
+	EventCallRejected = "call.rejected"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of a webhook body, keyed
+// with the workspace's webhook secret.
+const SignatureHeader = "X-Voicebot-Signature"
+
+// WebhookConfig is where a workspace wants its call events.
+type WebhookConfig struct {
+	URL string `json:"url"`
+	// SecretEnv names the variable holding the key deliveries are signed
+	// with; unsigned without it
+	SecretEnv string `json:"secret_env"`
+	// Events limits the events sent; empty sends every event
+	Events []string `json:"events"`
+}
+
+// WebhookEvent is the body of a webhook delivery.
+type WebhookEvent struct {
+	Event     string         `json:"event"`
+	Workspace string         `json:"workspace"`
+	Time      time.Time      `json:"time"`
+	Data      map[string]any `json:"data"`
+}
+
+// Notifier posts a workspace's events to its webhook. A nil Notifier, or
+// one without a URL, drops them.
+type Notifier struct {
+	workspace string
+	config    WebhookConfig
+	secret    []byte
+	client    *http.Client
+}
+
+// NewNotifier creates the notifier of a workspace.
+func NewNotifier(workspace string, config WebhookConfig, secret string) *Notifier {
+	return &Notifier{
+		workspace: workspace,
+		config:    config,
+		secret:    []byte(secret),
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Send delivers an event in the background; failures are logged, not
+// retried, so a slow webhook never holds up a call.
+func (n *Notifier) Send(event string, data map[string]any) {
+	if n == nil || n.config.URL == "" || !n.wants(event) {
+		return
+	}
+	body, err := json.Marshal(WebhookEvent{Event: event, Workspace: n.workspace, Time: time.Now().UTC(), Data: data})
+	if err != nil {
+		logging.Logger("workspace").Error("Failed to encode webhook", "event", event, "workspace", n.workspace, "error", err)
+		return
+	}
+	go func() {
+		if err := n.post(body); err != nil {
+			logging.Logger("workspace").Warn("Webhook failed", "event", event, "workspace", n.workspace, "error", err)
+		}
+	}()
+}
+
+func (n *Notifier) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, n.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(n.secret) > 0 {
+		mac := hmac.New(sha256.New, n.secret)
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (n *Notifier) wants(event string) bool {
+	if len(n.config.Events) == 0 {
+		return true
+	}
+	for _, e := range n.config.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,231 @@
+// Package workspace separates the teams and customers sharing one server.
+// Each workspace has its own vendor credentials, phone numbers, agents,
+// webhooks and usage limits, and every API key belongs to one.
+package workspace
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mrsingh-rishi/voice-bot/logging"
+)
+
+// DefaultID is the workspace built from the server's own environment. It
+// exists on every server and takes the requests that name no workspace.
+const DefaultID = "default"
+
+// validID keeps workspace IDs safe to use as directory names.
+var validID = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Config is a workspace as written in its JSON file. Secrets are not kept
+// in the file; the *_env fields name the environment variables holding them.
+type Config struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Twilio is the account the workspace's calls are placed with; without
+	// it the workspace cannot place outbound calls
+	Twilio TwilioConfig `json:"twilio"`
+	// PhoneNumbers are the workspace's numbers in E.164. Outbound calls are
+	// placed from the first unless the request picks another, and inbound
+	// Twilio calls to any of them land in this workspace
+	PhoneNumbers []string `json:"phone_numbers"`
+	// Vendors name the workspace's STT, LLM and TTS keys; the ones not set
+	// use the server's keys
+	Vendors VendorConfig `json:"vendors"`
+	// AgentsDir holds the workspace's agent configs; without it the
+	// workspace only has the default agent
+	AgentsDir string        `json:"agents_dir"`
+	Webhooks  WebhookConfig `json:"webhooks"`
+	Limits    Limits        `json:"limits"`
+}
+
+// TwilioConfig is a Twilio account.
+type TwilioConfig struct {
+	AccountSID   string `json:"account_sid"`
+	AuthTokenEnv string `json:"auth_token_env"`
+}
+
+// VendorConfig names the environment variables holding vendor API keys.
+type VendorConfig struct {
+	DeepgramAPIKeyEnv   string `json:"deepgram_api_key_env"`
+	OpenAIAPIKeyEnv     string `json:"openai_api_key_env"`
+	ElevenLabsAPIKeyEnv string `json:"elevenlabs_api_key_env"`
+}
+
+// Keys are vendor API keys.
+type Keys struct {
+	Deepgram   string
+	OpenAI     string
+	ElevenLabs string
+}
+
+// Workspace is a loaded workspace with its secrets resolved.
+type Workspace struct {
+	Config
+	// TwilioAuthToken is empty when the workspace has no Twilio account
+	TwilioAuthToken string
+	Keys            Keys
+	// Webhooks delivers the workspace's call events
+	Webhooks *Notifier
+
+	usage *usage
+}
+
+// New resolves a workspace's secrets. Vendor keys it does not name are
+// taken from serverKeys.
+func New(cfg Config, serverKeys Keys) (*Workspace, error) {
+	if !validID.MatchString(cfg.ID) {
+		return nil, fmt.Errorf("invalid workspace ID %q: use lowercase letters, digits, - and _", cfg.ID)
+	}
+	if cfg.Name == "" {
+		cfg.Name = cfg.ID
+	}
+	w := &Workspace{Config: cfg, Keys: serverKeys, usage: newUsage(cfg.Limits)}
+	var err error
+	if w.TwilioAuthToken, err = secret(cfg.Twilio.AuthTokenEnv); err != nil {
+		return nil, err
+	}
+	if (cfg.Twilio.AccountSID == "") != (w.TwilioAuthToken == "") {
+		return nil, fmt.Errorf("workspace %q: twilio needs both account_sid and auth_token_env", cfg.ID)
+	}
+	vendors := []struct {
+		env string
+		key *string
+	}{
+		{cfg.Vendors.DeepgramAPIKeyEnv, &w.Keys.Deepgram},
+		{cfg.Vendors.OpenAIAPIKeyEnv, &w.Keys.OpenAI},
+		{cfg.Vendors.ElevenLabsAPIKeyEnv, &w.Keys.ElevenLabs},
+	}
+	for _, v := range vendors {
+		if v.env == "" {
+			continue
+		}
+		if *v.key, err = secret(v.env); err != nil {
+			return nil, err
+		}
+	}
+	webhookSecret, err := secret(cfg.Webhooks.SecretEnv)
+	if err != nil {
+		return nil, err
+	}
+	w.Webhooks = NewNotifier(cfg.ID, cfg.Webhooks, webhookSecret)
+	return w, nil
+}
+
+// HasTwilio reports whether the workspace can place calls through Twilio.
+func (w *Workspace) HasTwilio() bool {
+	return w.TwilioAuthToken != "" && len(w.PhoneNumbers) > 0
+}
+
+// OwnsNumber reports whether number is one of the workspace's.
+func (w *Workspace) OwnsNumber(number string) bool {
+	for _, n := range w.PhoneNumbers {
+		if n == number {
+			return true
+		}
+	}
+	return false
+}
+
+// secret reads the environment variable a config names. Naming one that is
+// not set is an error rather than a silent fallback to the server's keys.
+func secret(env string) (string, error) {
+	if env == "" {
+		return "", nil
+	}
+	value := os.Getenv(env)
+	if value == "" {
+		return "", fmt.Errorf("%s is not set", env)
+	}
+	return value, nil
+}
+
+// Load reads a workspace config from a JSON file. The ID defaults to the
+// file name.
+func Load(path string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse workspace %s: %w", path, err)
+	}
+	if cfg.ID == "" {
+		cfg.ID = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return cfg, nil
+}
+
+// Registry holds every workspace on the server.
+type Registry struct {
+	workspaces map[string]*Workspace
+	byNumber   map[string]*Workspace
+}
+
+// LoadRegistry loads every *.json file in dir as a workspace, next to the
+// default workspace described by defaultConfig. A file with the default ID
+// replaces it. An empty dir yields only the default workspace.
+func LoadRegistry(dir string, defaultConfig Config, serverKeys Keys) (*Registry, error) {
+	configs := map[string]Config{DefaultID: defaultConfig}
+	if dir != "" {
+		paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			cfg, err := Load(path)
+			if err != nil {
+				return nil, err
+			}
+			configs[cfg.ID] = cfg
+			logging.Logger("workspace").Info("Loaded workspace", "workspace", cfg.ID, "path", path)
+		}
+	}
+	r := &Registry{workspaces: make(map[string]*Workspace), byNumber: make(map[string]*Workspace)}
+	for _, cfg := range configs {
+		w, err := New(cfg, serverKeys)
+		if err != nil {
+			return nil, err
+		}
+		// A number belongs to one workspace so its inbound calls have a
+		// single home
+		for _, number := range w.PhoneNumbers {
+			if other, ok := r.byNumber[number]; ok {
+				return nil, fmt.Errorf("phone number %s is in workspaces %q and %q", number, other.ID, w.ID)
+			}
+			r.byNumber[number] = w
+		}
+		r.workspaces[w.ID] = w
+	}
+	return r, nil
+}
+
+// Get returns the workspace with the given ID. An empty ID selects the
+// default workspace.
+func (r *Registry) Get(id string) (*Workspace, bool) {
+	if id == "" {
+		id = DefaultID
+	}
+	w, ok := r.workspaces[id]
+	return w, ok
+}
+
+// ByNumber returns the workspace owning a phone number.
+func (r *Registry) ByNumber(number string) (*Workspace, bool) {
+	w, ok := r.byNumber[number]
+	return w, ok
+}
+
+// All returns every workspace.
+func (r *Registry) All() []*Workspace {
+	all := make([]*Workspace, 0, len(r.workspaces))
+	for _, w := range r.workspaces {
+		all = append(all, w)
+	}
+	return all
+}